@@ -0,0 +1,277 @@
+// Package abuse screens submitted code against configurable rules before it
+// runs and watches finished runs for repeated resource exhaustion, so that
+// fork bombs, miners and sandbox probes can be flagged or blocked.
+package abuse
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Actions of rules and signal policies, in increasing severity.
+const (
+	ActionFlag   = "flag"   // Let the code run and record an incident
+	ActionReject = "reject" // Refuse this execution
+	ActionBlock  = "block"  // Refuse it and block the client for BlockDuration
+)
+
+var severity = map[string]int{ActionFlag: 1, ActionReject: 2, ActionBlock: 3}
+
+// Rule is a pattern of abusive code.
+type Rule struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	Languages   []string `json:"languages,omitempty"` // All languages when empty
+	Action      string   `json:"action"`
+	// Pattern is a regular expression matched against every source file.
+	Pattern string `json:"pattern,omitempty"`
+	// Imports and Calls match Go code by its syntax tree: imported package
+	// paths, and calls given as "path.Name" such as "syscall.ForkExec" or
+	// "os/exec.Command", whatever name the package is imported under. With
+	// InLoop, calls only match inside loops without a condition.
+	Imports []string `json:"imports,omitempty"`
+	Calls   []string `json:"calls,omitempty"`
+	InLoop  bool     `json:"inLoop,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// SignalPolicy decides when repeated resource exhaustion by one client counts
+// as abuse: Threshold signals within Window trigger Action.
+type SignalPolicy struct {
+	Threshold int      `json:"threshold"` // 0 disables the check
+	Window    Duration `json:"window"`
+	Action    string   `json:"action"` // flag or block
+}
+
+// Config is the content of the rules file.
+type Config struct {
+	Rules         []Rule       `json:"rules"`
+	Signals       SignalPolicy `json:"signals"`
+	BlockDuration Duration     `json:"blockDuration"`
+}
+
+// Duration is a time.Duration written as a string such as "10m" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations are strings such as \"10m\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// defaultConfig applies when ABUSE_RULES_FILE is not set.
+var defaultConfig = Config{
+	Rules: []Rule{
+		{
+			ID:          "shell-fork-bomb",
+			Description: "Shell fork bomb",
+			Action:      ActionBlock,
+			Pattern:     `:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`,
+		},
+		{
+			ID:          "fork-loop",
+			Description: "fork() in an endless loop",
+			Languages:   []string{"c", "cpp", "python", "ruby"},
+			Action:      ActionBlock,
+			Pattern:     `(while\s*\(\s*(1|true)\s*\)|for\s*\(\s*;\s*;\s*\)|while\s+(True|true|1)\s*:?|loop\s*(do\b|\{))\s*\{?\s*(os\.|Process\.)?fork\b`,
+		},
+		{
+			ID:          "go-fork-loop",
+			Description: "Process creation in an endless loop",
+			Languages:   []string{"go"},
+			Action:      ActionBlock,
+			Calls:       []string{"syscall.ForkExec", "syscall.RawSyscall", "syscall.Syscall", "os.StartProcess", "os/exec.Command"},
+			InLoop:      true,
+		},
+		{
+			ID:          "docker-socket",
+			Description: "Access to the Docker socket",
+			Action:      ActionReject,
+			Pattern:     `docker\.sock|/var/run/docker|DOCKER_HOST`,
+		},
+		{
+			ID:          "crypto-miner",
+			Description: "Cryptocurrency miner",
+			Action:      ActionBlock,
+			Pattern:     `(?i)stratum\+(tcp|ssl)://|xmrig|cryptonight|coinhive|randomx_`,
+		},
+		{
+			ID:          "go-unsafe",
+			Description: "Go code using unsafe or raw system calls",
+			Languages:   []string{"go"},
+			Action:      ActionFlag,
+			Imports:     []string{"unsafe", "syscall", "golang.org/x/sys/unix"},
+		},
+	},
+	Signals: SignalPolicy{
+		Threshold: 5,
+		Window:    Duration(10 * time.Minute),
+		Action:    ActionFlag,
+	},
+	BlockDuration: Duration(24 * time.Hour),
+}
+
+var (
+	rulesMu      sync.RWMutex
+	config       *Config
+	loadedAt     time.Time
+	rulesModTime time.Time
+)
+
+func init() {
+	cfg := defaultConfig
+	if err := cfg.compile(); err != nil {
+		panic(err)
+	}
+	config = &cfg
+}
+
+// LoadRules reads the rules from the JSON file named by ABUSE_RULES_FILE, or
+// uses the built-in ones if it is not set. Settings the file omits keep
+// their defaults; a rules list in it replaces the built-in rules.
+func LoadRules() error {
+	path := os.Getenv("ABUSE_RULES_FILE")
+	if path == "" {
+		cfg := defaultConfig
+		if err := cfg.compile(); err != nil {
+			return err
+		}
+		setConfig(&cfg, time.Time{})
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read abuse rules: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read abuse rules: %w", err)
+	}
+	// Decoding into the default rules would write into their backing array
+	cfg := defaultConfig
+	cfg.Rules = nil
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse abuse rules: %w", err)
+	}
+	if cfg.Rules == nil {
+		cfg.Rules = defaultConfig.Rules
+	}
+	if err := cfg.compile(); err != nil {
+		return err
+	}
+	setConfig(&cfg, info.ModTime())
+	return nil
+}
+
+// WatchRules reloads the rules file whenever it changes, checking every
+// interval. A file that fails to load is logged and the previous rules stay
+// in force.
+func WatchRules(interval time.Duration) {
+	path := os.Getenv("ABUSE_RULES_FILE")
+	if path == "" {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Printf("Failed to check abuse rules: %v", err)
+				continue
+			}
+			rulesMu.RLock()
+			changed := !info.ModTime().Equal(rulesModTime)
+			rulesMu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := LoadRules(); err != nil {
+				log.Printf("Keeping previous abuse rules: %v", err)
+				// Do not retry until the file changes again
+				rulesMu.Lock()
+				rulesModTime = info.ModTime()
+				rulesMu.Unlock()
+				continue
+			}
+			log.Printf("Reloaded %d abuse rules from %s", len(Rules().Rules), path)
+		}
+	}()
+}
+
+func setConfig(cfg *Config, modTime time.Time) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	config = cfg
+	loadedAt = time.Now()
+	rulesModTime = modTime
+}
+
+// Rules returns the rules in force.
+func Rules() Config {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return *config
+}
+
+// LoadedAt reports when the rules in force were loaded.
+func LoadedAt() time.Time {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return loadedAt
+}
+
+// compile validates the configuration and compiles the rules' patterns. The
+// rules are copied so that compiling never touches defaultConfig.
+func (cfg *Config) compile() error {
+	rules := make([]Rule, len(cfg.Rules))
+	seen := map[string]bool{}
+	for i, rule := range cfg.Rules {
+		if rule.ID == "" || seen[rule.ID] {
+			return fmt.Errorf("abuse rules: every rule needs a unique id")
+		}
+		seen[rule.ID] = true
+		if severity[rule.Action] == 0 {
+			return fmt.Errorf("abuse rules: %q has unknown action %q", rule.ID, rule.Action)
+		}
+		if rule.Pattern == "" && len(rule.Imports) == 0 && len(rule.Calls) == 0 {
+			return fmt.Errorf("abuse rules: %q needs a pattern, imports or calls", rule.ID)
+		}
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("abuse rules: %q has an invalid pattern: %w", rule.ID, err)
+			}
+			rule.re = re
+		}
+		rules[i] = rule
+	}
+	cfg.Rules = rules
+
+	if cfg.Signals.Threshold < 0 || (cfg.Signals.Threshold > 0 && cfg.Signals.Window <= 0) {
+		return fmt.Errorf("abuse rules: signals need a positive threshold and window")
+	}
+	if cfg.Signals.Action != ActionFlag && cfg.Signals.Action != ActionBlock {
+		return fmt.Errorf("abuse rules: signals action must be flag or block")
+	}
+	if cfg.BlockDuration <= 0 {
+		return fmt.Errorf("abuse rules: blockDuration must be positive")
+	}
+	return nil
+}
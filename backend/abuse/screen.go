@@ -0,0 +1,201 @@
+package abuse
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"code-editor/sandbox"
+)
+
+// maxExcerptLength shortens the code quoted in matches.
+const maxExcerptLength = 200
+
+// Match is a rule that matched submitted code.
+type Match struct {
+	RuleID      string `json:"ruleId"`
+	Description string `json:"description"`
+	Action      string `json:"action"`
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Excerpt     string `json:"excerpt"`
+}
+
+// RejectedError is returned for code that matched a reject or block rule.
+type RejectedError struct {
+	Match Match
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("code rejected: %s (rule %s)", e.Match.Description, e.Match.RuleID)
+}
+
+// Check is a screening step run on every request before it executes. The
+// rules are one; others can be added with Register.
+type Check func(req sandbox.Request) []Match
+
+var (
+	checksMu sync.RWMutex
+	checks   = []Check{screenRules}
+)
+
+// Register adds a screening step. It must be called before serving.
+func Register(check Check) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	checks = append(checks, check)
+}
+
+// Screen runs every screening step on the request and returns their matches,
+// most severe first.
+func Screen(req sandbox.Request) []Match {
+	checksMu.RLock()
+	defer checksMu.RUnlock()
+	var matches []Match
+	for _, check := range checks {
+		matches = append(matches, check(req)...)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return severity[matches[i].Action] > severity[matches[j].Action]
+	})
+	return matches
+}
+
+// screenRules checks the request's source files, including a benchmark
+// baseline, against the rules for its language.
+func screenRules(req sandbox.Request) []Match {
+	sources := map[string]string{"main": req.Code}
+	for name, content := range req.Files {
+		sources[name] = content
+	}
+	if req.Baseline != "" {
+		sources["baseline"] = req.Baseline
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var matches []Match
+	for _, rule := range Rules().Rules {
+		if len(rule.Languages) > 0 && !slices.Contains(rule.Languages, req.Language) {
+			continue
+		}
+		for _, name := range names {
+			if m, ok := rule.match(req.Language, name, sources[name]); ok {
+				matches = append(matches, m)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// match reports the rule's first match in one source file.
+func (r *Rule) match(language, name, source string) (Match, bool) {
+	m := Match{RuleID: r.ID, Description: r.Description, Action: r.Action, File: name}
+	if r.re != nil {
+		loc := r.re.FindStringIndex(source)
+		if loc == nil {
+			return m, false
+		}
+		m.Line = strings.Count(source[:loc[0]], "\n") + 1
+		m.Excerpt = excerpt(source, m.Line)
+		// A rule with syntax patterns as well needs both to match
+		if len(r.Imports) == 0 && len(r.Calls) == 0 {
+			return m, true
+		}
+	}
+	if len(r.Imports) == 0 && len(r.Calls) == 0 {
+		return m, false
+	}
+	if language != "go" || (name != "main" && name != "baseline" && path.Ext(name) != ".go") {
+		return m, false
+	}
+	line, ok := r.matchGo(source)
+	if !ok {
+		return m, false
+	}
+	if m.Line == 0 {
+		m.Line = line
+		m.Excerpt = excerpt(source, line)
+	}
+	return m, true
+}
+
+// matchGo looks for the rule's imports and calls in Go source and returns the
+// line of the first hit. Code that does not parse never matches; it will not
+// compile either.
+func (r *Rule) matchGo(source string) (int, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", source, parser.SkipObjectResolution)
+	if err != nil {
+		return 0, false
+	}
+
+	// Local name of every imported package, to resolve calls through aliases
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if slices.Contains(r.Imports, importPath) {
+			return fset.Position(spec.Pos()).Line, true
+		}
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = importPath
+	}
+	if len(r.Calls) == 0 {
+		return 0, false
+	}
+
+	line := 0
+	var inspect func(n ast.Node, inLoop bool) bool
+	inspect = func(n ast.Node, inLoop bool) bool {
+		if line != 0 {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.ForStmt:
+			if n.Cond == nil && !inLoop {
+				ast.Inspect(n.Body, func(n ast.Node) bool { return inspect(n, true) })
+				return false
+			}
+		case *ast.CallExpr:
+			if !r.InLoop || inLoop {
+				if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+					if pkg, ok := sel.X.(*ast.Ident); ok && imports[pkg.Name] != "" {
+						if slices.Contains(r.Calls, imports[pkg.Name]+"."+sel.Sel.Name) {
+							line = fset.Position(n.Pos()).Line
+							return false
+						}
+					}
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(file, func(n ast.Node) bool { return inspect(n, false) })
+	return line, line != 0
+}
+
+func excerpt(source string, line int) string {
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := strings.TrimSpace(lines[line-1])
+	if len(text) > maxExcerptLength {
+		text = text[:maxExcerptLength] + "..."
+	}
+	return text
+}
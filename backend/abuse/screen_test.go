@@ -0,0 +1,100 @@
+package abuse
+
+import "testing"
+
+func TestMatchGo(t *testing.T) {
+	spawn := Rule{Calls: []string{"os/exec.Command", "syscall.ForkExec"}, InLoop: true}
+	tests := []struct {
+		name     string
+		rule     Rule
+		source   string
+		wantLine int
+		wantOK   bool
+	}{
+		{
+			name:     "import",
+			rule:     Rule{Imports: []string{"unsafe"}},
+			source:   "package main\n\nimport (\n\t\"fmt\"\n\t\"unsafe\"\n)\n\nfunc main() { fmt.Println(unsafe.Sizeof(0)) }\n",
+			wantLine: 5,
+			wantOK:   true,
+		},
+		{
+			name:     "blank import",
+			rule:     Rule{Imports: []string{"unsafe"}},
+			source:   "package main\n\nimport _ \"unsafe\"\n\nfunc main() {}\n",
+			wantLine: 3,
+			wantOK:   true,
+		},
+		{
+			name:   "import path must match exactly",
+			rule:   Rule{Imports: []string{"syscall"}},
+			source: "package main\n\nimport \"golang.org/x/sys/unix/syscall\"\n\nfunc main() {}\n",
+		},
+		{
+			name:   "import in a comment or string",
+			rule:   Rule{Imports: []string{"unsafe"}},
+			source: "package main\n\n// import \"unsafe\"\nfunc main() { println(`import \"unsafe\"`) }\n",
+		},
+		{
+			name:     "call in an endless loop",
+			rule:     spawn,
+			source:   "package main\n\nimport \"os/exec\"\n\nfunc main() {\n\tfor {\n\t\texec.Command(\"sh\").Start()\n\t}\n}\n",
+			wantLine: 7,
+			wantOK:   true,
+		},
+		{
+			name:     "call through an alias",
+			rule:     spawn,
+			source:   "package main\n\nimport run \"os/exec\"\n\nfunc main() {\n\tfor {\n\t\trun.Command(\"sh\").Start()\n\t}\n}\n",
+			wantLine: 7,
+			wantOK:   true,
+		},
+		{
+			name:     "call nested in an endless loop",
+			rule:     spawn,
+			source:   "package main\n\nimport \"syscall\"\n\nfunc main() {\n\tfor {\n\t\tif true {\n\t\t\tgo func() { syscall.ForkExec(\"/bin/sh\", nil, nil) }()\n\t\t}\n\t}\n}\n",
+			wantLine: 8,
+			wantOK:   true,
+		},
+		{
+			name:   "call in a bounded loop",
+			rule:   spawn,
+			source: "package main\n\nimport \"os/exec\"\n\nfunc main() {\n\tfor i := 0; i < 3; i++ {\n\t\texec.Command(\"ls\").Run()\n\t}\n}\n",
+		},
+		{
+			name:   "call outside a loop",
+			rule:   spawn,
+			source: "package main\n\nimport \"os/exec\"\n\nfunc main() {\n\texec.Command(\"ls\").Run()\n}\n",
+		},
+		{
+			name:     "call outside a loop without InLoop",
+			rule:     Rule{Calls: []string{"os/exec.Command"}},
+			source:   "package main\n\nimport \"os/exec\"\n\nfunc main() {\n\texec.Command(\"ls\").Run()\n}\n",
+			wantLine: 6,
+			wantOK:   true,
+		},
+		{
+			name:   "method of a local variable with a package's name",
+			rule:   Rule{Calls: []string{"os/exec.Command"}},
+			source: "package main\n\ntype runner struct{}\n\nfunc (runner) Command() {}\n\nfunc main() {\n\texec := runner{}\n\texec.Command()\n}\n",
+		},
+		{
+			name:   "same function name from another package",
+			rule:   Rule{Calls: []string{"os/exec.Command"}},
+			source: "package main\n\nimport exec \"example.com/exec\"\n\nfunc main() { exec.Command() }\n",
+		},
+		{
+			name:   "code that does not parse",
+			rule:   Rule{Imports: []string{"unsafe"}},
+			source: "package main\n\nimport \"unsafe\"\n\nfunc main() {\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, ok := tt.rule.matchGo(tt.source)
+			if line != tt.wantLine || ok != tt.wantOK {
+				t.Errorf("matchGo() = %d, %v; want %d, %v", line, ok, tt.wantLine, tt.wantOK)
+			}
+		})
+	}
+}
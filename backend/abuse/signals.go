@@ -0,0 +1,109 @@
+package abuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"code-editor/db"
+	"code-editor/sandbox"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Signals of resource exhaustion in a finished run.
+const (
+	SignalTimeout = "timeout"
+	SignalOOM     = "oom"
+	SignalPids    = "pids"
+)
+
+const (
+	signalPrefix = "abuse:signals:" // Signal count of a client in the current window
+	blockPrefix  = "abuse:blocked:" // Set while a client is blocked
+)
+
+// ErrBlocked refuses executions by a blocked client.
+var ErrBlocked = errors.New("your executions are blocked because of suspected abuse, contact an administrator")
+
+// pidsMessages are what runtimes print when they cannot create a process or
+// thread because the container's pids limit is reached.
+var pidsMessages = []string{
+	"Resource temporarily unavailable",
+	"fork: retry",
+	"can't start new thread",
+	"failed to create new OS thread",
+	"unable to create native thread",
+	"pthread_create failed",
+}
+
+// SignalOf returns the resource exhaustion the result shows, or "".
+func SignalOf(result *sandbox.Result) string {
+	switch {
+	case result.Status == sandbox.StatusTimeout:
+		return SignalTimeout
+	case result.Status == sandbox.StatusError && result.ExitCode == 137: // SIGKILL from the OOM killer
+		return SignalOOM
+	case result.Status == sandbox.StatusError:
+		for _, msg := range pidsMessages {
+			if strings.Contains(result.Output, msg) {
+				return SignalPids
+			}
+		}
+	}
+	return ""
+}
+
+// CountSignal counts a signal for the client, an email or "ip:<addr>", and
+// returns the signal policy's action once the client reaches its threshold
+// within the window, or "" otherwise. Each window triggers at most once.
+func CountSignal(ctx context.Context, client string) (string, int, error) {
+	policy := Rules().Signals
+	if policy.Threshold == 0 {
+		return "", 0, nil
+	}
+	key := signalPrefix + client
+	pipe := db.RedisClient.TxPipeline()
+	// The first signal of a window creates the key and starts its expiry
+	pipe.SetNX(ctx, key, 0, time.Duration(policy.Window))
+	incr := pipe.Incr(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", 0, fmt.Errorf("failed to count signal: %w", err)
+	}
+	count := int(incr.Val())
+	if count != policy.Threshold {
+		return "", count, nil
+	}
+	return policy.Action, count, nil
+}
+
+// Block refuses the client's executions for the configured block duration.
+func Block(ctx context.Context, client string) error {
+	duration := time.Duration(Rules().BlockDuration)
+	if err := db.RedisClient.Set(ctx, blockPrefix+client, time.Now().Format(time.RFC3339), duration).Err(); err != nil {
+		return fmt.Errorf("failed to block %s: %w", client, err)
+	}
+	return nil
+}
+
+// Blocked reports how much longer the client is blocked, or 0 if it is not.
+func Blocked(ctx context.Context, client string) (time.Duration, error) {
+	ttl, err := db.RedisClient.PTTL(ctx, blockPrefix+client).Result()
+	if err == redis.Nil || ttl < 0 {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check block of %s: %w", client, err)
+	}
+	return ttl, nil
+}
+
+// Unblock lifts a block early and resets the client's signal count.
+func Unblock(ctx context.Context, client string) error {
+	if err := db.RedisClient.Del(ctx, blockPrefix+client, signalPrefix+client).Err(); err != nil {
+		return fmt.Errorf("failed to unblock %s: %w", client, err)
+	}
+	return nil
+}
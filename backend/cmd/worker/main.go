@@ -0,0 +1,106 @@
+// Command worker runs queued executions. It needs the Docker socket and
+// Redis but no database credentials; the API server records the results.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"code-editor/config"
+	"code-editor/db"
+	"code-editor/jobs"
+	"code-editor/sandbox"
+)
+
+func main() {
+	config.LoadEnv()
+
+	sandbox.StartReaper(5 * time.Minute)
+	if err := sandbox.LoadLanguages(); err != nil {
+		log.Fatalf("Failed to load sandbox languages: %v", err)
+	}
+	if err := sandbox.VerifyRuntimes(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	if err := sandbox.StartWasm(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	if err := sandbox.StartEgress(); err != nil {
+		log.Fatalf("Failed to enable network egress: %v", err)
+	}
+
+	// Only languages that pass their self-test are advertised, so the API
+	// never routes jobs to a worker that cannot run them
+	languages, err := capabilities(sandbox.CheckLanguages(os.Getenv("SANDBOX_BUILD_IMAGES") == "true"), os.Getenv("WORKER_LANGUAGES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	concurrency := 2
+	if v := os.Getenv("WORKER_CONCURRENCY"); v != "" {
+		concurrency, err = strconv.Atoi(v)
+		if err != nil || concurrency < 1 {
+			log.Fatal("WORKER_CONCURRENCY must be a positive number")
+		}
+	}
+
+	name := os.Getenv("WORKER_NAME")
+	if name == "" {
+		host, _ := os.Hostname()
+		name = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	db.ConnectRedis()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	worker := &jobs.Worker{Name: name, Languages: languages, Concurrency: concurrency}
+	if err := worker.Run(ctx); err != nil {
+		log.Fatalf("Worker stopped: %v", err)
+	}
+	log.Printf("Worker %s stopped", name)
+}
+
+// capabilities returns the available languages, limited to the comma
+// separated list in wanted when it is set.
+func capabilities(statuses []sandbox.LanguageStatus, wanted string) ([]string, error) {
+	var available []string
+	for _, st := range statuses {
+		if st.Available {
+			available = append(available, st.Name)
+		} else {
+			log.Printf("Language %s is unavailable: %s", st.Name, st.Reason)
+		}
+	}
+	if wanted == "" {
+		if len(available) == 0 {
+			return nil, fmt.Errorf("no language is available")
+		}
+		return available, nil
+	}
+
+	var languages []string
+	for _, name := range strings.Split(wanted, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(available, name) {
+			return nil, fmt.Errorf("WORKER_LANGUAGES lists %s, which is unknown or failed its self-test", name)
+		}
+		languages = append(languages, name)
+	}
+	if len(languages) == 0 {
+		return nil, fmt.Errorf("WORKER_LANGUAGES lists no language")
+	}
+	return languages, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"code-editor/config"
+	"code-editor/db"
+	"code-editor/models"
+	"code-editor/replay"
+	"code-editor/sandbox"
+)
+
+// runSubcommand runs a maintenance command instead of the API server.
+func runSubcommand(name string, args []string) {
+	switch name {
+	case "images":
+		imagesCommand(args)
+	case "replay-export":
+		replayExportCommand(args)
+	case "replay":
+		replayCommand(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\nUsage:\n  code-editor                  start the API server\n  code-editor images           check language images and run self-tests\n  code-editor replay-export    export sampled executions as a replay dataset\n  code-editor replay           replay a dataset against a candidate configuration\n", name)
+		os.Exit(2)
+	}
+}
+
+// imagesCommand checks every language image, optionally building missing
+// ones, and exits non-zero when any language fails its self-test.
+func imagesCommand(args []string) {
+	fs := flag.NewFlagSet("images", flag.ExitOnError)
+	build := fs.Bool("build", false, "build custom images from their Dockerfiles and pull missing stock images")
+	fs.Parse(args)
+
+	config.LoadEnv()
+	if err := sandbox.LoadLanguages(); err != nil {
+		log.Fatalf("Failed to load sandbox languages: %v", err)
+	}
+	if err := sandbox.VerifyRuntimes(); err != nil {
+		log.Fatalf("Refusing to run self-tests: %v", err)
+	}
+
+	failed := 0
+	for _, st := range sandbox.CheckLanguages(*build) {
+		if st.Available {
+			fmt.Printf("%-12s %-28s ok\n", st.Name, st.Image)
+		} else {
+			fmt.Printf("%-12s %-28s FAILED: %s\n", st.Name, st.Image, st.Reason)
+			failed++
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// replayExportCommand writes sampled executions from MongoDB to a dataset
+// file for the replay command.
+func replayExportCommand(args []string) {
+	fs := flag.NewFlagSet("replay-export", flag.ExitOnError)
+	out := fs.String("out", "samples.jsonl", "dataset file to write")
+	language := fs.String("language", "", "only export samples of this language")
+	since := fs.String("since", "", "only export samples taken on or after this date (YYYY-MM-DD)")
+	limit := fs.Int("limit", 0, "export at most this many samples, picked at random")
+	fs.Parse(args)
+
+	filter := replay.ExportFilter{Language: *language, Limit: *limit}
+	if *since != "" {
+		t, err := time.Parse("2006-01-02", *since)
+		if err != nil {
+			log.Fatalf("Invalid -since date: %v", err)
+		}
+		filter.Since = t
+	}
+
+	config.LoadEnv()
+	client := db.ConnectDB()
+	defer client.Disconnect(context.Background())
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Failed to create dataset: %v", err)
+	}
+	n, err := replay.Export(context.Background(), client.Database("code_editor_db").Collection("execution_samples"), filter, f)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		log.Fatalf("Failed to export samples: %v", err)
+	}
+	fmt.Printf("Exported %d samples to %s\n", n, *out)
+}
+
+// imageFlags collects repeated -image language=image flags.
+type imageFlags map[string]string
+
+func (f imageFlags) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f imageFlags) Set(value string) error {
+	language, image, ok := strings.Cut(value, "=")
+	if !ok || language == "" || image == "" {
+		return fmt.Errorf("want language=image")
+	}
+	f[language] = image
+	return nil
+}
+
+// replayCommand runs a dataset from replay-export against a candidate
+// language configuration and reports the samples whose results changed. It
+// needs only Docker, so it runs offline. The exit status is 1 when any
+// sample changed.
+func replayCommand(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	in := fs.String("in", "samples.jsonl", "dataset file written by replay-export")
+	languagesFile := fs.String("languages", "", "languages file with the candidate configuration, in the format of SANDBOX_LANGUAGES_FILE")
+	images := imageFlags{}
+	fs.Var(images, "image", "candidate image for a language as language=image; repeatable")
+	language := fs.String("language", "", "only replay samples of this language")
+	concurrency := fs.Int("concurrency", 2, "executions to run at once")
+	control := fs.Bool("control", false, "also replay under the current configuration and report samples that differ there as unstable")
+	reportFile := fs.String("report", "", "write the full report as JSON to this file")
+	fs.Parse(args)
+
+	config.LoadEnv()
+	if err := sandbox.LoadLanguages(); err != nil {
+		log.Fatalf("Failed to load sandbox languages: %v", err)
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("Failed to open dataset: %v", err)
+	}
+	all, err := replay.Load(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Failed to read dataset: %v", err)
+	}
+	var samples []models.ExecutionSample
+	for _, s := range all {
+		if *language == "" || s.Language == *language {
+			samples = append(samples, s)
+		}
+	}
+	if len(samples) == 0 {
+		log.Fatalf("No samples to replay in %s", *in)
+	}
+
+	progress := func(phase string) func(int) {
+		return func(done int) {
+			if done%10 == 0 || done == len(samples) {
+				log.Printf("%s: %d/%d samples", phase, done, len(samples))
+			}
+		}
+	}
+
+	var controlResults []*sandbox.Result
+	if *control {
+		if err := sandbox.VerifyRuntimes(); err != nil {
+			log.Fatalf("Refusing to replay: %v", err)
+		}
+		controlResults = replay.Run(samples, *concurrency, progress("control"))
+	}
+
+	if *languagesFile != "" {
+		data, err := os.ReadFile(*languagesFile)
+		if err != nil {
+			log.Fatalf("Failed to read candidate languages: %v", err)
+		}
+		if err := sandbox.OverrideLanguages(data); err != nil {
+			log.Fatalf("Invalid candidate languages: %v", err)
+		}
+	}
+	if len(images) > 0 {
+		var overrides []map[string]string
+		for name, image := range images {
+			overrides = append(overrides, map[string]string{"name": name, "image": image})
+		}
+		data, _ := json.Marshal(overrides)
+		if err := sandbox.OverrideLanguages(data); err != nil {
+			log.Fatalf("Invalid -image: %v", err)
+		}
+	}
+	if err := sandbox.VerifyRuntimes(); err != nil {
+		log.Fatalf("Refusing to replay: %v", err)
+	}
+	candidateResults := replay.Run(samples, *concurrency, progress("candidate"))
+
+	report := replay.Compare(samples, controlResults, candidateResults)
+	for _, c := range report.Changes {
+		fmt.Printf("%-10s %s %-9s %s/%d -> %s/%d", c.Language, c.ExecutionID, c.Kind, c.RecordedStatus, c.RecordedExitCode, c.Status, c.ExitCode)
+		if c.OutputDiff != "" {
+			fmt.Printf("  %s", c.OutputDiff)
+		}
+		if c.Error != "" {
+			fmt.Printf("  error: %s", c.Error)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("\n%-10s %7s %9s %7s %8s\n", "LANGUAGE", "TOTAL", "UNCHANGED", "CHANGED", "UNSTABLE")
+	names := make([]string, 0, len(report.Languages))
+	for name := range report.Languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := report.Languages[name]
+		fmt.Printf("%-10s %7d %9d %7d %8d\n", name, c.Total, c.Unchanged, c.Changed, c.Unstable)
+	}
+
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*reportFile, data, 0o644)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	}
+	if report.Changed > 0 {
+		os.Exit(1)
+	}
+}
@@ -9,15 +9,24 @@ import (
 var JWTSecret []byte
 
 func LoadConfig() {
-	// Load .env file
-	err := godotenv.Load(".env") // Load .env from the current directory
-	if err != nil {
-		log.Println("Error loading .env file, ensure it's present in the backend directory.")
-	}
+	LoadEnv()
 
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		log.Fatal("JWT_SECRET environment variable not set. Please set it in your .env file.")
 	}
 	JWTSecret = []byte(secret)
+
+	if err := LoadRateLimits(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// LoadEnv loads the .env file without validating any settings. Subcommands
+// that do not serve the API use it instead of LoadConfig.
+func LoadEnv() {
+	err := godotenv.Load(".env") // Load .env from the current directory
+	if err != nil {
+		log.Println("Error loading .env file, ensure it's present in the backend directory.")
+	}
 }
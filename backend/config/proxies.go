@@ -0,0 +1,20 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// TrustedProxies lists the reverse proxies, as IPs or CIDRs from the comma
+// separated TRUSTED_PROXIES, whose X-Forwarded-For header is believed. It is
+// nil when unset, so the client IP is always the peer address; otherwise
+// any client could pick its own IP and with it a fresh rate limit bucket.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RateLimit caps how much execution capacity one client may use. Zero means
+// unlimited.
+type RateLimit struct {
+	ExecutionsPerMinute int     `json:"executionsPerMinute"`
+	CPUSecondsPerDay    float64 `json:"cpuSecondsPerDay"`
+}
+
+// Rate limit roles. Logged-in users without a role use RoleLimitUser and
+// anonymous clients use RoleLimitAnonymous; any other role name is looked up
+// as stored on the user document (e.g. "admin", "trusted").
+const (
+	RoleLimitAnonymous = "anonymous"
+	RoleLimitUser      = "user"
+)
+
+// RateLimits holds the limits per role, loaded by LoadRateLimits.
+var RateLimits = map[string]RateLimit{
+	RoleLimitAnonymous: {ExecutionsPerMinute: 5, CPUSecondsPerDay: 60},
+	RoleLimitUser:      {ExecutionsPerMinute: 20, CPUSecondsPerDay: 600},
+	"trusted":          {ExecutionsPerMinute: 60, CPUSecondsPerDay: 3600},
+	"admin":            {},
+}
+
+// LoadRateLimits merges the RATE_LIMITS environment variable into the
+// defaults. It holds a JSON object keyed by role, for example
+// {"user": {"executionsPerMinute": 30, "cpuSecondsPerDay": 900}}.
+func LoadRateLimits() error {
+	raw := os.Getenv("RATE_LIMITS")
+	if raw == "" {
+		return nil
+	}
+	var overrides map[string]RateLimit
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return fmt.Errorf("invalid RATE_LIMITS: %w", err)
+	}
+	for role, limit := range overrides {
+		if limit.ExecutionsPerMinute < 0 || limit.CPUSecondsPerDay < 0 {
+			return fmt.Errorf("invalid RATE_LIMITS: negative limit for role %q", role)
+		}
+		RateLimits[role] = limit
+	}
+	return nil
+}
+
+// RateLimitFor returns the limits of a role, falling back to the regular user
+// limits for roles that are not configured.
+func RateLimitFor(role string) RateLimit {
+	if limit, ok := RateLimits[role]; ok {
+		return limit
+	}
+	return RateLimits[RoleLimitUser]
+}
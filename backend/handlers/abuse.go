@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code-editor/abuse"
+	"code-editor/models"
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// screen refuses executions by blocked clients and of code that matches a
+// reject or block abuse rule, recording an incident for every match. It
+// assigns the request an ID so that incidents point at the execution.
+// Redis errors fail open.
+func (h *ExecuteHandler) screen(ctx context.Context, req *sandbox.Request) error {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	if remaining, err := abuse.Blocked(ctx, req.Owner); err != nil {
+		log.Printf("Abuse block check failed for %s: %v", req.Owner, err)
+	} else if remaining > 0 {
+		return abuse.ErrBlocked
+	}
+
+	matches := abuse.Screen(*req)
+	if len(matches) == 0 {
+		return nil
+	}
+	worst := matches[0]
+	blocked := false
+	if worst.Action == abuse.ActionBlock {
+		if err := abuse.Block(ctx, req.Owner); err != nil {
+			log.Printf("Failed to block %s: %v", req.Owner, err)
+		} else {
+			blocked = true
+		}
+	}
+
+	hash := sha256.Sum256([]byte(req.Code))
+	for _, m := range matches {
+		log.Printf("Execution %s by %s matched abuse rule %s (%s)", req.ID, req.Owner, m.RuleID, m.Action)
+		h.recordIncident(models.Incident{
+			Owner:       req.Owner,
+			Kind:        "rule",
+			RuleID:      m.RuleID,
+			Action:      m.Action,
+			Blocked:     blocked && m.Action == abuse.ActionBlock,
+			Language:    req.Language,
+			ExecutionID: req.ID,
+			CodeHash:    hex.EncodeToString(hash[:]),
+			File:        m.File,
+			Line:        m.Line,
+			Excerpt:     m.Excerpt,
+			Detail:      m.Description,
+		})
+	}
+	if worst.Action == abuse.ActionFlag {
+		return nil
+	}
+	return &abuse.RejectedError{Match: worst}
+}
+
+// observe counts a finished run that exhausted its sandbox towards the
+// owner's signal policy and records an incident, blocking the owner if the
+// policy says so, once the threshold is reached.
+func (h *ExecuteHandler) observe(owner, language, codeHash string, result *sandbox.Result) {
+	signal := abuse.SignalOf(result)
+	if signal == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	action, count, err := abuse.CountSignal(ctx, owner)
+	if err != nil {
+		log.Printf("Failed to count %s signal of %s: %v", signal, owner, err)
+		return
+	}
+	if action == "" {
+		return
+	}
+
+	incident := models.Incident{
+		Owner:       owner,
+		Kind:        "signal",
+		Signal:      signal,
+		Action:      action,
+		Language:    language,
+		ExecutionID: result.ID,
+		CodeHash:    codeHash,
+		Detail:      fmt.Sprintf("%d runs timed out, ran out of memory or hit the process limit within %s", count, time.Duration(abuse.Rules().Signals.Window)),
+	}
+	if action == abuse.ActionBlock {
+		if err := abuse.Block(ctx, owner); err != nil {
+			log.Printf("Failed to block %s: %v", owner, err)
+		} else {
+			incident.Blocked = true
+		}
+	}
+	log.Printf("%s reached the abuse signal threshold with execution %s (%s)", owner, result.ID, signal)
+	h.recordIncident(incident)
+}
+
+// recordIncident opens an incident, or counts a repeat on the open one for
+// the same owner, rule or signal and code.
+func (h *ExecuteHandler) recordIncident(incident models.Incident) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"owner": incident.Owner, "kind": incident.Kind, "codeHash": incident.CodeHash, "status": models.IncidentOpen}
+	if incident.RuleID != "" {
+		filter["ruleId"] = incident.RuleID
+	}
+	if incident.Signal != "" {
+		filter["signal"] = incident.Signal
+	}
+	now := time.Now()
+	set := bson.M{"lastSeen": now, "executionId": incident.ExecutionID, "action": incident.Action}
+	onInsert := bson.M{"language": incident.Language, "detail": incident.Detail, "createdAt": now}
+	if incident.File != "" {
+		onInsert["file"], onInsert["line"], onInsert["excerpt"] = incident.File, incident.Line, incident.Excerpt
+	}
+	// A repeat that blocked the owner marks the incident blocked
+	if incident.Blocked {
+		set["blocked"] = true
+	} else {
+		onInsert["blocked"] = false
+	}
+	_, err := h.IncidentsCollection.UpdateOne(ctx, filter, bson.M{
+		"$set":         set,
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": onInsert,
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("Failed to record abuse incident for %s: %v", incident.Owner, err)
+	}
+}
+
+// GetIncidents lists abuse incidents for review, newest first. The status
+// filter defaults to open incidents; "all" lists every one.
+func (h *ExecuteHandler) GetIncidents(c *gin.Context) {
+	filter := bson.M{}
+	if status := c.DefaultQuery("status", models.IncidentOpen); status != "all" {
+		filter["status"] = status
+	}
+	if owner := c.Query("owner"); owner != "" {
+		filter["owner"] = owner
+	}
+	if kind := c.Query("kind"); kind != "" {
+		filter["kind"] = kind
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit)
+	cursor, err := h.IncidentsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve incidents"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	incidents := []models.Incident{}
+	if err = cursor.All(ctx, &incidents); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode incidents"})
+		return
+	}
+	c.JSON(http.StatusOK, incidents)
+}
+
+// ResolveIncident closes an incident with an optional note and, if asked,
+// lifts the owner's block.
+func (h *ExecuteHandler) ResolveIncident(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid incident ID"})
+		return
+	}
+	var body struct {
+		Note    string `json:"note"`
+		Unblock bool   `json:"unblock"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	admin, _ := requester(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var incident models.Incident
+	err = h.IncidentsCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":     models.IncidentResolved,
+		"resolvedAt": now,
+		"resolvedBy": admin,
+		"note":       body.Note,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&incident)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve incident"})
+		return
+	}
+
+	if body.Unblock {
+		if err := abuse.Unblock(c.Request.Context(), incident.Owner); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Incident resolved but failed to unblock its owner"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, incident)
+}
+
+// Unblock lifts the block of a client, given as an email or "ip:<addr>".
+func (h *ExecuteHandler) Unblock(c *gin.Context) {
+	client := c.Param("owner")
+	if err := abuse.Unblock(c.Request.Context(), client); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unblocked " + client})
+}
+
+// GetAbuseRules shows the abuse rules in force and when they were loaded.
+func (h *ExecuteHandler) GetAbuseRules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"config": abuse.Rules(), "loadedAt": abuse.LoadedAt()})
+}
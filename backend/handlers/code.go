@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"code-editor/models"
+	"code-editor/sandbox"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -29,6 +30,10 @@ func (h *CodeHandler) SaveCode(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := sandbox.ValidateArgsEnv(req.Args, req.Env); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Get email from the context
 	email, exists := c.Request.Context().Value("userEmail").(string)
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"code-editor/middleware"
+	"code-editor/models"
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// DebugHandler manages debug sessions and bridges Debug Adapter Protocol
+// traffic between the editor and the sandboxed debug adapter, one DAP
+// message per WebSocket text message.
+type DebugHandler struct {
+	upgrader websocket.Upgrader
+}
+
+func NewDebugHandler(allowedOrigins []string) *DebugHandler {
+	return &DebugHandler{upgrader: newUpgrader(allowedOrigins)}
+}
+
+// StartSession builds the submitted code in a new debug session.
+func (h *DebugHandler) StartSession(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req models.CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	session, err := sandbox.StartDebugger(email, req.Language, req.Code, req.Files)
+	var buildErr *sandbox.BuildError
+	switch {
+	case err == nil:
+		c.Set(middleware.CPUTimeKey, session.UnchargedCPU().Milliseconds())
+		c.JSON(http.StatusCreated, gin.H{"sessionId": session.ID})
+	case errors.As(err, &buildErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":       "Build failed",
+			"output":      buildErr.Output,
+			"diagnostics": buildErr.Diagnostics,
+		})
+	case errors.Is(err, sandbox.ErrUnsupportedLanguage), errors.Is(err, sandbox.ErrNoDebugger), errors.Is(err, sandbox.ErrInvalidFiles):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sandbox.ErrSessionLimit):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many open debug sessions"})
+	default:
+		log.Printf("Failed to start debugger for %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start debugger"})
+	}
+}
+
+// Connect upgrades to a WebSocket carrying one DAP connection to the
+// session's adapter. It may be called again for child sessions.
+func (h *DebugHandler) Connect(c *gin.Context) {
+	session, ok := h.findSession(c)
+	if !ok {
+		return
+	}
+
+	attachment, err := sandbox.ConnectDebugger(session)
+	if err != nil {
+		log.Printf("Failed to connect to debug session %s: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to debugger"})
+		return
+	}
+	defer attachment.Close()
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // The upgrader already replied
+	}
+	defer conn.Close()
+
+	proxyRPC(conn, session, attachment.Stdin, attachment.Stdout)
+
+	// The program ran while the editor was connected; anything after this
+	// is charged when the session closes
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	middleware.ChargeCPU(ctx, session.Owner, session.UnchargedCPU().Milliseconds())
+}
+
+// StopSession ends a debug session and its container.
+func (h *DebugHandler) StopSession(c *gin.Context) {
+	session, ok := h.findSession(c)
+	if !ok {
+		return
+	}
+	session.Close()
+	c.JSON(http.StatusOK, gin.H{"message": "Debug session stopped"})
+}
+
+func (h *DebugHandler) findSession(c *gin.Context) (*sandbox.Session, bool) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	role, _ := c.Request.Context().Value("userRole").(string)
+
+	session, err := sandbox.FindSession(c.Param("id"), "debug", email, role == models.RoleAdmin)
+	switch err {
+	case nil:
+		return session, true
+	case sandbox.ErrNotSessionOwner:
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only use your own debug sessions"})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Debug session not found or already ended"})
+	}
+	return nil, false
+}
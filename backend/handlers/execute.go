@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"code-editor/jobs"
+	"code-editor/middleware"
+	"code-editor/models"
+	"code-editor/replay"
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExecuteHandler struct {
+	ExecutionsCollection *mongo.Collection
+	ArtifactsCollection  *mongo.Collection
+	SamplesCollection    *mongo.Collection
+	IncidentsCollection  *mongo.Collection
+}
+
+func NewExecuteHandler(executionsCollection, artifactsCollection, samplesCollection, incidentsCollection *mongo.Collection) *ExecuteHandler {
+	return &ExecuteHandler{
+		ExecutionsCollection: executionsCollection,
+		ArtifactsCollection:  artifactsCollection,
+		SamplesCollection:    samplesCollection,
+		IncidentsCollection:  incidentsCollection,
+	}
+}
+
+// requester identifies the caller: the logged-in user's email, or the client
+// IP for anonymous requests, which owns their executions and profiles. The IP
+// comes from X-Forwarded-For only behind TRUSTED_PROXIES, so it cannot be
+// picked by the client. The second value reports admin rights.
+func requester(c *gin.Context) (string, bool) {
+	if email, ok := c.Request.Context().Value("userEmail").(string); ok {
+		role, _ := c.Request.Context().Value("userRole").(string)
+		return email, role == models.RoleAdmin
+	}
+	return "ip:" + c.ClientIP(), false
+}
+
+// egressAllowed reports whether the user's role is one of
+// SANDBOX_EGRESS_ROLES (default admin and trusted), which may give their
+// runs network access. Anonymous clients never may.
+func egressAllowed(c *gin.Context) bool {
+	if _, ok := c.Request.Context().Value("userEmail").(string); !ok {
+		return false
+	}
+	role, _ := c.Request.Context().Value("userRole").(string)
+	return egressRole(role)
+}
+
+func egressRole(role string) bool {
+	roles := os.Getenv("SANDBOX_EGRESS_ROLES")
+	if roles == "" {
+		roles = models.RoleAdmin + ",trusted"
+	}
+	for _, r := range strings.Split(roles, ",") {
+		if strings.TrimSpace(r) == role && role != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *ExecuteHandler) Execute(c *gin.Context) {
+	req, ok := h.executionRequest(c)
+	if !ok {
+		return
+	}
+	if jobs.Enabled() {
+		h.executeQueued(c, req)
+		return
+	}
+
+	result := sandbox.Execute(req)
+	log.Printf("Execution %s (%s) finished with status %s", result.ID, req.Language, result.Status)
+	h.recordExecution(req, result)
+	if result.Profile != nil && result.Profile.Raw != nil {
+		h.storeProfile(req.Owner, result)
+	}
+	if result.Usage != nil {
+		c.Set(middleware.CPUTimeKey, result.Usage.CPUTimeMs)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// executionRequest reads, checks and screens an execution request, answering
+// the client itself when it is invalid or refused.
+func (h *ExecuteHandler) executionRequest(c *gin.Context) (sandbox.Request, bool) {
+	req, data, err := bindExecution(c)
+	if errors.Is(err, errUploadTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return sandbox.Request{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return sandbox.Request{}, false
+	}
+	if req.ExecutionID != "" {
+		if _, err := uuid.Parse(req.ExecutionID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "executionId must be a UUID"})
+			return sandbox.Request{}, false
+		}
+	}
+
+	owner, _ := requester(c)
+	if req.Network && !egressAllowed(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Network access is not enabled for your account"})
+		return sandbox.Request{}, false
+	}
+	sreq := sandbox.Request{
+		ID:       req.ExecutionID,
+		Owner:    owner,
+		Language: req.Language,
+		Code:     req.Code,
+		Input:    req.Input,
+		Mode:     req.Mode,
+		Files:    req.Files,
+		Data:     data,
+		Coverage: req.Coverage,
+		Runs:     req.Runs,
+		Warmup:   req.Warmup,
+		Baseline: req.Baseline,
+		Args:     req.Args,
+		Env:      req.Env,
+		Network:  req.Network,
+	}
+	if err := h.screen(c.Request.Context(), &sreq); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return sandbox.Request{}, false
+	}
+	return sreq, true
+}
+
+// recordExecution stores the audit record of a run. Failures are only logged
+// so that a database hiccup never hides the program's output.
+func (h *ExecuteHandler) recordExecution(req sandbox.Request, result *sandbox.Result) {
+	hash := sha256.Sum256([]byte(req.Code))
+	record := newExecutionRecord(req.Owner, req.Language, req.Mode, hex.EncodeToString(hash[:]), len(req.Input), result)
+	if err := h.insertExecution(record); err != nil {
+		log.Printf("Failed to record execution %s: %v", result.ID, err)
+	}
+	h.observe(req.Owner, req.Language, record.CodeHash, result)
+	if sample := replay.Sample(req, result); sample != nil {
+		h.insertSample(sample)
+	}
+}
+
+func newExecutionRecord(owner, language, mode, codeHash string, inputSize int, result *sandbox.Result) models.Execution {
+	record := models.Execution{
+		ExecutionID: result.ID,
+		Language:    language,
+		Mode:        mode,
+		Runtime:     result.Runtime,
+		CodeHash:    codeHash,
+		InputSize:   inputSize,
+		Status:      result.Status,
+		ExitCode:    result.ExitCode,
+		StartedAt:   result.StartedAt,
+		CreatedAt:   time.Now(),
+	}
+	if ip, ok := strings.CutPrefix(owner, "ip:"); ok {
+		record.ClientIP = ip
+	} else {
+		record.Email = owner
+	}
+	if result.Egress != nil {
+		for _, r := range result.Egress.Requests {
+			record.Egress = append(record.Egress, models.EgressRequest(r))
+		}
+	}
+	if result.Usage != nil {
+		record.WallTimeMs = result.Usage.WallTimeMs
+		record.CPUTimeMs = result.Usage.CPUTimeMs
+		record.PeakMemoryKB = result.Usage.PeakMemoryKB
+	}
+	return record
+}
+
+func (h *ExecuteHandler) insertExecution(record models.Execution) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := h.ExecutionsCollection.InsertOne(ctx, record)
+	return err
+}
+
+// insertSample keeps a run for replaying; see package replay.
+func (h *ExecuteHandler) insertSample(sample *models.ExecutionSample) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.SamplesCollection.InsertOne(ctx, sample); err != nil {
+		log.Printf("Failed to store sample of execution %s: %v", sample.ExecutionID, err)
+	}
+}
+
+// storeProfile keeps the raw profile for download and points the result at
+// it. Without a stored copy the response simply has no artifact link.
+func (h *ExecuteHandler) storeProfile(owner string, result *sandbox.Result) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := h.ArtifactsCollection.InsertOne(ctx, models.Artifact{
+		ExecutionID: result.ID,
+		Kind:        "profile",
+		Owner:       owner,
+		Filename:    result.Profile.Filename,
+		Data:        result.Profile.Raw,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to store profile of execution %s: %v", result.ID, err)
+		return
+	}
+	result.Profile.Artifact = "/executions/" + result.ID + "/profile"
+}
+
+// GetProfile downloads the raw profile of a profiled execution. Anonymous
+// profiles belong to the client IP, see requester.
+func (h *ExecuteHandler) GetProfile(c *gin.Context) {
+	owner, admin := requester(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var artifact models.Artifact
+	err := h.ArtifactsCollection.FindOne(ctx, bson.M{"executionId": c.Param("id"), "kind": "profile"}).Decode(&artifact)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found or expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profile"})
+		return
+	}
+	if artifact.Owner != owner && !admin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only download your own profiles"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+artifact.Filename+`"`)
+	c.Data(http.StatusOK, "application/octet-stream", artifact.Data)
+}
+
+func (h *ExecuteHandler) CancelExecution(c *gin.Context) {
+	id := c.Param("id")
+	owner, admin := requester(c)
+
+	var err error
+	if jobs.Enabled() {
+		err = jobs.Cancel(c.Request.Context(), id, owner, admin)
+	} else {
+		err = sandbox.Cancel(id, owner, admin)
+	}
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"executionId": id, "status": sandbox.StatusCancelled})
+	case sandbox.ErrExecutionNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Execution not found or already finished"})
+	case sandbox.ErrNotOwner:
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only cancel your own executions"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel execution"})
+	}
+}
+
+// GetExecutions lists the logged-in user's run history, newest first.
+func (h *ExecuteHandler) GetExecutions(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	h.listExecutions(c, bson.M{"email": email})
+}
+
+// GetAllExecutions is the admin view across users, optionally filtered by the
+// email and language query parameters.
+func (h *ExecuteHandler) GetAllExecutions(c *gin.Context) {
+	filter := bson.M{}
+	if email := c.Query("email"); email != "" {
+		filter["email"] = email
+	}
+	if language := c.Query("language"); language != "" {
+		filter["language"] = language
+	}
+	h.listExecutions(c, filter)
+}
+
+func (h *ExecuteHandler) listExecutions(c *gin.Context, filter bson.M) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	skip, err := strconv.ParseInt(c.DefaultQuery("skip", "0"), 10, 64)
+	if err != nil || skip < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "skip must be a non-negative number"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit).SetSkip(skip)
+	cursor, err := h.ExecutionsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve executions"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	executions := []models.Execution{}
+	if err = cursor.All(ctx, &executions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode executions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, executions)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code-editor/config"
+	"code-editor/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequester(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name      string
+		proxies   string
+		peer      string
+		forwarded string
+		email     string
+		role      string
+		want      string
+		wantAdmin bool
+	}{
+		{name: "anonymous", peer: "203.0.113.7:4000", want: "ip:203.0.113.7"},
+		{name: "forwarded header ignored without trusted proxies", peer: "203.0.113.7:4000", forwarded: "198.51.100.1", want: "ip:203.0.113.7"},
+		{name: "forwarded header from a trusted proxy", proxies: "10.0.0.0/8", peer: "10.1.2.3:4000", forwarded: "198.51.100.1", want: "ip:198.51.100.1"},
+		{name: "forwarded header from another peer", proxies: "10.0.0.0/8", peer: "203.0.113.7:4000", forwarded: "198.51.100.1", want: "ip:203.0.113.7"},
+		{name: "logged in", peer: "203.0.113.7:4000", forwarded: "198.51.100.1", email: "a@example.com", want: "a@example.com"},
+		{name: "admin", peer: "203.0.113.7:4000", email: "b@example.com", role: models.RoleAdmin, want: "b@example.com", wantAdmin: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.proxies)
+			router := gin.New()
+			if err := router.SetTrustedProxies(config.TrustedProxies()); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			var admin bool
+			router.GET("/", func(c *gin.Context) { got, admin = requester(c) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.email != "" {
+				ctx := context.WithValue(req.Context(), "userEmail", tt.email)
+				req = req.WithContext(context.WithValue(ctx, "userRole", tt.role))
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want || admin != tt.wantAdmin {
+				t.Errorf("requester() = %s, %v; want %s, %v", got, admin, tt.want, tt.wantAdmin)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"code-editor/jobs"
+	"code-editor/middleware"
+	codeexecv1 "code-editor/proto/codeexec/v1"
+	"code-editor/sandbox"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// MaxGRPCMessageSize admits requests as large as the biggest multipart
+// upload POST /execute accepts.
+const MaxGRPCMessageSize = maxRequestPart + sandbox.MaxInputSize + sandbox.MaxTotalDataSize + 1<<20
+
+// ExecutionService implements the gRPC API on top of the same sandbox,
+// queue and recording code as POST /execute. Callers are authenticated by
+// middleware.GRPCAuth.
+type ExecutionService struct {
+	codeexecv1.UnimplementedExecutionServiceServer
+	exec *ExecuteHandler
+}
+
+func NewExecutionService(executeHandler *ExecuteHandler) *ExecutionService {
+	return &ExecutionService{exec: executeHandler}
+}
+
+func (s *ExecutionService) Execute(ctx context.Context, req *codeexecv1.ExecuteRequest) (*codeexecv1.ExecuteResponse, error) {
+	sreq, err := grpcRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.run(ctx, sreq)
+	if err != nil {
+		return nil, err
+	}
+	return executeResponse(result, true), nil
+}
+
+func (s *ExecutionService) ExecuteStream(req *codeexecv1.ExecuteRequest, stream grpc.ServerStreamingServer[codeexecv1.ExecuteStreamResponse]) error {
+	sreq, err := grpcRequest(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	// Queued executions run elsewhere, so their output arrives in one piece
+	if !jobs.Enabled() {
+		sreq.Output = &outputStream{stream: stream}
+	}
+	result, err := s.run(stream.Context(), sreq)
+	if err != nil {
+		return err
+	}
+	if jobs.Enabled() && result.Output != "" {
+		if err := stream.Send(outputEvent([]byte(result.Output))); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&codeexecv1.ExecuteStreamResponse{
+		Event: &codeexecv1.ExecuteStreamResponse_Result{Result: executeResponse(result, false)},
+	})
+}
+
+func (s *ExecutionService) ListLanguages(ctx context.Context, req *codeexecv1.ListLanguagesRequest) (*codeexecv1.ListLanguagesResponse, error) {
+	resp := &codeexecv1.ListLanguagesResponse{}
+	for _, st := range sandbox.LanguageStatuses() {
+		resp.Languages = append(resp.Languages, &codeexecv1.Language{
+			Name:      st.Name,
+			Image:     st.Image,
+			Backend:   st.Backend,
+			Available: st.Available,
+			Reason:    st.Reason,
+		})
+	}
+	return resp, nil
+}
+
+// grpcRequest checks a request like executionRequest does for HTTP and
+// applies the caller's rate limits.
+func grpcRequest(ctx context.Context, req *codeexecv1.ExecuteRequest) (sandbox.Request, error) {
+	owner, _ := ctx.Value("userEmail").(string)
+	role, _ := ctx.Value("userRole").(string)
+
+	id := req.GetExecutionId()
+	if id == "" {
+		id = uuid.New().String()
+	} else if _, err := uuid.Parse(id); err != nil {
+		return sandbox.Request{}, status.Error(codes.InvalidArgument, "execution_id must be a UUID")
+	}
+	if req.GetNetwork() && !egressRole(role) {
+		return sandbox.Request{}, status.Error(codes.PermissionDenied, "network access is not enabled for your account")
+	}
+	if err := middleware.CheckRateLimit(ctx, owner, role); err != nil {
+		return sandbox.Request{}, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	var data map[string]string
+	if len(req.GetData()) > 0 {
+		data = make(map[string]string, len(req.GetData()))
+		for name, content := range req.GetData() {
+			data[name] = string(content)
+		}
+	}
+	return sandbox.Request{
+		ID:       id,
+		Owner:    owner,
+		Language: req.GetLanguage(),
+		Code:     req.GetCode(),
+		Input:    req.GetInput(),
+		Mode:     req.GetMode(),
+		Files:    req.GetFiles(),
+		Data:     data,
+		Coverage: req.GetCoverage(),
+		Runs:     int(req.GetRuns()),
+		Warmup:   int(req.GetWarmup()),
+		Baseline: req.GetBaseline(),
+		Args:     req.GetArgs(),
+		Env:      req.GetEnv(),
+		Network:  req.GetNetwork(),
+	}, nil
+}
+
+// run screens the request and executes it locally or through the workers,
+// cancelling it if the caller goes away first.
+func (s *ExecutionService) run(ctx context.Context, req sandbox.Request) (*sandbox.Result, error) {
+	if err := s.exec.screen(ctx, &req); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if jobs.Enabled() {
+				jobs.Cancel(context.Background(), req.ID, req.Owner, true)
+			} else {
+				sandbox.Cancel(req.ID, req.Owner, true)
+			}
+		case <-finished:
+		}
+	}()
+
+	if jobs.Enabled() {
+		_, err := jobs.Submit(ctx, req)
+		switch {
+		case errors.Is(err, jobs.ErrNoWorker):
+			return nil, status.Errorf(codes.Unavailable, "no worker is available for %s", req.Language)
+		case errors.Is(err, jobs.ErrQueueFull):
+			return nil, status.Errorf(codes.ResourceExhausted, "too many queued executions for %s", req.Language)
+		case errors.Is(err, jobs.ErrDuplicateJob):
+			return nil, status.Error(codes.AlreadyExists, "execution_id is already in use")
+		case err != nil:
+			log.Printf("Failed to queue execution %s: %v", req.ID, err)
+			return nil, status.Error(codes.Internal, "failed to queue the execution")
+		}
+		st, err := jobs.Wait(ctx, req.ID, syncWait)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to wait for the execution")
+		}
+		if st.Status != jobs.StatusDone || st.Result == nil {
+			return nil, status.Errorf(codes.DeadlineExceeded, "execution %s is still %s, poll /jobs/%s", req.ID, st.Status, req.ID)
+		}
+		return st.Result, nil
+	}
+
+	result := sandbox.Execute(req)
+	log.Printf("Execution %s (%s) over gRPC finished with status %s", result.ID, req.Language, result.Status)
+	s.exec.recordExecution(req, result)
+	if result.Profile != nil && result.Profile.Raw != nil {
+		s.exec.storeProfile(req.Owner, result)
+	}
+	if result.Usage != nil {
+		middleware.ChargeCPU(context.Background(), req.Owner, result.Usage.CPUTimeMs)
+	}
+	return result, nil
+}
+
+// executeResponse converts a result, leaving out the output when it was
+// already streamed.
+func executeResponse(result *sandbox.Result, withOutput bool) *codeexecv1.ExecuteResponse {
+	full := *result
+	if !withOutput {
+		full.Output = ""
+	}
+	resultJSON, err := json.Marshal(&full)
+	if err != nil {
+		log.Printf("Failed to encode result of execution %s: %v", result.ID, err)
+	}
+
+	resp := &codeexecv1.ExecuteResponse{
+		ExecutionId: result.ID,
+		Status:      result.Status,
+		Output:      full.Output,
+		Error:       result.Error,
+		ExitCode:    int32(result.ExitCode),
+		Runtime:     result.Runtime,
+		ResultJson:  string(resultJSON),
+	}
+	if !result.StartedAt.IsZero() {
+		resp.StartedAt = timestamppb.New(result.StartedAt)
+	}
+	if result.Usage != nil {
+		resp.Usage = &codeexecv1.Usage{
+			WallTimeMs:   result.Usage.WallTimeMs,
+			CpuTimeMs:    result.Usage.CPUTimeMs,
+			PeakMemoryKb: result.Usage.PeakMemoryKB,
+		}
+	}
+	for _, d := range result.Diagnostics {
+		resp.Diagnostics = append(resp.Diagnostics, &codeexecv1.Diagnostic{
+			File:     d.File,
+			Line:     int32(d.Line),
+			Column:   int32(d.Column),
+			Severity: d.Severity,
+			Rule:     d.Rule,
+			Message:  d.Message,
+		})
+	}
+	return resp
+}
+
+func outputEvent(p []byte) *codeexecv1.ExecuteStreamResponse {
+	return &codeexecv1.ExecuteStreamResponse{Event: &codeexecv1.ExecuteStreamResponse_Output{Output: p}}
+}
+
+// outputStream sends the program's output to the client as it arrives. The
+// sandbox writes from one goroutine at a time.
+type outputStream struct {
+	stream grpc.ServerStreamingServer[codeexecv1.ExecuteStreamResponse]
+}
+
+func (o *outputStream) Write(p []byte) (int, error) {
+	if err := o.stream.Send(outputEvent(bytes.Clone(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"code-editor/models"
+	"code-editor/sandbox"
+
+	"github.com/google/uuid"
+)
+
+// ipynb is the subset of the Jupyter notebook format (nbformat 4) that maps
+// onto models.Notebook.
+type ipynb struct {
+	NBFormat      int           `json:"nbformat"`
+	NBFormatMinor int           `json:"nbformat_minor"`
+	Metadata      ipynbMetadata `json:"metadata"`
+	Cells         []ipynbCell   `json:"cells"`
+}
+
+type ipynbMetadata struct {
+	KernelSpec *struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+		Language    string `json:"language"`
+	} `json:"kernelspec,omitempty"`
+	LanguageInfo *struct {
+		Name string `json:"name"`
+	} `json:"language_info,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+type ipynbCell struct {
+	CellType       string         `json:"cell_type"`
+	ID             string         `json:"id,omitempty"`
+	Metadata       map[string]any `json:"metadata"`
+	Source         ipynbText      `json:"source"`
+	ExecutionCount *int           `json:"execution_count,omitempty"`
+	Outputs        []ipynbOutput  `json:"outputs,omitempty"`
+}
+
+// ipynbOutput is one cell output. Data holds every MIME bundle untouched;
+// only text/plain is decoded, since images and HTML need not be text.
+type ipynbOutput struct {
+	OutputType     string                     `json:"output_type"`
+	Name           string                     `json:"name,omitempty"` // stream: stdout or stderr
+	Text           ipynbText                  `json:"text,omitempty"`
+	Data           map[string]json.RawMessage `json:"data,omitempty"`
+	Metadata       map[string]any             `json:"metadata,omitempty"`
+	ExecutionCount *int                       `json:"execution_count,omitempty"`
+	EName          string                     `json:"ename,omitempty"`
+	EValue         string                     `json:"evalue,omitempty"`
+	Traceback      []string                   `json:"traceback,omitempty"`
+}
+
+// MarshalJSON writes the keys nbformat requires for the cell type: code
+// cells always carry execution_count and outputs, markdown cells neither.
+func (c ipynbCell) MarshalJSON() ([]byte, error) {
+	m := map[string]any{
+		"cell_type": c.CellType,
+		"metadata":  c.Metadata,
+		"source":    c.Source,
+	}
+	if c.ID != "" {
+		m["id"] = c.ID
+	}
+	if c.CellType == "code" {
+		m["execution_count"] = c.ExecutionCount
+		m["outputs"] = c.Outputs
+	}
+	return json.Marshal(m)
+}
+
+// MarshalJSON writes the metadata and execution_count of execute_result
+// outputs even when they are empty or null, as nbformat requires.
+func (o ipynbOutput) MarshalJSON() ([]byte, error) {
+	type plain ipynbOutput
+	if o.OutputType != "execute_result" {
+		return json.Marshal(plain(o))
+	}
+	if o.Metadata == nil {
+		o.Metadata = map[string]any{}
+	}
+	return json.Marshal(struct {
+		plain
+		Metadata       map[string]any `json:"metadata"`
+		ExecutionCount *int           `json:"execution_count"`
+	}{plain(o), o.Metadata, o.ExecutionCount})
+}
+
+// ipynbText is multiline text, stored either as one string or as a list of
+// lines that keep their newlines.
+type ipynbText string
+
+func (t *ipynbText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = ipynbText(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*t = ipynbText(strings.Join(lines, ""))
+	return nil
+}
+
+func (t ipynbText) MarshalJSON() ([]byte, error) {
+	lines := strings.SplitAfter(string(t), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if lines == nil {
+		lines = []string{}
+	}
+	return json.Marshal(lines)
+}
+
+// Kernel languages as Jupyter names them, mapped to sandbox languages.
+var ipynbLanguages = map[string]string{
+	"python":     "python",
+	"python3":    "python",
+	"javascript": "javascript",
+	"go":         "go",
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// notebookFromIpynb converts an uploaded .ipynb document. Raw cells become
+// markdown cells; outputs are kept as the cells' last output.
+func notebookFromIpynb(data []byte) (*models.Notebook, error) {
+	var doc ipynb
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %w", err)
+	}
+	if doc.NBFormat != 4 {
+		return nil, fmt.Errorf("unsupported nbformat %d, only version 4 is supported", doc.NBFormat)
+	}
+
+	language := "python"
+	switch {
+	case doc.Metadata.KernelSpec != nil && doc.Metadata.KernelSpec.Language != "":
+		language = doc.Metadata.KernelSpec.Language
+	case doc.Metadata.LanguageInfo != nil && doc.Metadata.LanguageInfo.Name != "":
+		language = doc.Metadata.LanguageInfo.Name
+	}
+	if mapped, ok := ipynbLanguages[strings.ToLower(language)]; ok {
+		language = mapped
+	}
+
+	nb := &models.Notebook{Title: doc.Metadata.Title, Language: language, Cells: []models.Cell{}}
+	for _, c := range doc.Cells {
+		cell := models.Cell{ID: c.ID, Type: models.CellMarkdown, Source: string(c.Source)}
+		if cell.ID == "" {
+			cell.ID = uuid.New().String()
+		}
+		if c.CellType == "code" {
+			cell.Type = models.CellCode
+			if c.ExecutionCount != nil {
+				cell.ExecutionCount = *c.ExecutionCount
+				nb.ExecutionCount = max(nb.ExecutionCount, cell.ExecutionCount)
+			}
+			cell.Output = outputFromIpynb(c.Outputs)
+		}
+		nb.Cells = append(nb.Cells, cell)
+	}
+	return nb, nil
+}
+
+func outputFromIpynb(outputs []ipynbOutput) *models.CellOutput {
+	if len(outputs) == 0 {
+		return nil
+	}
+	out := &models.CellOutput{Status: sandbox.StatusSuccess}
+	for _, o := range outputs {
+		switch o.OutputType {
+		case "stream":
+			out.Output += string(o.Text)
+		case "execute_result", "display_data":
+			var text ipynbText
+			if raw, ok := o.Data["text/plain"]; ok && json.Unmarshal(raw, &text) == nil {
+				out.Result = string(text)
+			}
+		case "error":
+			out.Status = sandbox.StatusError
+			out.Error = ansiEscape.ReplaceAllString(strings.Join(o.Traceback, "\n"), "")
+			if out.Error == "" {
+				out.Error = o.EName + ": " + o.EValue
+			}
+		}
+	}
+	return out
+}
+
+// notebookToIpynb converts a notebook to nbformat 4.
+func notebookToIpynb(nb *models.Notebook) ([]byte, error) {
+	doc := ipynb{NBFormat: 4, NBFormatMinor: 5, Cells: []ipynbCell{}}
+	doc.Metadata.Title = nb.Title
+	doc.Metadata.KernelSpec = &struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+		Language    string `json:"language"`
+	}{Name: nb.Language, DisplayName: nb.Language, Language: nb.Language}
+	doc.Metadata.LanguageInfo = &struct {
+		Name string `json:"name"`
+	}{Name: nb.Language}
+
+	for _, cell := range nb.Cells {
+		c := ipynbCell{CellType: "markdown", ID: cell.ID, Metadata: map[string]any{}, Source: ipynbText(cell.Source)}
+		if cell.Type == models.CellCode {
+			c.CellType = "code"
+			c.Outputs = []ipynbOutput{}
+			if cell.ExecutionCount > 0 {
+				count := cell.ExecutionCount
+				c.ExecutionCount = &count
+			}
+			if out := cell.Output; out != nil {
+				if out.Output != "" {
+					c.Outputs = append(c.Outputs, ipynbOutput{OutputType: "stream", Name: "stdout", Text: ipynbText(out.Output)})
+				}
+				if out.Result != "" {
+					text, err := json.Marshal(ipynbText(out.Result))
+					if err != nil {
+						return nil, err
+					}
+					c.Outputs = append(c.Outputs, ipynbOutput{
+						OutputType:     "execute_result",
+						ExecutionCount: c.ExecutionCount,
+						Data:           map[string]json.RawMessage{"text/plain": text},
+						Metadata:       map[string]any{},
+					})
+				}
+				if out.Error != "" {
+					// The last traceback line reads "Name: message" in Python
+					// and JavaScript alike
+					lines := strings.Split(strings.TrimRight(out.Error, "\n"), "\n")
+					ename, evalue, ok := strings.Cut(lines[len(lines)-1], ": ")
+					if !ok {
+						ename, evalue = "Error", lines[len(lines)-1]
+					}
+					c.Outputs = append(c.Outputs, ipynbOutput{
+						OutputType: "error",
+						EName:      ename,
+						EValue:     evalue,
+						Traceback:  lines,
+					})
+				}
+			}
+		}
+		doc.Cells = append(doc.Cells, c)
+	}
+	return json.MarshalIndent(doc, "", " ")
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code-editor/models"
+	"code-editor/sandbox"
+)
+
+func TestNotebookFromIpynb(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		language string
+		cells    []models.Cell
+		wantErr  string
+	}{
+		{
+			name: "code and markdown",
+			doc: `{"nbformat": 4, "nbformat_minor": 5, "metadata": {"kernelspec": {"name": "python3", "language": "python"}},
+				"cells": [
+					{"cell_type": "markdown", "id": "a", "metadata": {}, "source": ["# Title\n", "text"]},
+					{"cell_type": "code", "id": "b", "metadata": {}, "source": "print(1)\n1", "execution_count": 3,
+					 "outputs": [
+						{"output_type": "stream", "name": "stdout", "text": ["1\n"]},
+						{"output_type": "execute_result", "execution_count": 3, "metadata": {}, "data": {"text/plain": ["1"]}}
+					 ]}
+				]}`,
+			language: "python",
+			cells: []models.Cell{
+				{ID: "a", Type: models.CellMarkdown, Source: "# Title\ntext"},
+				{ID: "b", Type: models.CellCode, Source: "print(1)\n1", ExecutionCount: 3,
+					Output: &models.CellOutput{Status: sandbox.StatusSuccess, Output: "1\n", Result: "1"}},
+			},
+		},
+		{
+			name: "rich outputs are skipped, not rejected",
+			doc: `{"nbformat": 4, "metadata": {"language_info": {"name": "javascript"}},
+				"cells": [{"cell_type": "code", "id": "c", "metadata": {}, "source": "x", "execution_count": null,
+					"outputs": [{"output_type": "display_data", "metadata": {}, "data": {
+						"image/png": "iVBORw0KGgo=",
+						"application/json": {"a": [1, 2]},
+						"text/plain": "<Figure>"
+					}}]}]}`,
+			language: "javascript",
+			cells: []models.Cell{
+				{ID: "c", Type: models.CellCode, Source: "x",
+					Output: &models.CellOutput{Status: sandbox.StatusSuccess, Result: "<Figure>"}},
+			},
+		},
+		{
+			name: "malformed text/plain is ignored",
+			doc: `{"nbformat": 4, "metadata": {},
+				"cells": [{"cell_type": "code", "id": "d", "metadata": {}, "source": "", "outputs": [
+					{"output_type": "execute_result", "metadata": {}, "data": {"text/plain": {"not": "text"}}}
+				]}]}`,
+			language: "python",
+			cells: []models.Cell{
+				{ID: "d", Type: models.CellCode, Output: &models.CellOutput{Status: sandbox.StatusSuccess}},
+			},
+		},
+		{
+			name: "error output strips ANSI colours",
+			doc: `{"nbformat": 4, "metadata": {},
+				"cells": [{"cell_type": "code", "id": "e", "metadata": {}, "source": "1/0", "outputs": [
+					{"output_type": "error", "ename": "ZeroDivisionError", "evalue": "division by zero",
+					 "traceback": ["\u001b[0;31mTraceback\u001b[0m", "ZeroDivisionError: division by zero"]}
+				]}]}`,
+			language: "python",
+			cells: []models.Cell{
+				{ID: "e", Type: models.CellCode, Source: "1/0", Output: &models.CellOutput{
+					Status: sandbox.StatusError, Error: "Traceback\nZeroDivisionError: division by zero"}},
+			},
+		},
+		{
+			name:    "old nbformat",
+			doc:     `{"nbformat": 3, "metadata": {}, "cells": []}`,
+			wantErr: "unsupported nbformat 3",
+		},
+		{
+			name:    "source of the wrong type",
+			doc:     `{"nbformat": 4, "metadata": {}, "cells": [{"cell_type": "code", "source": 42}]}`,
+			wantErr: "invalid notebook",
+		},
+		{
+			name:    "not JSON",
+			doc:     `<html>`,
+			wantErr: "invalid notebook",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nb, err := notebookFromIpynb([]byte(tt.doc))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("notebookFromIpynb() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("notebookFromIpynb() error = %v", err)
+			}
+			if nb.Language != tt.language {
+				t.Errorf("Language = %q, want %q", nb.Language, tt.language)
+			}
+			if !reflect.DeepEqual(nb.Cells, tt.cells) {
+				t.Errorf("Cells = %+v, want %+v", nb.Cells, tt.cells)
+			}
+		})
+	}
+}
+
+func TestNotebookToIpynb(t *testing.T) {
+	nb := &models.Notebook{
+		Title:    "Demo",
+		Language: "python",
+		Cells: []models.Cell{
+			{ID: "a", Type: models.CellMarkdown, Source: "# Demo\n"},
+			{ID: "b", Type: models.CellCode, Source: "x = 1\nx", ExecutionCount: 2,
+				Output: &models.CellOutput{Status: sandbox.StatusSuccess, Output: "hi\n", Result: "1"}},
+			{ID: "c", Type: models.CellCode, Source: "y",
+				Output: &models.CellOutput{Status: sandbox.StatusSuccess, Result: "2"}},
+			{ID: "d", Type: models.CellCode, Source: "1/0", ExecutionCount: 3,
+				Output: &models.CellOutput{Status: sandbox.StatusError, Error: "Traceback\nZeroDivisionError: division by zero"}},
+		},
+	}
+	data, err := notebookToIpynb(nb)
+	if err != nil {
+		t.Fatalf("notebookToIpynb() error = %v", err)
+	}
+
+	var doc struct {
+		NBFormat int `json:"nbformat"`
+		Cells    []map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("exported notebook is not JSON: %v", err)
+	}
+	if doc.NBFormat != 4 || len(doc.Cells) != 4 {
+		t.Fatalf("got nbformat %d with %d cells, want 4 and 4", doc.NBFormat, len(doc.Cells))
+	}
+
+	tests := []struct {
+		name    string
+		cell    int
+		outputs string
+	}{
+		{"markdown has no outputs", 0, ""},
+		{"stream and result", 1, `[{"output_type":"stream","name":"stdout","text":["hi\n"]},{"output_type":"execute_result","data":{"text/plain":["1"]},"metadata":{},"execution_count":2}]`},
+		{"result of an unnumbered cell keeps a null count", 2, `[{"output_type":"execute_result","data":{"text/plain":["2"]},"metadata":{},"execution_count":null}]`},
+		{"error", 3, `[{"output_type":"error","ename":"ZeroDivisionError","evalue":"division by zero","traceback":["Traceback","ZeroDivisionError: division by zero"]}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cell := doc.Cells[tt.cell]
+			outputs, ok := cell["outputs"]
+			if tt.outputs == "" {
+				if ok {
+					t.Errorf("outputs = %s, want none", outputs)
+				}
+				return
+			}
+			if _, ok := cell["execution_count"]; !ok {
+				t.Errorf("code cell has no execution_count")
+			}
+			var got, want any
+			json.Unmarshal(outputs, &got)
+			json.Unmarshal([]byte(tt.outputs), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("outputs = %s, want %s", outputs, tt.outputs)
+			}
+		})
+	}
+
+	// Exporting and importing again keeps the cells
+	back, err := notebookFromIpynb(data)
+	if err != nil {
+		t.Fatalf("re-importing the export failed: %v", err)
+	}
+	if !reflect.DeepEqual(back.Cells, nb.Cells) {
+		t.Errorf("round trip cells = %+v, want %+v", back.Cells, nb.Cells)
+	}
+}
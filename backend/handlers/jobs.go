@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"code-editor/jobs"
+	"code-editor/middleware"
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// syncWait is how long POST /execute waits for a queued execution before
+// answering 202 with the job's status, to be polled at /jobs/:id.
+const syncWait = 3 * time.Minute
+
+// executeQueued hands the execution to the workers and waits for its result.
+func (h *ExecuteHandler) executeQueued(c *gin.Context, req sandbox.Request) {
+	if _, ok := submitJob(c, &req); !ok {
+		return
+	}
+	st, err := jobs.Wait(c.Request.Context(), req.ID, syncWait)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to wait for the execution"})
+		return
+	}
+	if st.Status != jobs.StatusDone || st.Result == nil {
+		c.JSON(http.StatusAccepted, st)
+		return
+	}
+	c.JSON(http.StatusOK, st.Result)
+}
+
+// submitJob queues the request, giving it an ID first if it has none, and
+// answers the client itself on failure.
+func submitJob(c *gin.Context, req *sandbox.Request) (*jobs.Status, bool) {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	st, err := jobs.Submit(c.Request.Context(), *req)
+	switch {
+	case errors.Is(err, jobs.ErrNoWorker):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No worker is available for " + req.Language})
+		return nil, false
+	case errors.Is(err, jobs.ErrQueueFull):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many queued executions for " + req.Language + ", try again later"})
+		return nil, false
+	case errors.Is(err, jobs.ErrDuplicateJob):
+		c.JSON(http.StatusConflict, gin.H{"error": "executionId is already in use"})
+		return nil, false
+	case err != nil:
+		log.Printf("Failed to queue execution %s: %v", req.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue the execution"})
+		return nil, false
+	}
+	return st, true
+}
+
+// SubmitJob queues an execution and returns without waiting for it. It takes
+// the same JSON or multipart body as POST /execute.
+func (h *ExecuteHandler) SubmitJob(c *gin.Context) {
+	req, ok := h.executionRequest(c)
+	if !ok {
+		return
+	}
+	st, ok := submitJob(c, &req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusAccepted, st)
+}
+
+// GetJob reports a queued execution's status and, once done, its result.
+func (h *ExecuteHandler) GetJob(c *gin.Context) {
+	owner, admin := requester(c)
+
+	st, err := jobs.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, sandbox.ErrExecutionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found or expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job"})
+		return
+	}
+	if st.Owner != owner && !admin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// GetWorkers lists the live workers and the languages they run.
+func (h *ExecuteHandler) GetWorkers(c *gin.Context) {
+	workers, err := jobs.Workers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve workers"})
+		return
+	}
+	c.JSON(http.StatusOK, workers)
+}
+
+// RecordJobs stores the execution records and profiles of jobs finished by
+// the workers and charges their CPU time, which the workers cannot do as
+// they have no database access. It runs until ctx is done.
+func (h *ExecuteHandler) RecordJobs(ctx context.Context) {
+	consumer, err := os.Hostname()
+	if err != nil || consumer == "" {
+		consumer = "code-editor"
+	}
+	err = jobs.ConsumeResults(ctx, consumer, func(o *jobs.Outcome) error {
+		result := o.Result
+		log.Printf("Execution %s (%s) finished on %s with status %s", result.ID, o.Language, o.Worker, result.Status)
+		if err := h.insertExecution(newExecutionRecord(o.Owner, o.Language, o.Mode, o.CodeHash, o.InputSize, result)); err != nil {
+			return err
+		}
+		h.observe(o.Owner, o.Language, o.CodeHash, result)
+		if o.Sample != nil {
+			h.insertSample(o.Sample)
+		}
+		if o.Profile != nil && result.Profile != nil {
+			result.Profile.Raw = o.Profile
+			result.Profile.Filename = o.ProfileFilename
+			h.storeProfile(o.Owner, result)
+		}
+		if result.Usage != nil {
+			middleware.ChargeCPU(ctx, o.Owner, result.Usage.CPUTimeMs)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Stopped recording jobs: %v", err)
+	}
+}
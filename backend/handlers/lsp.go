@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// maxClientMessage bounds a single JSON-RPC message sent by the browser.
+const maxClientMessage = 4 << 20
+
+// LSPHandler proxies Language Server Protocol traffic between the editor and
+// a sandboxed language server. Each WebSocket text message carries exactly
+// one JSON-RPC message without the Content-Length header.
+type LSPHandler struct {
+	upgrader websocket.Upgrader
+}
+
+func NewLSPHandler(allowedOrigins []string) *LSPHandler {
+	return &LSPHandler{upgrader: newUpgrader(allowedOrigins)}
+}
+
+// newUpgrader accepts WebSocket handshakes from the same origins as CORS.
+func newUpgrader(allowedOrigins []string) websocket.Upgrader {
+	return websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			for _, allowed := range allowedOrigins {
+				if origin == allowed {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+func (h *LSPHandler) Connect(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Start the server before upgrading so failures are plain HTTP errors
+	session, err := sandbox.StartLanguageServer(email, c.Param("language"))
+	switch {
+	case err == nil:
+	case errors.Is(err, sandbox.ErrUnsupportedLanguage), errors.Is(err, sandbox.ErrNoLanguageServer):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, sandbox.ErrSessionLimit):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many open language server sessions"})
+		return
+	default:
+		log.Printf("Failed to start language server for %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start language server"})
+		return
+	}
+	defer session.Close()
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // The upgrader already replied
+	}
+	defer conn.Close()
+
+	proxyRPC(conn, session, session.Stdin(), session.Stdout())
+}
+
+// proxyRPC shuttles Content-Length framed JSON-RPC messages between a
+// process in the session (its main process or an attachment) and the
+// WebSocket until either side goes away.
+func proxyRPC(conn *websocket.Conn, session *sandbox.Session, stdin io.Writer, stdout io.Reader) {
+	conn.SetReadLimit(maxClientMessage)
+
+	// Session to client
+	go func() {
+		defer conn.Close()
+		r := bufio.NewReader(stdout)
+		for {
+			msg, err := sandbox.ReadRPCMessage(r)
+			if err != nil {
+				return
+			}
+			session.Touch()
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		}
+	}()
+	// An idle or expired session closes the connection too
+	go func() {
+		<-session.Done()
+		conn.Close()
+	}()
+
+	// Client to session
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		session.Touch()
+		if err := sandbox.WriteRPCMessage(stdin, msg); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"code-editor/middleware"
+	"code-editor/models"
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxNotebookSize bounds uploaded .ipynb files.
+const maxNotebookSize = 5 << 20
+
+// Cell ids end up in .ipynb files, which restrict them to this pattern.
+var cellIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._ -]+`)
+
+// NotebookHandler stores notebooks and runs their code cells. Each notebook
+// gets its own REPL kernel on first use, so state carries over between cells
+// until the kernel is restarted or times out.
+type NotebookHandler struct {
+	NotebooksCollection *mongo.Collection
+
+	kernelsMu sync.Mutex
+	kernels   map[string]string // Notebook ID to REPL ID
+}
+
+func NewNotebookHandler(notebooksCollection *mongo.Collection) *NotebookHandler {
+	return &NotebookHandler{
+		NotebooksCollection: notebooksCollection,
+		kernels:             map[string]string{},
+	}
+}
+
+func (h *NotebookHandler) CreateNotebook(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req struct {
+		Title    string        `json:"title"`
+		Language string        `json:"language" binding:"required"`
+		Cells    []models.Cell `json:"cells"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	cells, ok := normalizeCells(c, req.Cells)
+	if !ok {
+		return
+	}
+
+	h.insertNotebook(c, &models.Notebook{Email: email, Title: req.Title, Language: req.Language, Cells: cells})
+}
+
+// ImportNotebook creates a notebook from an .ipynb file, sent either as the
+// "file" field of a multipart form or as the raw request body.
+func (h *NotebookHandler) ImportNotebook(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxNotebookSize)
+	var body io.Reader = c.Request.Body
+	if file, _, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Notebook is too large"})
+		return
+	}
+
+	nb, err := notebookFromIpynb(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cells, ok := normalizeCells(c, nb.Cells)
+	if !ok {
+		return
+	}
+	nb.Cells = cells
+	nb.Email = email
+	if nb.Title == "" {
+		nb.Title = "Imported notebook"
+	}
+	h.insertNotebook(c, nb)
+}
+
+// insertNotebook saves a new notebook, refusing languages its cells could
+// never run in.
+func (h *NotebookHandler) insertNotebook(c *gin.Context, nb *models.Notebook) {
+	if err := sandbox.CheckReplLanguage(nb.Language); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	nb.CreatedAt = time.Now()
+	nb.UpdatedAt = nb.CreatedAt
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := h.NotebooksCollection.InsertOne(ctx, nb)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save notebook"})
+		return
+	}
+	nb.ID = res.InsertedID.(primitive.ObjectID)
+	c.JSON(http.StatusCreated, nb)
+}
+
+// GetNotebooks lists the user's notebooks without their cells.
+func (h *NotebookHandler) GetNotebooks(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetProjection(bson.M{"cells": 0}).SetSort(bson.D{{Key: "updatedAt", Value: -1}})
+	cursor, err := h.NotebooksCollection.Find(ctx, bson.M{"email": email}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notebooks"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	notebooks := []models.Notebook{}
+	if err = cursor.All(ctx, &notebooks); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode notebooks"})
+		return
+	}
+	c.JSON(http.StatusOK, notebooks)
+}
+
+func (h *NotebookHandler) GetNotebook(c *gin.Context) {
+	nb, ok := h.findNotebook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, nb)
+}
+
+// ExportNotebook downloads the notebook in .ipynb format.
+func (h *NotebookHandler) ExportNotebook(c *gin.Context) {
+	nb, ok := h.findNotebook(c)
+	if !ok {
+		return
+	}
+	data, err := notebookToIpynb(nb)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export notebook"})
+		return
+	}
+	filename := unsafeFilename.ReplaceAllString(nb.Title, "_")
+	if filename == "" {
+		filename = "notebook"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ipynb"`, filename))
+	c.Data(http.StatusOK, "application/x-ipynb+json", data)
+}
+
+// UpdateNotebook replaces the title and cells. Outputs sent with the cells
+// are stored as they are.
+func (h *NotebookHandler) UpdateNotebook(c *gin.Context) {
+	nb, ok := h.findNotebook(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Title string        `json:"title"`
+		Cells []models.Cell `json:"cells"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	cells, ok := normalizeCells(c, req.Cells)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	nb.Title, nb.Cells, nb.UpdatedAt = req.Title, cells, time.Now()
+	_, err := h.NotebooksCollection.UpdateOne(ctx, bson.M{"_id": nb.ID}, bson.M{"$set": bson.M{
+		"title":     nb.Title,
+		"cells":     nb.Cells,
+		"updatedAt": nb.UpdatedAt,
+	}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notebook"})
+		return
+	}
+	c.JSON(http.StatusOK, nb)
+}
+
+func (h *NotebookHandler) DeleteNotebook(c *gin.Context) {
+	nb, ok := h.findNotebook(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.NotebooksCollection.DeleteOne(ctx, bson.M{"_id": nb.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notebook"})
+		return
+	}
+	h.shutdownKernel(c, nb)
+	c.JSON(http.StatusOK, gin.H{"message": "Notebook deleted successfully"})
+}
+
+// ExecuteCell runs a code cell in the notebook's kernel and stores its
+// output with the notebook.
+func (h *NotebookHandler) ExecuteCell(c *gin.Context) {
+	nb, ok := h.findNotebook(c)
+	if !ok {
+		return
+	}
+	cellID := c.Param("cellId")
+	var cell *models.Cell
+	for i := range nb.Cells {
+		if nb.Cells[i].ID == cellID {
+			cell = &nb.Cells[i]
+		}
+	}
+	if cell == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cell not found"})
+		return
+	}
+	if cell.Type != models.CellCode {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only code cells can be executed"})
+		return
+	}
+
+	kernel, err := h.kernel(c, nb)
+	switch {
+	case err == nil:
+	case errors.Is(err, sandbox.ErrUnsupportedLanguage), errors.Is(err, sandbox.ErrNoRepl):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, sandbox.ErrSessionLimit):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many open kernels"})
+		return
+	default:
+		log.Printf("Failed to start kernel for notebook %s: %v", nb.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start kernel"})
+		return
+	}
+
+	result, err := kernel.Eval(cell.Source)
+	if err != nil {
+		log.Printf("Kernel of notebook %s failed: %v", nb.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kernel failed, restart it to continue"})
+		return
+	}
+	c.Set(middleware.CPUTimeKey, result.CPUTimeMs)
+	cell.Output = &models.CellOutput{Status: result.Status, Output: result.Output, ExecutedAt: time.Now()}
+	if result.Result != nil {
+		cell.Output.Result = *result.Result
+	}
+	if result.Error != nil {
+		cell.Output.Error = *result.Error
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Number the run, then store the output on the cell by id so that
+	// concurrent edits to other cells are kept
+	var counter struct {
+		ExecutionCount int `bson:"executionCount"`
+	}
+	err = h.NotebooksCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": nb.ID},
+		bson.M{"$inc": bson.M{"executionCount": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"executionCount": 1}),
+	).Decode(&counter)
+	if err == nil {
+		cell.ExecutionCount = counter.ExecutionCount
+		_, err = h.NotebooksCollection.UpdateOne(ctx,
+			bson.M{"_id": nb.ID},
+			bson.M{"$set": bson.M{
+				"cells.$[cell].output":         cell.Output,
+				"cells.$[cell].executionCount": cell.ExecutionCount,
+				"updatedAt":                    time.Now(),
+			}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"cell.id": cell.ID}}}),
+		)
+	}
+	if err != nil {
+		log.Printf("Failed to store output of notebook %s cell %s: %v", nb.ID.Hex(), cell.ID, err)
+	}
+	c.JSON(http.StatusOK, cell)
+}
+
+// RestartKernel discards the notebook's kernel state. Stored outputs stay.
+func (h *NotebookHandler) RestartKernel(c *gin.Context) {
+	nb, ok := h.findNotebook(c)
+	if !ok {
+		return
+	}
+	h.shutdownKernel(c, nb)
+	c.JSON(http.StatusOK, gin.H{"message": "Kernel restarted"})
+}
+
+// kernel returns the notebook's running REPL, starting one if needed.
+func (h *NotebookHandler) kernel(c *gin.Context, nb *models.Notebook) (*sandbox.Repl, error) {
+	email, admin := requester(c)
+	h.kernelsMu.Lock()
+	defer h.kernelsMu.Unlock()
+
+	if id, ok := h.kernels[nb.ID.Hex()]; ok {
+		if repl, err := sandbox.FindRepl(id, email, admin); err == nil {
+			return repl, nil
+		}
+	}
+	repl, err := sandbox.StartRepl(email, nb.Language)
+	if err != nil {
+		return nil, err
+	}
+	h.kernels[nb.ID.Hex()] = repl.ID
+	return repl, nil
+}
+
+func (h *NotebookHandler) shutdownKernel(c *gin.Context, nb *models.Notebook) {
+	email, admin := requester(c)
+	h.kernelsMu.Lock()
+	id, ok := h.kernels[nb.ID.Hex()]
+	delete(h.kernels, nb.ID.Hex())
+	h.kernelsMu.Unlock()
+	if !ok {
+		return
+	}
+	if repl, err := sandbox.FindRepl(id, email, admin); err == nil {
+		repl.Close()
+	}
+}
+
+// findNotebook loads the notebook named by the id parameter if it belongs
+// to the user.
+func (h *NotebookHandler) findNotebook(c *gin.Context) (*models.Notebook, bool) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var nb models.Notebook
+	err = h.NotebooksCollection.FindOne(ctx, bson.M{"_id": objID, "email": email}).Decode(&nb)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notebook"})
+		return nil, false
+	}
+	return &nb, true
+}
+
+// normalizeCells validates cell types and assigns ids to new cells.
+func normalizeCells(c *gin.Context, cells []models.Cell) ([]models.Cell, bool) {
+	seen := map[string]bool{}
+	normalized := make([]models.Cell, 0, len(cells))
+	for _, cell := range cells {
+		if cell.Type != models.CellCode && cell.Type != models.CellMarkdown {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cell type must be code or markdown"})
+			return nil, false
+		}
+		if cell.ID == "" {
+			cell.ID = uuid.New().String()
+		}
+		if !cellIDPattern.MatchString(cell.ID) || seen[cell.ID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cell ids must be unique and alphanumeric"})
+			return nil, false
+		}
+		seen[cell.ID] = true
+		if cell.Type == models.CellMarkdown {
+			cell.Output, cell.ExecutionCount = nil, 0
+		}
+		normalized = append(normalized, cell)
+	}
+	return normalized, true
+}
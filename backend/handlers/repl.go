@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"code-editor/middleware"
+	"code-editor/models"
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReplHandler manages REPL sessions whose interpreter state persists
+// between snippets.
+type ReplHandler struct{}
+
+func NewReplHandler() *ReplHandler {
+	return &ReplHandler{}
+}
+
+func (h *ReplHandler) StartRepl(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req struct {
+		Language string `json:"language" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	repl, err := sandbox.StartRepl(email, req.Language)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, gin.H{"replId": repl.ID, "language": repl.Language})
+	case errors.Is(err, sandbox.ErrUnsupportedLanguage), errors.Is(err, sandbox.ErrNoRepl):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sandbox.ErrSessionLimit):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many open REPL sessions"})
+	default:
+		log.Printf("Failed to start REPL for %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start REPL"})
+	}
+}
+
+func (h *ReplHandler) Eval(c *gin.Context) {
+	repl, ok := findRepl(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	result, err := repl.Eval(req.Code)
+	switch {
+	case err == nil:
+		c.Set(middleware.CPUTimeKey, result.CPUTimeMs)
+		c.JSON(http.StatusOK, result)
+	case errors.Is(err, sandbox.ErrReplNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "REPL not found or already closed"})
+	default:
+		log.Printf("REPL %s failed: %v", repl.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "REPL failed, reset it to continue"})
+	}
+}
+
+// Reset discards the REPL's state by replacing its interpreter.
+func (h *ReplHandler) Reset(c *gin.Context) {
+	repl, ok := findRepl(c)
+	if !ok {
+		return
+	}
+	if err := repl.Reset(); err != nil {
+		log.Printf("Failed to reset REPL %s: %v", repl.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset REPL"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "REPL reset"})
+}
+
+func (h *ReplHandler) CloseRepl(c *gin.Context) {
+	repl, ok := findRepl(c)
+	if !ok {
+		return
+	}
+	repl.Close()
+	c.JSON(http.StatusOK, gin.H{"message": "REPL closed"})
+}
+
+func findRepl(c *gin.Context) (*sandbox.Repl, bool) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	role, _ := c.Request.Context().Value("userRole").(string)
+
+	repl, err := sandbox.FindRepl(c.Param("id"), email, role == models.RoleAdmin)
+	switch err {
+	case nil:
+		return repl, true
+	case sandbox.ErrNotReplOwner:
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only use your own REPLs"})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "REPL not found or already closed"})
+	}
+	return nil, false
+}
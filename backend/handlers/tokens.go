@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"code-editor/middleware"
+	"code-editor/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxTokensPerUser bounds the API tokens one user may hold.
+const maxTokensPerUser = 20
+
+// apiTokenPrefix marks API tokens so that leaked ones are easy to spot.
+const apiTokenPrefix = "cx_"
+
+type TokenHandler struct {
+	TokensCollection *mongo.Collection
+}
+
+func NewTokenHandler(tokensCollection *mongo.Collection) *TokenHandler {
+	return &TokenHandler{TokensCollection: tokensCollection}
+}
+
+// CreateToken issues a new API token for the logged-in user. The token is
+// only ever returned here.
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var req struct {
+		Name string `json:"name" binding:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := h.TokensCollection.CountDocuments(ctx, bson.M{"email": email})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	if count >= maxTokensPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "Token limit reached, revoke an unused token first"})
+		return
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	token := apiTokenPrefix + hex.EncodeToString(secret)
+	record := models.APIToken{
+		Email:     email,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    token[:len(apiTokenPrefix)+6],
+		TokenHash: middleware.HashAPIToken(token),
+		CreatedAt: time.Now(),
+	}
+	result, err := h.TokensCollection.InsertOne(ctx, record)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	record.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, gin.H{"token": token, "apiToken": record})
+}
+
+// GetTokens lists the logged-in user's API tokens without the tokens
+// themselves.
+func (h *TokenHandler) GetTokens(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := h.TokensCollection.Find(ctx, bson.M{"email": email}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tokens"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []models.APIToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// DeleteToken revokes one of the logged-in user's API tokens.
+func (h *TokenHandler) DeleteToken(c *gin.Context) {
+	email, exists := c.Request.Context().Value("userEmail").(string)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.TokensCollection.DeleteOne(ctx, bson.M{"_id": objID, "email": email})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"code-editor/middleware"
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ToolsHandler serves editor helpers that run language tooling in the
+// sandbox rather than executing the user's program.
+type ToolsHandler struct{}
+
+func NewToolsHandler() *ToolsHandler {
+	return &ToolsHandler{}
+}
+
+func (h *ToolsHandler) Format(c *gin.Context) {
+	var req struct {
+		Language string `json:"language" binding:"required"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	owner, _ := requester(c)
+	formatted, usage, err := sandbox.Format(owner, req.Language, req.Code)
+	if usage != nil {
+		c.Set(middleware.CPUTimeKey, usage.CPUTimeMs)
+	}
+	var syntaxErr *sandbox.SyntaxError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"formatted": formatted})
+	case errors.As(err, &syntaxErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  syntaxErr.Message,
+			"line":   syntaxErr.Line,
+			"column": syntaxErr.Column,
+		})
+	case errors.Is(err, sandbox.ErrUnsupportedLanguage), errors.Is(err, sandbox.ErrNoFormatter):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Formatting %s code failed: %v", req.Language, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to format code"})
+	}
+}
+
+func (h *ToolsHandler) Lint(c *gin.Context) {
+	var req struct {
+		Language string `json:"language" binding:"required"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	owner, _ := requester(c)
+	diagnostics, usage, err := sandbox.Lint(owner, req.Language, req.Code)
+	if usage != nil {
+		c.Set(middleware.CPUTimeKey, usage.CPUTimeMs)
+	}
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"diagnostics": diagnostics})
+	case errors.Is(err, sandbox.ErrUnsupportedLanguage), errors.Is(err, sandbox.ErrNoLinter):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Linting %s code failed: %v", req.Language, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lint code"})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"code-editor/models"
+	"code-editor/sandbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRequestPart bounds the JSON "request" part of a multipart execution.
+const maxRequestPart = 2 << 20
+
+var errUploadTooLarge = errors.New("upload too large")
+
+// bindExecution reads an execution request, either as JSON or as a
+// multipart form with the JSON request in a "request" part, stdin as a
+// "stdin" file and data files in "files" parts named by their filename. Size
+// limits are enforced while reading, so oversized uploads are rejected
+// before anything is buffered in full.
+func bindExecution(c *gin.Context) (models.CodeRequest, map[string]string, error) {
+	var req models.CodeRequest
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return req, nil, errors.New("invalid payload")
+		}
+		return req, nil, nil
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestPart+sandbox.MaxInputSize+sandbox.MaxTotalDataSize+1<<20)
+	c.Request.Body = body
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return req, nil, errors.New("invalid multipart form")
+	}
+
+	var sawRequest, sawStdin bool
+	var stdin string
+	data := map[string]string{}
+	total := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return req, nil, errUploadTooLarge
+			}
+			return req, nil, errors.New("invalid multipart form")
+		}
+
+		switch part.FormName() {
+		case "request":
+			content, err := readPart(part, maxRequestPart)
+			if err != nil {
+				return req, nil, err
+			}
+			if err := json.Unmarshal([]byte(content), &req); err != nil {
+				return req, nil, errors.New("invalid request part")
+			}
+			sawRequest = true
+		case "stdin":
+			if sawStdin {
+				return req, nil, errors.New("only one stdin part is allowed")
+			}
+			if stdin, err = readPart(part, sandbox.MaxInputSize); err != nil {
+				return req, nil, err
+			}
+			sawStdin = true
+		case "files":
+			name := part.FileName()
+			if _, dup := data[name]; dup {
+				return req, nil, fmt.Errorf("duplicate file %q", name)
+			}
+			if len(data) >= sandbox.MaxDataFiles {
+				return req, nil, fmt.Errorf("%w: at most %d data files are allowed", errUploadTooLarge, sandbox.MaxDataFiles)
+			}
+			content, err := readPart(part, min(sandbox.MaxDataFileSize, sandbox.MaxTotalDataSize-total))
+			if err != nil {
+				return req, nil, err
+			}
+			data[name] = content
+			total += len(content)
+		default:
+			return req, nil, fmt.Errorf("unexpected form field %q", part.FormName())
+		}
+	}
+	if !sawRequest {
+		return req, nil, errors.New("missing request part")
+	}
+	if sawStdin {
+		if req.Input != "" {
+			return req, nil, errors.New("send input either in the request or as stdin, not both")
+		}
+		req.Input = stdin
+	}
+	return req, data, nil
+}
+
+// readPart reads a whole part, failing with errUploadTooLarge if it has more
+// than limit bytes.
+func readPart(part io.Reader, limit int) (string, error) {
+	content, err := io.ReadAll(io.LimitReader(part, int64(limit)+1))
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		return "", errUploadTooLarge
+	case err != nil:
+		return "", errors.New("invalid multipart form")
+	case len(content) > limit:
+		return "", errUploadTooLarge
+	}
+	return string(content), nil
+}
@@ -0,0 +1,378 @@
+// Package jobs moves executions off the API server. The API enqueues them on
+// Redis streams, one per language, workers (cmd/worker) run them in the
+// sandbox and publish outcomes on a results stream, and the API records
+// those and completes the jobs.
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"code-editor/db"
+	"code-editor/models"
+	"code-editor/sandbox"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis keys and consumer groups.
+const (
+	streamPrefix  = "codeexec:jobs:" // Job stream of one language
+	resultsStream = "codeexec:results"
+	jobPrefix     = "codeexec:job:"    // Hash with a job's state and result
+	donePrefix    = "codeexec:done:"   // List pushed to once a job is done
+	workerPrefix  = "codeexec:worker:" // Heartbeat of a worker
+	cancelChannel = "codeexec:cancel"
+
+	workersGroup   = "workers"
+	recordersGroup = "recorders"
+)
+
+const (
+	jobTTL = time.Hour
+	// Submit refuses jobs while a language's stream holds this many. Streams
+	// are never trimmed, since that would drop unfinished jobs; entries are
+	// deleted once acknowledged instead.
+	maxQueuedJobs = 10000
+	// An outcome left pending this long by a recorder is picked up by another.
+	recordTimeout = time.Minute
+)
+
+// Job statuses.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+)
+
+var (
+	ErrDuplicateJob = errors.New("job ID already in use")
+	ErrNoWorker     = errors.New("no worker available for this language")
+	ErrQueueFull    = errors.New("too many queued jobs for this language")
+)
+
+// Enabled reports whether executions go through the queue, which
+// EXECUTION_QUEUE=redis turns on. Otherwise the API runs them itself.
+func Enabled() bool {
+	return os.Getenv("EXECUTION_QUEUE") == "redis"
+}
+
+// LocalSandbox reports whether the API runs sandbox sessions and tools
+// (REPL, notebooks, debugger, language servers, format and lint) itself.
+// Without the queue it always does; with it only when SANDBOX_ON_API is
+// true, since the API host then need not have the Docker socket.
+func LocalSandbox() bool {
+	return !Enabled() || os.Getenv("SANDBOX_ON_API") == "true"
+}
+
+// Job is an execution request as queued for the workers.
+type Job struct {
+	Request    sandbox.Request `json:"request"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+}
+
+// Outcome is what a worker publishes for a finished job. It carries what the
+// API needs for the execution record but not the code or input themselves.
+type Outcome struct {
+	JobID     string          `json:"jobId"`
+	Worker    string          `json:"worker"`
+	Owner     string          `json:"owner"`
+	Language  string          `json:"language"`
+	Mode      string          `json:"mode,omitempty"`
+	CodeHash  string          `json:"codeHash"`
+	InputSize int             `json:"inputSize"`
+	Result    *sandbox.Result `json:"result"`
+	// Profile is the raw profile, which Result does not serialize.
+	Profile         []byte `json:"profile,omitempty"`
+	ProfileFilename string `json:"profileFilename,omitempty"`
+	// Sample is set for runs picked for replaying, see package replay.
+	Sample *models.ExecutionSample `json:"sample,omitempty"`
+}
+
+// Status is a job's state as reported to clients.
+type Status struct {
+	ID         string          `json:"executionId"`
+	Owner      string          `json:"-"`
+	Language   string          `json:"language"`
+	Status     string          `json:"status"`
+	Worker     string          `json:"worker,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	Result     *sandbox.Result `json:"result,omitempty"`
+}
+
+func streamOf(language string) string {
+	return streamPrefix + language
+}
+
+// Submit queues the request, whose ID must be set, on its language's stream.
+// It fails with ErrNoWorker when no live worker runs the language, since the
+// job would otherwise wait forever, and with ErrQueueFull when the stream
+// already holds maxQueuedJobs.
+func Submit(ctx context.Context, req sandbox.Request) (*Status, error) {
+	workers, err := Workers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !anyRuns(workers, req.Language) {
+		return nil, ErrNoWorker
+	}
+	queued, err := db.RedisClient.XLen(ctx, streamOf(req.Language)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue length: %w", err)
+	}
+	if queued >= maxQueuedJobs {
+		return nil, ErrQueueFull
+	}
+
+	job := Job{Request: req, EnqueuedAt: time.Now()}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	key := jobPrefix + req.ID
+	created, err := db.RedisClient.HSetNX(ctx, key, "status", StatusQueued).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	if !created {
+		return nil, ErrDuplicateJob
+	}
+
+	pipe := db.RedisClient.TxPipeline()
+	pipe.HSet(ctx, key, "owner", req.Owner, "language", req.Language, "enqueuedAt", job.EnqueuedAt.Format(time.RFC3339Nano))
+	pipe.Expire(ctx, key, jobTTL)
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamOf(req.Language),
+		Values: map[string]interface{}{"job": payload},
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		db.RedisClient.Del(ctx, key)
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return &Status{ID: req.ID, Owner: req.Owner, Language: req.Language, Status: StatusQueued, EnqueuedAt: job.EnqueuedAt}, nil
+}
+
+// Get returns the job's current state, or sandbox.ErrExecutionNotFound once
+// it has expired.
+func Get(ctx context.Context, id string) (*Status, error) {
+	fields, err := db.RedisClient.HGetAll(ctx, jobPrefix+id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, sandbox.ErrExecutionNotFound
+	}
+
+	st := &Status{
+		ID:       id,
+		Owner:    fields["owner"],
+		Language: fields["language"],
+		Status:   fields["status"],
+		Worker:   fields["worker"],
+	}
+	st.EnqueuedAt, _ = time.Parse(time.RFC3339Nano, fields["enqueuedAt"])
+	st.StartedAt = parseTime(fields["startedAt"])
+	st.FinishedAt = parseTime(fields["finishedAt"])
+	if result := fields["result"]; result != "" {
+		st.Result = &sandbox.Result{}
+		if err := json.Unmarshal([]byte(result), st.Result); err != nil {
+			return nil, fmt.Errorf("failed to decode job result: %w", err)
+		}
+	}
+	return st, nil
+}
+
+func parseTime(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// Wait blocks until the job is done or timeout passes and returns its state
+// either way.
+func Wait(ctx context.Context, id string, timeout time.Duration) (*Status, error) {
+	err := db.RedisClient.BLPop(ctx, timeout, donePrefix+id).Err()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to wait for job: %w", err)
+	}
+	return Get(ctx, id)
+}
+
+// Cancel stops a queued or running job. Like sandbox.Cancel, only the owner
+// may cancel it unless admin is set. Queued jobs are flagged so that the
+// worker skips them; running ones are stopped by whichever worker has them.
+func Cancel(ctx context.Context, id, requester string, admin bool) error {
+	st, err := Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if st.Status == StatusDone {
+		return sandbox.ErrExecutionNotFound
+	}
+	if !admin && st.Owner != requester {
+		return sandbox.ErrNotOwner
+	}
+
+	pipe := db.RedisClient.TxPipeline()
+	pipe.HSet(ctx, jobPrefix+id, "cancel", "1")
+	pipe.Publish(ctx, cancelChannel, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to cancel job: %w", err)
+	}
+	return nil
+}
+
+// ensureGroup creates a consumer group reading the stream from its start, so
+// that entries added before the first consumer are not lost.
+func ensureGroup(ctx context.Context, stream, group string) error {
+	err := db.RedisClient.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s on %s: %w", group, stream, err)
+	}
+	return nil
+}
+
+// ConsumeResults passes every published outcome to record and then completes
+// the job, waking up a waiting API request. Outcomes that fail to record
+// stay pending and are retried, by this or another API instance, after
+// recordTimeout. It returns when ctx is done.
+func ConsumeResults(ctx context.Context, consumer string, record func(*Outcome) error) error {
+	if err := ensureGroup(ctx, resultsStream, recordersGroup); err != nil {
+		return err
+	}
+
+	lastClaim := time.Time{}
+	for ctx.Err() == nil {
+		var messages []redis.XMessage
+		if time.Since(lastClaim) > recordTimeout/2 {
+			lastClaim = time.Now()
+			claimed, err := claimStale(ctx, consumer)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Failed to claim pending results: %v", err)
+			}
+			messages = claimed
+		}
+
+		streams, err := db.RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    recordersGroup,
+			Consumer: consumer,
+			Streams:  []string{resultsStream, ">"},
+			Count:    10,
+			Block:    5 * time.Second,
+		}).Result()
+		switch {
+		case err == redis.Nil:
+		case err != nil:
+			if ctx.Err() == nil {
+				log.Printf("Failed to read results: %v", err)
+				time.Sleep(time.Second)
+			}
+		default:
+			for _, stream := range streams {
+				messages = append(messages, stream.Messages...)
+			}
+		}
+
+		for _, msg := range messages {
+			recordOutcome(ctx, msg, record)
+		}
+	}
+	return nil
+}
+
+// claimStale takes over outcomes that another recorder left pending for
+// recordTimeout. XAUTOCLAIM would do this in one call, but this client
+// cannot parse its Redis 7 reply.
+func claimStale(ctx context.Context, consumer string) ([]redis.XMessage, error) {
+	pending, err := db.RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: resultsStream,
+		Group:  recordersGroup,
+		Idle:   recordTimeout,
+		Start:  "-",
+		End:    "+",
+		Count:  50,
+	}).Result()
+	if err == redis.Nil || len(pending) == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(pending))
+	for i, p := range pending {
+		ids[i] = p.ID
+	}
+	return db.RedisClient.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   resultsStream,
+		Group:    recordersGroup,
+		Consumer: consumer,
+		MinIdle:  recordTimeout,
+		Messages: ids,
+	}).Result()
+}
+
+func recordOutcome(ctx context.Context, msg redis.XMessage, record func(*Outcome) error) {
+	var outcome Outcome
+	payload, _ := msg.Values["outcome"].(string)
+	if err := json.Unmarshal([]byte(payload), &outcome); err != nil {
+		log.Printf("Dropping malformed result %s: %v", msg.ID, err)
+		ack(ctx, resultsStream, recordersGroup, msg.ID)
+		return
+	}
+
+	key := jobPrefix + outcome.JobID
+	if status, _ := db.RedisClient.HGet(ctx, key, "status").Result(); status != StatusDone {
+		if err := record(&outcome); err != nil {
+			log.Printf("Failed to record job %s, will retry: %v", outcome.JobID, err)
+			return
+		}
+	}
+
+	result, err := json.Marshal(outcome.Result)
+	if err != nil {
+		log.Printf("Failed to encode result of job %s: %v", outcome.JobID, err)
+		return
+	}
+	pipe := db.RedisClient.TxPipeline()
+	pipe.HSet(ctx, key, "status", StatusDone, "result", result, "finishedAt", time.Now().Format(time.RFC3339Nano))
+	pipe.Expire(ctx, key, jobTTL)
+	pipe.RPush(ctx, donePrefix+outcome.JobID, "1")
+	pipe.Expire(ctx, donePrefix+outcome.JobID, time.Minute)
+	pipe.XAck(ctx, resultsStream, recordersGroup, msg.ID)
+	pipe.XDel(ctx, resultsStream, msg.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to complete job %s: %v", outcome.JobID, err)
+	}
+}
+
+// ack acknowledges a stream entry and deletes it, since no other group
+// reads it.
+func ack(ctx context.Context, stream, group, id string) {
+	pipe := db.RedisClient.TxPipeline()
+	pipe.XAck(ctx, stream, group, id)
+	pipe.XDel(ctx, stream, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to acknowledge %s on %s: %v", id, stream, err)
+	}
+}
+
+// codeHash is the hex SHA-256 stored in execution records instead of the
+// code.
+func codeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,413 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code-editor/db"
+	"code-editor/replay"
+	"code-editor/sandbox"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	heartbeatInterval = 5 * time.Second
+	// A worker whose heartbeat is this old is presumed dead and its pending
+	// jobs are handed to other workers.
+	heartbeatTTL = 3 * heartbeatInterval
+	// maxDeliveries bounds how often a job is retried after its worker died,
+	// so that code which takes workers down cannot loop through all of them.
+	maxDeliveries = 3
+)
+
+// WorkerInfo is the heartbeat a worker publishes.
+type WorkerInfo struct {
+	Name        string    `json:"name"`
+	Languages   []string  `json:"languages"`
+	Concurrency int       `json:"concurrency"`
+	Running     int       `json:"running"`
+	StartedAt   time.Time `json:"startedAt"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+// Workers lists the workers with a live heartbeat.
+func Workers(ctx context.Context) ([]WorkerInfo, error) {
+	var keys []string
+	iter := db.RedisClient.Scan(ctx, 0, workerPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list workers: %w", err)
+	}
+	if len(keys) == 0 {
+		return []WorkerInfo{}, nil
+	}
+
+	values, err := db.RedisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workers: %w", err)
+	}
+	workers := []WorkerInfo{}
+	for _, value := range values {
+		payload, ok := value.(string)
+		if !ok {
+			continue // Expired since the scan
+		}
+		var info WorkerInfo
+		if json.Unmarshal([]byte(payload), &info) == nil {
+			workers = append(workers, info)
+		}
+	}
+	return workers, nil
+}
+
+func anyRuns(workers []WorkerInfo, language string) bool {
+	for _, w := range workers {
+		if slices.Contains(w.Languages, language) {
+			return true
+		}
+	}
+	return false
+}
+
+// Worker consumes the job streams of its languages and runs the jobs in the
+// sandbox, at most Concurrency at a time.
+type Worker struct {
+	Name        string
+	Languages   []string
+	Concurrency int
+
+	startedAt time.Time
+	running   atomic.Int32
+	active    sync.Map // Stream and message ID of each job running here
+	slots     chan struct{}
+	wg        sync.WaitGroup
+}
+
+// Run processes jobs until ctx is done, then waits for the running ones to
+// finish and removes the worker's heartbeat.
+func (w *Worker) Run(ctx context.Context) error {
+	w.startedAt = time.Now()
+	w.slots = make(chan struct{}, max(w.Concurrency, 1))
+
+	streams := make([]string, len(w.Languages))
+	for i, language := range w.Languages {
+		streams[i] = streamOf(language)
+		if err := ensureGroup(ctx, streams[i], workersGroup); err != nil {
+			return err
+		}
+	}
+	if err := w.heartbeat(ctx); err != nil {
+		return err
+	}
+	log.Printf("Worker %s running %s with %d slots", w.Name, strings.Join(w.Languages, ", "), cap(w.slots))
+
+	go w.heartbeats(ctx)
+	go w.cancellations(ctx)
+	go w.reclaim(ctx, streams)
+
+	// Jobs this worker read but never acknowledged in a previous life come
+	// first, then new ones
+	for _, stream := range streams {
+		w.claim(ctx, stream, w.Name, 0, true)
+	}
+	for ctx.Err() == nil {
+		w.read(ctx, streams)
+	}
+
+	w.wg.Wait()
+	db.RedisClient.Del(context.Background(), workerPrefix+w.Name)
+	return nil
+}
+
+// read takes a free slot, reads new jobs from the streams and runs them in
+// the background. COUNT applies to each stream, so one read can return a job
+// from every stream; each job after the first waits for a slot of its own.
+func (w *Worker) read(ctx context.Context, streams []string) {
+	select {
+	case w.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+
+	ids := make([]string, len(streams))
+	for i := range ids {
+		ids[i] = ">"
+	}
+	result, err := db.RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    workersGroup,
+		Consumer: w.Name,
+		Streams:  append(slices.Clone(streams), ids...),
+		Count:    1,
+		Block:    5 * time.Second,
+	}).Result()
+	if err != nil {
+		<-w.slots
+		if err != redis.Nil && ctx.Err() == nil {
+			log.Printf("Failed to read jobs: %v", err)
+			time.Sleep(time.Second)
+		}
+		return
+	}
+
+	taken := true
+	for _, stream := range result {
+		for _, msg := range stream.Messages {
+			if !taken {
+				select {
+				case w.slots <- struct{}{}:
+				case <-ctx.Done():
+					return // Left pending; the next worker to start claims it
+				}
+			}
+			w.start(stream.Stream, msg, 0)
+			taken = false
+		}
+	}
+	if taken {
+		<-w.slots
+	}
+}
+
+// start runs a job in a slot the caller has already taken.
+func (w *Worker) start(stream string, msg redis.XMessage, deliveries int64) {
+	w.wg.Add(1)
+	w.running.Add(1)
+	w.active.Store(stream+" "+msg.ID, struct{}{})
+	go func() {
+		defer func() {
+			w.active.Delete(stream + " " + msg.ID)
+			w.running.Add(-1)
+			<-w.slots
+			w.wg.Done()
+		}()
+		w.process(stream, msg, deliveries)
+	}()
+}
+
+// process runs one job and publishes its outcome. It is not tied to the
+// worker's context so that a shutdown lets running jobs finish.
+func (w *Worker) process(stream string, msg redis.XMessage, deliveries int64) {
+	ctx := context.Background()
+
+	var job Job
+	payload, _ := msg.Values["job"].(string)
+	if err := json.Unmarshal([]byte(payload), &job); err != nil {
+		log.Printf("Dropping malformed job %s: %v", msg.ID, err)
+		ack(ctx, stream, workersGroup, msg.ID)
+		return
+	}
+	req := job.Request
+	key := jobPrefix + req.ID
+
+	fields, err := db.RedisClient.HMGet(ctx, key, "status", "cancel").Result()
+	if err != nil {
+		log.Printf("Failed to read job %s, leaving it for redelivery: %v", req.ID, err)
+		return
+	}
+	status, _ := fields[0].(string)
+	cancelled, _ := fields[1].(string)
+	switch {
+	case status == "" || status == StatusDone:
+		// Expired or already published by a worker presumed dead
+		ack(ctx, stream, workersGroup, msg.ID)
+		return
+	case cancelled != "":
+		w.publish(ctx, stream, msg.ID, req, &sandbox.Result{ID: req.ID, Status: sandbox.StatusCancelled})
+		return
+	case deliveries >= maxDeliveries:
+		log.Printf("Giving up on job %s after %d deliveries", req.ID, deliveries)
+		w.publish(ctx, stream, msg.ID, req, &sandbox.Result{
+			ID:     req.ID,
+			Status: sandbox.StatusError,
+			Error:  fmt.Sprintf("execution failed: %d workers stopped while running it", deliveries),
+		})
+		return
+	}
+
+	db.RedisClient.HSet(ctx, key, "status", StatusRunning, "worker", w.Name, "startedAt", time.Now().Format(time.RFC3339Nano))
+	log.Printf("Worker %s running job %s (%s), queued for %s", w.Name, req.ID, req.Language, time.Since(job.EnqueuedAt).Round(time.Millisecond))
+	res := sandbox.Execute(req)
+	w.publish(ctx, stream, msg.ID, req, res)
+}
+
+// publish adds the outcome to the results stream and acknowledges and deletes
+// the job in one transaction, so a job is never both published and
+// redelivered.
+func (w *Worker) publish(ctx context.Context, stream, id string, req sandbox.Request, res *sandbox.Result) {
+	outcome := Outcome{
+		JobID:     req.ID,
+		Worker:    w.Name,
+		Owner:     req.Owner,
+		Language:  req.Language,
+		Mode:      req.Mode,
+		CodeHash:  codeHash(req.Code),
+		InputSize: len(req.Input),
+		Result:    res,
+		Sample:    replay.Sample(req, res),
+	}
+	if res.Profile != nil && res.Profile.Raw != nil {
+		outcome.Profile = res.Profile.Raw
+		outcome.ProfileFilename = res.Profile.Filename
+	}
+	payload, err := json.Marshal(outcome)
+	if err != nil {
+		log.Printf("Failed to encode outcome of job %s: %v", req.ID, err)
+		return
+	}
+
+	pipe := db.RedisClient.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: resultsStream,
+		Values: map[string]interface{}{"outcome": payload},
+	})
+	pipe.XAck(ctx, stream, workersGroup, id)
+	pipe.XDel(ctx, stream, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to publish outcome of job %s: %v", req.ID, err)
+	}
+}
+
+func (w *Worker) heartbeat(ctx context.Context) error {
+	info, err := json.Marshal(WorkerInfo{
+		Name:        w.Name,
+		Languages:   w.Languages,
+		Concurrency: cap(w.slots),
+		Running:     int(w.running.Load()),
+		StartedAt:   w.startedAt,
+		LastSeen:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := db.RedisClient.Set(ctx, workerPrefix+w.Name, info, heartbeatTTL).Err(); err != nil {
+		return fmt.Errorf("failed to publish heartbeat: %w", err)
+	}
+	return nil
+}
+
+func (w *Worker) heartbeats(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.heartbeat(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Worker %s: %v", w.Name, err)
+			}
+		}
+	}
+}
+
+// cancellations stops running jobs that the API asks to cancel. Jobs running
+// on other workers are simply not found here.
+func (w *Worker) cancellations(ctx context.Context) {
+	sub := db.RedisClient.Subscribe(ctx, cancelChannel)
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-sub.Channel():
+			if sandbox.Cancel(msg.Payload, "", true) == nil {
+				log.Printf("Worker %s cancelled job %s", w.Name, msg.Payload)
+			}
+		}
+	}
+}
+
+// reclaim periodically takes over jobs pending on workers whose heartbeat
+// has expired, which crashed or were killed mid-run, and retries its own
+// pending jobs that are no longer running, such as ones left for
+// redelivery after a Redis error.
+func (w *Worker) reclaim(ctx context.Context, streams []string) {
+	ticker := time.NewTicker(2 * heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, stream := range streams {
+			w.claim(ctx, stream, "", heartbeatTTL, false)
+		}
+	}
+}
+
+// claim takes over the stream's jobs that have been pending for minIdle on
+// consumer, or on any dead worker or this one when consumer is empty, and
+// runs them.
+// With wait it waits for free slots, otherwise it stops when all are busy.
+func (w *Worker) claim(ctx context.Context, stream, consumer string, minIdle time.Duration, wait bool) {
+	pending, err := db.RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   stream,
+		Group:    workersGroup,
+		Idle:     minIdle,
+		Start:    "-",
+		End:      "+",
+		Count:    50,
+		Consumer: consumer,
+	}).Result()
+	if err == redis.Nil {
+		return
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to list pending jobs on %s: %v", stream, err)
+		}
+		return
+	}
+
+	for _, p := range pending {
+		if consumer == "" {
+			if p.Consumer == w.Name {
+				if _, running := w.active.Load(stream + " " + p.ID); running {
+					continue
+				}
+			} else if alive, err := db.RedisClient.Exists(ctx, workerPrefix+p.Consumer).Result(); err != nil || alive > 0 {
+				continue
+			}
+		}
+		if wait {
+			select {
+			case w.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		} else {
+			select {
+			case w.slots <- struct{}{}:
+			default:
+				return // Busy; try again next round
+			}
+		}
+
+		claimed, err := db.RedisClient.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    workersGroup,
+			Consumer: w.Name,
+			MinIdle:  minIdle,
+			Messages: []string{p.ID},
+		}).Result()
+		if err != nil || len(claimed) == 0 {
+			<-w.slots // Another worker was faster
+			continue
+		}
+		if p.Consumer != w.Name {
+			log.Printf("Worker %s took over job %s from %s", w.Name, p.ID, p.Consumer)
+		}
+		w.start(stream, claimed[0], p.RetryCount)
+	}
+}
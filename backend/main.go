@@ -2,30 +2,74 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
-
-	"code-editor/sandbox"
+	"strconv"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"code-editor/abuse"
 	"code-editor/config"
 	"code-editor/db"
 	"code-editor/handlers"
+	"code-editor/jobs"
 	"code-editor/middleware"
 	"code-editor/models"
+	codeexecv1 "code-editor/proto/codeexec/v1"
+	"code-editor/sandbox"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		runSubcommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	// Load configuration (including JWT secret)
 	config.LoadConfig()
 
+	// Clean up containers and temp dirs left behind by a previous crash
+	sandbox.StartReaper(5 * time.Minute)
+
+	// Load sandbox language overrides and check images in the background so
+	// broken languages get disabled without delaying startup
+	if err := sandbox.LoadLanguages(); err != nil {
+		log.Fatalf("Failed to load sandbox languages: %v", err)
+	}
+	if err := sandbox.VerifyRuntimes(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	if err := sandbox.StartWasm(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	// With the execution queue, runs with network access go through the
+	// workers' egress proxies
+	if !jobs.Enabled() {
+		if err := sandbox.StartEgress(); err != nil {
+			log.Fatalf("Failed to enable network egress: %v", err)
+		}
+	}
+	go sandbox.CheckLanguages(os.Getenv("SANDBOX_BUILD_IMAGES") == "true")
+
+	// Abuse rules are reloaded when their file changes
+	if err := abuse.LoadRules(); err != nil {
+		log.Fatalf("Failed to load abuse rules: %v", err)
+	}
+	abuse.WatchRules(10 * time.Second)
+
 	// Connect to MongoDB
 	client := db.ConnectDB()
 	defer func() {
@@ -37,10 +81,24 @@ func main() {
 	// Connect to Redis
 	db.ConnectRedis()
 
+	// REPL and debug containers are charged for CPU left running after the
+	// owner's last request once they close
+	sandbox.ChargeSessionCPU = func(owner string, cpu time.Duration) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		middleware.ChargeCPU(ctx, owner, cpu.Milliseconds())
+	}
+
 	// Get a handle to the users collection
 	usersCollection := client.Database("code_editor_db").Collection("users")
 	codesCollection := client.Database("code_editor_db").Collection("codes")
 	sharedCodesCollection := client.Database("code_editor_db").Collection("shared_codes")
+	executionsCollection := client.Database("code_editor_db").Collection("executions")
+	artifactsCollection := client.Database("code_editor_db").Collection("artifacts")
+	notebooksCollection := client.Database("code_editor_db").Collection("notebooks")
+	tokensCollection := client.Database("code_editor_db").Collection("api_tokens")
+	samplesCollection := client.Database("code_editor_db").Collection("execution_samples")
+	incidentsCollection := client.Database("code_editor_db").Collection("abuse_incidents")
 
 	// Create a unique index on the email field
 	indexModel := mongo.IndexModel{
@@ -62,13 +120,73 @@ func main() {
 		log.Fatalf("Failed to create TTL index on shared_codes collection: %v", err)
 	}
 
+	// Index executions for per-user history and expire them after the
+	// configured retention period
+	if err := ensureExecutionIndexes(executionsCollection); err != nil {
+		log.Fatalf("Failed to create indexes on executions collection: %v", err)
+	}
+
+	// Raw profiles and other execution artifacts are kept for a day
+	artifactsIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "executionId", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(24 * 60 * 60)},
+	}
+	if _, err := artifactsCollection.Indexes().CreateMany(context.Background(), artifactsIndexes); err != nil {
+		log.Fatalf("Failed to create indexes on artifacts collection: %v", err)
+	}
+
+	// Samples hold user code, so they are kept for a limited time
+	samplesIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "language", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(90 * 24 * 60 * 60)},
+	}
+	if _, err := samplesCollection.Indexes().CreateMany(context.Background(), samplesIndexes); err != nil {
+		log.Fatalf("Failed to create indexes on execution_samples collection: %v", err)
+	}
+
+	// Repeats are counted on the one open incident per owner, rule or
+	// signal and code; resolved incidents are dropped after 90 days
+	incidentsIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "kind", Value: 1}, {Key: "ruleId", Value: 1}, {Key: "signal", Value: 1}, {Key: "codeHash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": models.IncidentOpen}),
+		},
+		{Keys: bson.D{{Key: "resolvedAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(90 * 24 * 60 * 60)},
+	}
+	if _, err := incidentsCollection.Indexes().CreateMany(context.Background(), incidentsIndexes); err != nil {
+		log.Fatalf("Failed to create indexes on abuse_incidents collection: %v", err)
+	}
+
+	notebooksIndex := mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}, {Key: "updatedAt", Value: -1}}}
+	if _, err := notebooksCollection.Indexes().CreateOne(context.Background(), notebooksIndex); err != nil {
+		log.Fatalf("Failed to create index on notebooks collection: %v", err)
+	}
+
+	// API tokens are looked up by hash on every gRPC call
+	tokensIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+	}
+	if _, err := tokensCollection.Indexes().CreateMany(context.Background(), tokensIndexes); err != nil {
+		log.Fatalf("Failed to create indexes on api_tokens collection: %v", err)
+	}
+
 	router := gin.Default()
+	// Client IPs identify anonymous users for rate limits, abuse blocks and
+	// ownership, so forwarded headers are only believed from known proxies
+	if err := router.SetTrustedProxies(config.TrustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Add CORS middleware
+	allowedOrigins := []string{"http://localhost:3000"}
 	config := cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
+		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-RateLimit-CPU-Limit", "X-RateLimit-CPU-Remaining", "Retry-After"},
 		AllowCredentials: true,
 	}
 	router.Use(cors.New(config))
@@ -85,6 +203,23 @@ func main() {
 	authHandler := handlers.NewAuthHandler(usersCollection, smtpCfg)
 	codeHandler := handlers.NewCodeHandler(codesCollection)
 	shareHandler := handlers.NewShareHandler(codesCollection, sharedCodesCollection)
+	executeHandler := handlers.NewExecuteHandler(executionsCollection, artifactsCollection, samplesCollection, incidentsCollection)
+	toolsHandler := handlers.NewToolsHandler()
+	lspHandler := handlers.NewLSPHandler(allowedOrigins)
+	debugHandler := handlers.NewDebugHandler(allowedOrigins)
+	replHandler := handlers.NewReplHandler()
+	notebookHandler := handlers.NewNotebookHandler(notebooksCollection)
+	tokenHandler := handlers.NewTokenHandler(tokensCollection)
+
+	// With the queue, sessions and tools only run here when enabled
+	localSandbox := middleware.LocalSandboxMiddleware(jobs.LocalSandbox())
+	// Blocked clients may not run code through sessions and tools either
+	notBlocked := middleware.AbuseBlockMiddleware()
+
+	// Workers only publish results; this instance records them
+	if jobs.Enabled() {
+		go executeHandler.RecordJobs(context.Background())
+	}
 
 	// Auth routes
 	router.POST("/login", authHandler.Login)
@@ -112,29 +247,182 @@ func main() {
 		codeRoutes.DELETE("/:id", codeHandler.DeleteCode)
 	}
 
-	// Code execution route (remains in main.go)
-	router.POST("/execute", func(c *gin.Context) {
-		var req models.CodeRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
-			return
+	// Code execution routes. Login is optional, but when present it ties the
+	// execution to the user so that only they (or an admin) can cancel it.
+	executionRoutes := router.Group("")
+	executionRoutes.Use(middleware.OptionalAuthMiddleware(usersCollection))
+	{
+		executionRoutes.POST("/execute", middleware.RateLimitMiddleware(), executeHandler.Execute)
+		executionRoutes.DELETE("/executions/:id", executeHandler.CancelExecution)
+		executionRoutes.GET("/executions/:id/profile", executeHandler.GetProfile)
+		executionRoutes.POST("/format", localSandbox, notBlocked, middleware.RateLimitMiddleware(), toolsHandler.Format)
+		executionRoutes.POST("/lint", localSandbox, notBlocked, middleware.RateLimitMiddleware(), toolsHandler.Lint)
+	}
+
+	// Asynchronous executions, run by the workers
+	if jobs.Enabled() {
+		jobRoutes := router.Group("/jobs")
+		jobRoutes.Use(middleware.OptionalAuthMiddleware(usersCollection))
+		{
+			jobRoutes.POST("", middleware.RateLimitMiddleware(), executeHandler.SubmitJob)
+			jobRoutes.GET("/:id", executeHandler.GetJob)
 		}
-		fmt.Println("api data ", req.Language, req.Code, req.Input)
-		output, err := sandbox.ExecuteCode(req.Language, req.Code, req.Input)
-		log.Printf("Docker returned output: %q, err: %v", output, err)
+	}
 
-		resp := gin.H{"output": output}
-		if err != nil {
-			resp["error"] = err.Error()
+	// Language server sessions over WebSocket
+	router.GET("/lsp/:language", middleware.AuthMiddleware(usersCollection), localSandbox, notBlocked, lspHandler.Connect)
+
+	// Debug sessions: build, then bridge DAP over WebSocket
+	debugRoutes := router.Group("/debug")
+	debugRoutes.Use(middleware.AuthMiddleware(usersCollection), localSandbox)
+	{
+		debugRoutes.POST("", notBlocked, middleware.RateLimitMiddleware(), debugHandler.StartSession)
+		debugRoutes.GET("/:id", notBlocked, debugHandler.Connect)
+		debugRoutes.DELETE("/:id", debugHandler.StopSession)
+	}
+
+	// REPL sessions keeping interpreter state between snippets
+	replRoutes := router.Group("/repl")
+	replRoutes.Use(middleware.AuthMiddleware(usersCollection), localSandbox)
+	{
+		replRoutes.POST("", notBlocked, replHandler.StartRepl)
+		replRoutes.POST("/:id/eval", notBlocked, middleware.RateLimitMiddleware(), replHandler.Eval)
+		replRoutes.POST("/:id/reset", replHandler.Reset)
+		replRoutes.DELETE("/:id", replHandler.CloseRepl)
+	}
+
+	// Notebook routes
+	notebookRoutes := router.Group("/notebooks")
+	notebookRoutes.Use(middleware.AuthMiddleware(usersCollection))
+	{
+		notebookRoutes.POST("", notebookHandler.CreateNotebook)
+		notebookRoutes.POST("/import", notebookHandler.ImportNotebook)
+		notebookRoutes.GET("", notebookHandler.GetNotebooks)
+		notebookRoutes.GET("/:id", notebookHandler.GetNotebook)
+		notebookRoutes.GET("/:id/export", notebookHandler.ExportNotebook)
+		notebookRoutes.PUT("/:id", notebookHandler.UpdateNotebook)
+		notebookRoutes.DELETE("/:id", notebookHandler.DeleteNotebook)
+		notebookRoutes.POST("/:id/cells/:cellId/execute", localSandbox, notBlocked, middleware.RateLimitMiddleware(), notebookHandler.ExecuteCell)
+		notebookRoutes.POST("/:id/kernel/restart", localSandbox, notebookHandler.RestartKernel)
+	}
+
+	// API tokens for the gRPC service
+	tokenRoutes := router.Group("/tokens")
+	tokenRoutes.Use(middleware.AuthMiddleware(usersCollection))
+	{
+		tokenRoutes.POST("", tokenHandler.CreateToken)
+		tokenRoutes.GET("", tokenHandler.GetTokens)
+		tokenRoutes.DELETE("/:id", tokenHandler.DeleteToken)
+	}
+
+	// Execution history routes
+	router.GET("/executions", middleware.AuthMiddleware(usersCollection), executeHandler.GetExecutions)
+	adminRoutes := router.Group("/admin")
+	adminRoutes.Use(middleware.AuthMiddleware(usersCollection), middleware.AdminMiddleware())
+	{
+		adminRoutes.GET("/executions", executeHandler.GetAllExecutions)
+		if jobs.Enabled() {
+			adminRoutes.GET("/workers", executeHandler.GetWorkers)
 		}
-		c.JSON(http.StatusOK, resp)
+		adminRoutes.GET("/incidents", executeHandler.GetIncidents)
+		adminRoutes.POST("/incidents/:id/resolve", executeHandler.ResolveIncident)
+		adminRoutes.DELETE("/blocks/:owner", executeHandler.Unblock)
+		adminRoutes.GET("/abuse/rules", executeHandler.GetAbuseRules)
+	}
+
+	// Language availability, so the UI can disable broken languages
+	router.GET("/languages", func(c *gin.Context) {
+		c.JSON(http.StatusOK, sandbox.LanguageStatuses())
 	})
 
+	// Runtime counters (reaped sandbox resources etc.), which also expose the
+	// process's command line and memory stats, so admins only
+	router.GET("/debug/vars", middleware.AuthMiddleware(usersCollection), middleware.AdminMiddleware(), gin.WrapH(expvar.Handler()))
+
 	// Health check route (remains in main.go)
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
+	// gRPC execution API for internal services
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = ":9090"
+	}
+	unaryAuth, streamAuth := middleware.GRPCAuth(usersCollection, tokensCollection)
+	grpcOptions := []grpc.ServerOption{
+		grpc.UnaryInterceptor(unaryAuth),
+		grpc.StreamInterceptor(streamAuth),
+		grpc.MaxRecvMsgSize(handlers.MaxGRPCMessageSize),
+	}
+	// API tokens travel in metadata, so anything beyond a private network
+	// needs TLS
+	certFile, keyFile := os.Getenv("GRPC_TLS_CERT"), os.Getenv("GRPC_TLS_KEY")
+	if certFile != "" || keyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load gRPC TLS certificate: %v", err)
+		}
+		grpcOptions = append(grpcOptions, grpc.Creds(creds))
+	} else {
+		log.Println("gRPC server has no TLS certificate; API tokens are sent in plain text")
+	}
+	grpcServer := grpc.NewServer(grpcOptions...)
+	codeexecv1.RegisterExecutionServiceServer(grpcServer, handlers.NewExecutionService(executeHandler))
+	go func() {
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC on %s: %v", grpcAddr, err)
+		}
+		log.Printf("gRPC server listening on %s", grpcAddr)
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Fatalf("gRPC server stopped: %v", err)
+		}
+	}()
+
 	// start server
 	router.Run(":8003")
 }
+
+// ensureExecutionIndexes creates the history index and the retention TTL
+// index on the executions collection. EXECUTION_RETENTION_DAYS (default 30)
+// sets the retention; changing it updates the existing TTL index in place.
+func ensureExecutionIndexes(executionsCollection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := executionsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "email", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// MongoDB stores expireAfterSeconds as a 32-bit integer
+	const maxRetentionDays = math.MaxInt32 / (24 * 60 * 60)
+	retentionDays := int64(30)
+	if v := os.Getenv("EXECUTION_RETENTION_DAYS"); v != "" {
+		retentionDays, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || retentionDays < 1 || retentionDays > maxRetentionDays {
+			return fmt.Errorf("EXECUTION_RETENTION_DAYS must be a number of days from 1 to %d", maxRetentionDays)
+		}
+	}
+	expireAfter := int32(retentionDays * 24 * 60 * 60)
+
+	ttlIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "createdAt", Value: 1}},
+		Options: options.Index().SetName("createdAt_ttl").SetExpireAfterSeconds(expireAfter),
+	}
+	_, err = executionsCollection.Indexes().CreateOne(ctx, ttlIndexModel)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 85 { // IndexOptionsConflict: retention changed
+		return executionsCollection.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: executionsCollection.Name()},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: "createdAt_ttl"},
+				{Key: "expireAfterSeconds", Value: expireAfter},
+			}},
+		}).Err()
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"code-editor/abuse"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AbuseBlockMiddleware refuses blocked clients on routes that run code
+// outside /execute, which screens its requests itself: REPLs, notebook
+// cells, debug and language server sessions, format and lint. It must run
+// after (Optional)AuthMiddleware. Anonymous clients are blocked by IP, which
+// only TRUSTED_PROXIES can vouch for, so a forged X-Forwarded-For does not
+// escape a block. Redis errors fail open.
+func AbuseBlockMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := "ip:" + c.ClientIP()
+		if email, ok := c.Request.Context().Value("userEmail").(string); ok {
+			client = email
+		}
+		if remaining, err := abuse.Blocked(c.Request.Context(), client); err != nil {
+			log.Printf("Abuse block check failed for %s: %v", client, err)
+		} else if remaining > 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": abuse.ErrBlocked.Error()})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"code-editor/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets administrators through. It must run after
+// AuthMiddleware, which puts the user's role into the request context.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Request.Context().Value("userRole").(string)
+		if role != models.RoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -49,8 +49,46 @@ func AuthMiddleware(usersCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
-		// Add the email to the context for downstream handlers
+		// Add the email and role to the context for downstream handlers
 		ctx = context.WithValue(c.Request.Context(), "userEmail", claims.Email)
+		ctx = context.WithValue(ctx, "userRole", user.Role)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware identifies the user like AuthMiddleware when a valid
+// token cookie is present, but lets anonymous requests through untouched.
+func OptionalAuthMiddleware(usersCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, err := c.Cookie("token")
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		claims := &models.Claims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			return config.JWTSecret, nil
+		})
+		if err != nil || !token.Valid {
+			c.Next()
+			return
+		}
+
+		var user models.User
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err = usersCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&user)
+		if err != nil || !user.IsVerified {
+			c.Next()
+			return
+		}
+
+		ctx = context.WithValue(c.Request.Context(), "userEmail", claims.Email)
+		ctx = context.WithValue(ctx, "userRole", user.Role)
 		c.Request = c.Request.WithContext(ctx)
 
 		c.Next()
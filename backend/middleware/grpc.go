@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"code-editor/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// HashAPIToken returns the form in which an API token is stored and looked
+// up.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GRPCAuth authenticates gRPC calls with an API token sent as
+// "authorization: Bearer <token>" metadata. Like AuthMiddleware, it puts
+// the user's email and role into the context and requires a verified user.
+func GRPCAuth(usersCollection, tokensCollection *mongo.Collection) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	authenticate := func(ctx context.Context) (context.Context, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "no API token provided")
+		}
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok || token == "" {
+			return nil, status.Error(codes.Unauthenticated, "authorization must be \"Bearer <token>\"")
+		}
+
+		dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var apiToken models.APIToken
+		err := tokensCollection.FindOne(dbCtx, bson.M{"tokenHash": HashAPIToken(token)}).Decode(&apiToken)
+		if err == mongo.ErrNoDocuments {
+			return nil, status.Error(codes.Unauthenticated, "invalid API token")
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to check API token")
+		}
+
+		var user models.User
+		if err := usersCollection.FindOne(dbCtx, bson.M{"email": apiToken.Email}).Decode(&user); err != nil {
+			return nil, status.Error(codes.Unauthenticated, "the token's user no longer exists")
+		}
+		if !user.IsVerified {
+			return nil, status.Error(codes.PermissionDenied, "email not verified")
+		}
+
+		// Usage tracking must not slow down or fail the call
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := tokensCollection.UpdateByID(ctx, apiToken.ID, bson.M{"$set": bson.M{"lastUsedAt": time.Now()}}); err != nil {
+				log.Printf("Failed to update last use of API token %s: %v", apiToken.Prefix, err)
+			}
+		}()
+
+		ctx = context.WithValue(ctx, "userEmail", user.Email)
+		ctx = context.WithValue(ctx, "userRole", user.Role)
+		return ctx, nil
+	}
+
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+	return unary, stream
+}
+
+// authenticatedStream carries the authenticated context to stream handlers.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
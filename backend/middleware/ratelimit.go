@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code-editor/config"
+	"code-editor/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// CPUTimeKey is the gin context key under which execution handlers report
+// the CPU milliseconds a request consumed, charged to the daily quota.
+const CPUTimeKey = "cpuTimeMs"
+
+var (
+	ErrTooManyExecutions = errors.New("too many executions, please wait a minute")
+	ErrCPUQuotaExhausted = errors.New("daily CPU quota exhausted")
+)
+
+// RateLimitMiddleware enforces the per-role limits from config.RateLimits on
+// execution routes: executions per minute and CPU-seconds per day. Clients
+// are identified by email when logged in and by IP otherwise. It must run
+// after (Optional)AuthMiddleware. Redis errors fail open.
+func RateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		client, role := "ip:"+c.ClientIP(), config.RoleLimitAnonymous
+		if email, ok := ctx.Value("userEmail").(string); ok {
+			client, role = "user:"+email, config.RoleLimitUser
+			if r, _ := ctx.Value("userRole").(string); r != "" {
+				role = r
+			}
+		}
+		limit := config.RateLimitFor(role)
+		now := time.Now()
+
+		if limit.ExecutionsPerMinute > 0 {
+			reset := (now.Unix()/60 + 1) * 60
+			count, err := countExecution(ctx, client, now)
+			if err != nil {
+				log.Printf("Rate limit check failed for %s: %v", client, err)
+			} else {
+				remaining := limit.ExecutionsPerMinute - count
+				if remaining < 0 {
+					remaining = 0
+				}
+				c.Header("X-RateLimit-Limit", strconv.Itoa(limit.ExecutionsPerMinute))
+				c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+				c.Header("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+				if count > limit.ExecutionsPerMinute {
+					c.Header("Retry-After", strconv.FormatInt(reset-now.Unix(), 10))
+					c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many executions, please wait a minute"})
+					return
+				}
+			}
+		}
+
+		if limit.CPUSecondsPerDay > 0 {
+			used, err := cpuUsed(ctx, client, now)
+			if err != nil {
+				log.Printf("CPU quota check failed for %s: %v", client, err)
+			} else {
+				remaining := limit.CPUSecondsPerDay - used
+				if remaining < 0 {
+					remaining = 0
+				}
+				c.Header("X-RateLimit-CPU-Limit", strconv.FormatFloat(limit.CPUSecondsPerDay, 'f', -1, 64))
+				c.Header("X-RateLimit-CPU-Remaining", strconv.FormatFloat(remaining, 'f', 1, 64))
+				if remaining <= 0 {
+					midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+					c.Header("Retry-After", strconv.FormatInt(int64(midnight.Sub(now).Seconds()), 10))
+					c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Daily CPU quota exhausted"})
+					return
+				}
+			}
+		}
+
+		c.Next()
+
+		// The request context ends when the client hangs up, which must not
+		// make the run free
+		chargeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		chargeCPU(chargeCtx, client, c.GetInt64(CPUTimeKey))
+	}
+}
+
+// CheckRateLimit applies the limits of RateLimitMiddleware to an execution
+// outside gin, by an owner (an email, or "ip:<addr>") with the given role.
+// The caller charges the CPU time afterwards with ChargeCPU.
+func CheckRateLimit(ctx context.Context, owner, role string) error {
+	client, limitRole := clientOf(owner), config.RoleLimitAnonymous
+	if !strings.HasPrefix(owner, "ip:") {
+		limitRole = config.RoleLimitUser
+		if role != "" {
+			limitRole = role
+		}
+	}
+	limit := config.RateLimitFor(limitRole)
+	now := time.Now()
+
+	if limit.ExecutionsPerMinute > 0 {
+		count, err := countExecution(ctx, client, now)
+		if err != nil {
+			log.Printf("Rate limit check failed for %s: %v", client, err)
+		} else if count > limit.ExecutionsPerMinute {
+			return ErrTooManyExecutions
+		}
+	}
+	if limit.CPUSecondsPerDay > 0 {
+		used, err := cpuUsed(ctx, client, now)
+		if err != nil {
+			log.Printf("CPU quota check failed for %s: %v", client, err)
+		} else if used >= limit.CPUSecondsPerDay {
+			return ErrCPUQuotaExhausted
+		}
+	}
+	return nil
+}
+
+// countExecution counts an execution in the client's current minute and
+// returns the minute's total.
+func countExecution(ctx context.Context, client string, now time.Time) (int, error) {
+	key := fmt.Sprintf("ratelimit:exec:%s:%d", client, now.Unix()/60)
+	pipe := db.RedisClient.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(incr.Val()), nil
+}
+
+func cpuKey(client string, now time.Time) string {
+	return fmt.Sprintf("ratelimit:cpu:%s:%s", client, now.UTC().Format("2006-01-02"))
+}
+
+// cpuUsed returns the CPU-seconds the client has used today.
+func cpuUsed(ctx context.Context, client string, now time.Time) (float64, error) {
+	used, err := db.RedisClient.Get(ctx, cpuKey(client, now)).Float64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return used, err
+}
+
+// clientOf maps an execution owner to the client the limits are kept for.
+func clientOf(owner string) string {
+	if strings.HasPrefix(owner, "ip:") {
+		return owner
+	}
+	return "user:" + owner
+}
+
+// ChargeCPU adds CPU time used outside a request, such as by queued jobs, to
+// the daily quota of an execution owner (an email, or "ip:<addr>").
+func ChargeCPU(ctx context.Context, owner string, cpuMs int64) {
+	chargeCPU(ctx, clientOf(owner), cpuMs)
+}
+
+func chargeCPU(ctx context.Context, client string, cpuMs int64) {
+	if cpuMs <= 0 {
+		return
+	}
+	key := cpuKey(client, time.Now())
+	// Charge even unlimited roles so switching roles keeps the day's usage
+	pipe := db.RedisClient.TxPipeline()
+	pipe.IncrByFloat(ctx, key, float64(cpuMs)/1000)
+	pipe.Expire(ctx, key, 48*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to charge CPU time for %s: %v", client, err)
+	}
+}
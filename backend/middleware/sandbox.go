@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocalSandboxMiddleware guards routes that run sandboxes on the API host
+// instead of the workers, answering 503 when enabled is false.
+func LocalSandboxMiddleware(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Sessions and tools are not available on this server"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Artifact is a file produced by an execution, such as a raw profile, kept
+// for download until its TTL index removes it.
+type Artifact struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	ExecutionID string             `bson:"executionId"`
+	Kind        string             `bson:"kind"`  // e.g. "profile"
+	Owner       string             `bson:"owner"` // Email or "ip:<addr>", as for cancellation
+	Filename    string             `bson:"filename"`
+	Data        []byte             `bson:"data"`
+	CreatedAt   time.Time          `bson:"createdAt"` // For TTL index
+}
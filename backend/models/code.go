@@ -9,4 +9,7 @@ type Code struct {
 	Email    string             `bson:"email" json:"email"`
 	Language string             `bson:"language" json:"language"`
 	Code     string             `bson:"code" json:"code"`
+	// Default program arguments and environment for runs of the snippet
+	Args []string          `bson:"args,omitempty" json:"args,omitempty"`
+	Env  map[string]string `bson:"env,omitempty" json:"env,omitempty"`
 }
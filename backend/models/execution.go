@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Execution is the audit record of a single code run. The code itself is not
+// stored, only its hash.
+type Execution struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ExecutionID  string             `bson:"executionId" json:"executionId"`
+	Email        string             `bson:"email,omitempty" json:"email,omitempty"`       // Set for logged-in users
+	ClientIP     string             `bson:"clientIp,omitempty" json:"clientIp,omitempty"` // Set for anonymous clients
+	Language     string             `bson:"language" json:"language"`
+	Mode         string             `bson:"mode,omitempty" json:"mode,omitempty"`
+	Runtime      string             `bson:"runtime,omitempty" json:"runtime,omitempty"` // OCI runtime, or "wasm"
+	CodeHash     string             `bson:"codeHash" json:"codeHash"`                   // Hex SHA-256 of the submitted code
+	InputSize    int                `bson:"inputSize" json:"inputSize"`
+	Status       string             `bson:"status" json:"status"`
+	ExitCode     int                `bson:"exitCode" json:"exitCode"`
+	StartedAt    time.Time          `bson:"startedAt" json:"startedAt"`
+	WallTimeMs   int64              `bson:"wallTimeMs" json:"wallTimeMs"`
+	CPUTimeMs    int64              `bson:"cpuTimeMs" json:"cpuTimeMs"`
+	PeakMemoryKB int64              `bson:"peakMemoryKb" json:"peakMemoryKb"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"` // For TTL index
+	// Egress lists the requests made through the egress proxy, if the run
+	// had network access.
+	Egress []EgressRequest `bson:"egress,omitempty" json:"egress,omitempty"`
+}
+
+// EgressRequest is one proxied request of an execution.
+type EgressRequest struct {
+	Time          time.Time `bson:"time" json:"time"`
+	Method        string    `bson:"method" json:"method"`
+	Host          string    `bson:"host" json:"host"`
+	URL           string    `bson:"url,omitempty" json:"url,omitempty"`
+	Status        int       `bson:"status" json:"status"`
+	BytesSent     int64     `bson:"bytesSent" json:"bytesSent"`
+	BytesReceived int64     `bson:"bytesReceived" json:"bytesReceived"`
+	Blocked       string    `bson:"blocked,omitempty" json:"blocked,omitempty"`
+}
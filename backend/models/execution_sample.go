@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExecutionSample is a run kept together with its code, input and output so
+// that it can be replayed against new toolchain versions. Samples are taken
+// at random from plain runs and carry no owner.
+type ExecutionSample struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ExecutionID string             `bson:"executionId" json:"executionId"`
+	Language    string             `bson:"language" json:"language"`
+	Image       string             `bson:"image" json:"image"` // Image the code ran in
+	Code        string             `bson:"code" json:"code"`
+	Files       map[string]string  `bson:"files,omitempty" json:"files,omitempty"`
+	Input       string             `bson:"input,omitempty" json:"input,omitempty"`
+	Args        []string           `bson:"args,omitempty" json:"args,omitempty"`
+	Env         map[string]string  `bson:"env,omitempty" json:"env,omitempty"`
+	Status      string             `bson:"status" json:"status"`
+	ExitCode    int                `bson:"exitCode" json:"exitCode"`
+	Output      string             `bson:"output" json:"output"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"` // For TTL index
+}
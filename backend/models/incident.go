@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Incident statuses.
+const (
+	IncidentOpen     = "open"
+	IncidentResolved = "resolved"
+)
+
+// Incident records suspected abuse for admin review: submitted code that
+// matched an abuse rule, or a client that repeatedly exhausted its sandbox.
+// Repeats of an open incident, by the same owner with the same rule or
+// signal and code, are counted on it instead of recorded again.
+type Incident struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Owner       string             `bson:"owner" json:"owner"` // Email or "ip:<addr>"
+	Kind        string             `bson:"kind" json:"kind"`   // "rule" or "signal"
+	RuleID      string             `bson:"ruleId,omitempty" json:"ruleId,omitempty"`
+	Signal      string             `bson:"signal,omitempty" json:"signal,omitempty"` // timeout, oom or pids
+	Action      string             `bson:"action" json:"action"`                     // flag, reject or block
+	Blocked     bool               `bson:"blocked" json:"blocked"`                   // Whether the owner was blocked
+	Language    string             `bson:"language" json:"language"`
+	ExecutionID string             `bson:"executionId" json:"executionId"`
+	CodeHash    string             `bson:"codeHash,omitempty" json:"codeHash,omitempty"`
+	File        string             `bson:"file,omitempty" json:"file,omitempty"`
+	Line        int                `bson:"line,omitempty" json:"line,omitempty"`
+	Excerpt     string             `bson:"excerpt,omitempty" json:"excerpt,omitempty"` // The offending line
+	Detail      string             `bson:"detail" json:"detail"`
+	Status      string             `bson:"status" json:"status"`
+	Count       int                `bson:"count" json:"count"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	LastSeen    time.Time          `bson:"lastSeen" json:"lastSeen"` // ExecutionID is the latest run's
+	ResolvedAt  *time.Time         `bson:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+	ResolvedBy  string             `bson:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+	Note        string             `bson:"note,omitempty" json:"note,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notebook cell types.
+const (
+	CellMarkdown = "markdown"
+	CellCode     = "code"
+)
+
+// Notebook is a document of ordered markdown and code cells. Code cells run
+// in a per-notebook REPL kernel in the notebook's language.
+type Notebook struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Email          string             `bson:"email" json:"email"`
+	Title          string             `bson:"title" json:"title"`
+	Language       string             `bson:"language" json:"language"`
+	Cells          []Cell             `bson:"cells" json:"cells"`
+	ExecutionCount int                `bson:"executionCount" json:"executionCount"` // Cells run so far, for numbering
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+type Cell struct {
+	ID             string      `bson:"id" json:"id"`
+	Type           string      `bson:"type" json:"type"` // CellMarkdown or CellCode
+	Source         string      `bson:"source" json:"source"`
+	ExecutionCount int         `bson:"executionCount,omitempty" json:"executionCount,omitempty"`
+	Output         *CellOutput `bson:"output,omitempty" json:"output,omitempty"`
+}
+
+// CellOutput is what the last run of a code cell produced.
+type CellOutput struct {
+	Status     string    `bson:"status" json:"status"`
+	Output     string    `bson:"output" json:"output"`
+	Result     string    `bson:"result,omitempty" json:"result,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	ExecutedAt time.Time `bson:"executedAt,omitempty" json:"executedAt,omitempty"`
+}
@@ -6,6 +6,28 @@ type CodeRequest struct {
 	Language string `json:"language"`
 	Code     string `json:"code"`
 	Input    string `json:"input"`
+	// ExecutionID lets the client pick the ID up front so it can cancel the
+	// run while the request is still in flight. Generated when empty.
+	ExecutionID string `json:"executionId"`
+	// Mode is "run" (default) or "test", which runs the language's test
+	// framework and returns per-test results.
+	Mode string `json:"mode"`
+	// Files are extra source files placed next to the main file, keyed by
+	// relative path.
+	Files map[string]string `json:"files"`
+	// Coverage collects line coverage in test mode.
+	Coverage bool `json:"coverage"`
+	// Runs, Warmup and Baseline configure "benchmark" mode. Baseline is a
+	// second version of the code benchmarked for comparison.
+	Runs     int    `json:"runs"`
+	Warmup   int    `json:"warmup"`
+	Baseline string `json:"baseline"`
+	// Args and Env are passed to the program when it runs, never to the
+	// compiler.
+	Args []string          `json:"args"`
+	Env  map[string]string `json:"env"`
+	// Network asks for access to the hosts on the egress allowlist.
+	Network bool `json:"network"`
 }
 
 type LoginRequest struct {
@@ -19,12 +41,16 @@ type SignupRequest struct {
 }
 
 type User struct {
-	ID       string `bson:"_id,omitempty"` // MongoDB _id field
-	Email    string `bson:"email"`
-	Password string `bson:"password"` // Hashed password
+	ID         string `bson:"_id,omitempty"` // MongoDB _id field
+	Email      string `bson:"email"`
+	Password   string `bson:"password"` // Hashed password
 	IsVerified bool   `bson:"isVerified"`
+	Role       string `bson:"role,omitempty"` // Empty for regular users, RoleAdmin for administrators
 }
 
+// RoleAdmin marks users allowed to manage other users' resources.
+const RoleAdmin = "admin"
+
 type Claims struct {
 	Email string `json:"email"`
 	jwt.RegisteredClaims
@@ -0,0 +1,838 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: codeexec/v1/codeexec.proto
+
+// Code execution API for internal services. Calls authenticate with an API
+// token from POST /tokens in the "authorization" metadata: "Bearer <token>".
+
+package codeexecv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ExecuteRequest mirrors the body of POST /execute.
+type ExecuteRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Language string                 `protobuf:"bytes,1,opt,name=language,proto3" json:"language,omitempty"`
+	Code     string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Input    string                 `protobuf:"bytes,3,opt,name=input,proto3" json:"input,omitempty"`
+	// Generated when empty; otherwise a UUID, usable to cancel the run over
+	// DELETE /executions/:id.
+	ExecutionId string `protobuf:"bytes,4,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	// run (default), test, benchmark or profile.
+	Mode string `protobuf:"bytes,5,opt,name=mode,proto3" json:"mode,omitempty"`
+	// Extra source files next to the main file, keyed by relative path.
+	Files map[string]string `protobuf:"bytes,6,rep,name=files,proto3" json:"files,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Data files in the working directory, keyed by relative path.
+	Data          map[string][]byte `protobuf:"bytes,7,rep,name=data,proto3" json:"data,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Coverage      bool              `protobuf:"varint,8,opt,name=coverage,proto3" json:"coverage,omitempty"`
+	Runs          int32             `protobuf:"varint,9,opt,name=runs,proto3" json:"runs,omitempty"`
+	Warmup        int32             `protobuf:"varint,10,opt,name=warmup,proto3" json:"warmup,omitempty"`
+	Baseline      string            `protobuf:"bytes,11,opt,name=baseline,proto3" json:"baseline,omitempty"`
+	Args          []string          `protobuf:"bytes,12,rep,name=args,proto3" json:"args,omitempty"`
+	Env           map[string]string `protobuf:"bytes,13,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Network       bool              `protobuf:"varint,14,opt,name=network,proto3" json:"network,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteRequest) Reset() {
+	*x = ExecuteRequest{}
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteRequest) ProtoMessage() {}
+
+func (x *ExecuteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteRequest.ProtoReflect.Descriptor instead.
+func (*ExecuteRequest) Descriptor() ([]byte, []int) {
+	return file_codeexec_v1_codeexec_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ExecuteRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetInput() string {
+	if x != nil {
+		return x.Input
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetFiles() map[string]string {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *ExecuteRequest) GetData() map[string][]byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ExecuteRequest) GetCoverage() bool {
+	if x != nil {
+		return x.Coverage
+	}
+	return false
+}
+
+func (x *ExecuteRequest) GetRuns() int32 {
+	if x != nil {
+		return x.Runs
+	}
+	return 0
+}
+
+func (x *ExecuteRequest) GetWarmup() int32 {
+	if x != nil {
+		return x.Warmup
+	}
+	return 0
+}
+
+func (x *ExecuteRequest) GetBaseline() string {
+	if x != nil {
+		return x.Baseline
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ExecuteRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *ExecuteRequest) GetNetwork() bool {
+	if x != nil {
+		return x.Network
+	}
+	return false
+}
+
+type ExecuteResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ExecutionId string                 `protobuf:"bytes,1,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	// SUCCESS, ERROR, TIMEOUT or CANCELLED.
+	Status string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	// Empty in ExecuteStream results, where it was sent as it was produced.
+	Output      string                 `protobuf:"bytes,3,opt,name=output,proto3" json:"output,omitempty"`
+	Error       string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	ExitCode    int32                  `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	StartedAt   *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	Usage       *Usage                 `protobuf:"bytes,7,opt,name=usage,proto3" json:"usage,omitempty"`
+	Diagnostics []*Diagnostic          `protobuf:"bytes,8,rep,name=diagnostics,proto3" json:"diagnostics,omitempty"`
+	Runtime     string                 `protobuf:"bytes,9,opt,name=runtime,proto3" json:"runtime,omitempty"`
+	// The complete result as POST /execute returns it, including the test,
+	// coverage, benchmark, profile and egress reports of the other modes.
+	ResultJson    string `protobuf:"bytes,10,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteResponse) Reset() {
+	*x = ExecuteResponse{}
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteResponse) ProtoMessage() {}
+
+func (x *ExecuteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteResponse.ProtoReflect.Descriptor instead.
+func (*ExecuteResponse) Descriptor() ([]byte, []int) {
+	return file_codeexec_v1_codeexec_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExecuteResponse) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *ExecuteResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ExecuteResponse) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *ExecuteResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ExecuteResponse) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *ExecuteResponse) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetUsage() *Usage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetDiagnostics() []*Diagnostic {
+	if x != nil {
+		return x.Diagnostics
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetRuntime() string {
+	if x != nil {
+		return x.Runtime
+	}
+	return ""
+}
+
+func (x *ExecuteResponse) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+type Usage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WallTimeMs    int64                  `protobuf:"varint,1,opt,name=wall_time_ms,json=wallTimeMs,proto3" json:"wall_time_ms,omitempty"`
+	CpuTimeMs     int64                  `protobuf:"varint,2,opt,name=cpu_time_ms,json=cpuTimeMs,proto3" json:"cpu_time_ms,omitempty"`
+	PeakMemoryKb  int64                  `protobuf:"varint,3,opt,name=peak_memory_kb,json=peakMemoryKb,proto3" json:"peak_memory_kb,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Usage) Reset() {
+	*x = Usage{}
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Usage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Usage) ProtoMessage() {}
+
+func (x *Usage) ProtoReflect() protoreflect.Message {
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Usage.ProtoReflect.Descriptor instead.
+func (*Usage) Descriptor() ([]byte, []int) {
+	return file_codeexec_v1_codeexec_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Usage) GetWallTimeMs() int64 {
+	if x != nil {
+		return x.WallTimeMs
+	}
+	return 0
+}
+
+func (x *Usage) GetCpuTimeMs() int64 {
+	if x != nil {
+		return x.CpuTimeMs
+	}
+	return 0
+}
+
+func (x *Usage) GetPeakMemoryKb() int64 {
+	if x != nil {
+		return x.PeakMemoryKb
+	}
+	return 0
+}
+
+type Diagnostic struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	File          string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int32                  `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
+	Column        int32                  `protobuf:"varint,3,opt,name=column,proto3" json:"column,omitempty"`
+	Severity      string                 `protobuf:"bytes,4,opt,name=severity,proto3" json:"severity,omitempty"`
+	Rule          string                 `protobuf:"bytes,5,opt,name=rule,proto3" json:"rule,omitempty"`
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Diagnostic) Reset() {
+	*x = Diagnostic{}
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Diagnostic) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Diagnostic) ProtoMessage() {}
+
+func (x *Diagnostic) ProtoReflect() protoreflect.Message {
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Diagnostic.ProtoReflect.Descriptor instead.
+func (*Diagnostic) Descriptor() ([]byte, []int) {
+	return file_codeexec_v1_codeexec_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Diagnostic) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Diagnostic) GetColumn() int32 {
+	if x != nil {
+		return x.Column
+	}
+	return 0
+}
+
+func (x *Diagnostic) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ExecuteStreamResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*ExecuteStreamResponse_Output
+	//	*ExecuteStreamResponse_Result
+	Event         isExecuteStreamResponse_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteStreamResponse) Reset() {
+	*x = ExecuteStreamResponse{}
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteStreamResponse) ProtoMessage() {}
+
+func (x *ExecuteStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteStreamResponse.ProtoReflect.Descriptor instead.
+func (*ExecuteStreamResponse) Descriptor() ([]byte, []int) {
+	return file_codeexec_v1_codeexec_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ExecuteStreamResponse) GetEvent() isExecuteStreamResponse_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *ExecuteStreamResponse) GetOutput() []byte {
+	if x != nil {
+		if x, ok := x.Event.(*ExecuteStreamResponse_Output); ok {
+			return x.Output
+		}
+	}
+	return nil
+}
+
+func (x *ExecuteStreamResponse) GetResult() *ExecuteResponse {
+	if x != nil {
+		if x, ok := x.Event.(*ExecuteStreamResponse_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isExecuteStreamResponse_Event interface {
+	isExecuteStreamResponse_Event()
+}
+
+type ExecuteStreamResponse_Output struct {
+	// A piece of the program's combined stdout and stderr.
+	Output []byte `protobuf:"bytes,1,opt,name=output,proto3,oneof"`
+}
+
+type ExecuteStreamResponse_Result struct {
+	// The final message of the stream.
+	Result *ExecuteResponse `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*ExecuteStreamResponse_Output) isExecuteStreamResponse_Event() {}
+
+func (*ExecuteStreamResponse_Result) isExecuteStreamResponse_Event() {}
+
+type ListLanguagesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListLanguagesRequest) Reset() {
+	*x = ListLanguagesRequest{}
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListLanguagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListLanguagesRequest) ProtoMessage() {}
+
+func (x *ListLanguagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListLanguagesRequest.ProtoReflect.Descriptor instead.
+func (*ListLanguagesRequest) Descriptor() ([]byte, []int) {
+	return file_codeexec_v1_codeexec_proto_rawDescGZIP(), []int{5}
+}
+
+type ListLanguagesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Languages     []*Language            `protobuf:"bytes,1,rep,name=languages,proto3" json:"languages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListLanguagesResponse) Reset() {
+	*x = ListLanguagesResponse{}
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListLanguagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListLanguagesResponse) ProtoMessage() {}
+
+func (x *ListLanguagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListLanguagesResponse.ProtoReflect.Descriptor instead.
+func (*ListLanguagesResponse) Descriptor() ([]byte, []int) {
+	return file_codeexec_v1_codeexec_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListLanguagesResponse) GetLanguages() []*Language {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+type Language struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Image string                 `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	// docker or wasm.
+	Backend       string `protobuf:"bytes,3,opt,name=backend,proto3" json:"backend,omitempty"`
+	Available     bool   `protobuf:"varint,4,opt,name=available,proto3" json:"available,omitempty"`
+	Reason        string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Language) Reset() {
+	*x = Language{}
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Language) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Language) ProtoMessage() {}
+
+func (x *Language) ProtoReflect() protoreflect.Message {
+	mi := &file_codeexec_v1_codeexec_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Language.ProtoReflect.Descriptor instead.
+func (*Language) Descriptor() ([]byte, []int) {
+	return file_codeexec_v1_codeexec_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Language) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Language) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *Language) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *Language) GetAvailable() bool {
+	if x != nil {
+		return x.Available
+	}
+	return false
+}
+
+func (x *Language) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+var File_codeexec_v1_codeexec_proto protoreflect.FileDescriptor
+
+const file_codeexec_v1_codeexec_proto_rawDesc = "" +
+	"\n" +
+	"\x1acodeexec/v1/codeexec.proto\x12\vcodeexec.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xfb\x04\n" +
+	"\x0eExecuteRequest\x12\x1a\n" +
+	"\blanguage\x18\x01 \x01(\tR\blanguage\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x14\n" +
+	"\x05input\x18\x03 \x01(\tR\x05input\x12!\n" +
+	"\fexecution_id\x18\x04 \x01(\tR\vexecutionId\x12\x12\n" +
+	"\x04mode\x18\x05 \x01(\tR\x04mode\x12<\n" +
+	"\x05files\x18\x06 \x03(\v2&.codeexec.v1.ExecuteRequest.FilesEntryR\x05files\x129\n" +
+	"\x04data\x18\a \x03(\v2%.codeexec.v1.ExecuteRequest.DataEntryR\x04data\x12\x1a\n" +
+	"\bcoverage\x18\b \x01(\bR\bcoverage\x12\x12\n" +
+	"\x04runs\x18\t \x01(\x05R\x04runs\x12\x16\n" +
+	"\x06warmup\x18\n" +
+	" \x01(\x05R\x06warmup\x12\x1a\n" +
+	"\bbaseline\x18\v \x01(\tR\bbaseline\x12\x12\n" +
+	"\x04args\x18\f \x03(\tR\x04args\x126\n" +
+	"\x03env\x18\r \x03(\v2$.codeexec.v1.ExecuteRequest.EnvEntryR\x03env\x12\x18\n" +
+	"\anetwork\x18\x0e \x01(\bR\anetwork\x1a8\n" +
+	"\n" +
+	"FilesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a7\n" +
+	"\tDataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value:\x028\x01\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xf2\x02\n" +
+	"\x0fExecuteResponse\x12!\n" +
+	"\fexecution_id\x18\x01 \x01(\tR\vexecutionId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x16\n" +
+	"\x06output\x18\x03 \x01(\tR\x06output\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x1b\n" +
+	"\texit_code\x18\x05 \x01(\x05R\bexitCode\x129\n" +
+	"\n" +
+	"started_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12(\n" +
+	"\x05usage\x18\a \x01(\v2\x12.codeexec.v1.UsageR\x05usage\x129\n" +
+	"\vdiagnostics\x18\b \x03(\v2\x17.codeexec.v1.DiagnosticR\vdiagnostics\x12\x18\n" +
+	"\aruntime\x18\t \x01(\tR\aruntime\x12\x1f\n" +
+	"\vresult_json\x18\n" +
+	" \x01(\tR\n" +
+	"resultJson\"o\n" +
+	"\x05Usage\x12 \n" +
+	"\fwall_time_ms\x18\x01 \x01(\x03R\n" +
+	"wallTimeMs\x12\x1e\n" +
+	"\vcpu_time_ms\x18\x02 \x01(\x03R\tcpuTimeMs\x12$\n" +
+	"\x0epeak_memory_kb\x18\x03 \x01(\x03R\fpeakMemoryKb\"\x96\x01\n" +
+	"\n" +
+	"Diagnostic\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x02 \x01(\x05R\x04line\x12\x16\n" +
+	"\x06column\x18\x03 \x01(\x05R\x06column\x12\x1a\n" +
+	"\bseverity\x18\x04 \x01(\tR\bseverity\x12\x12\n" +
+	"\x04rule\x18\x05 \x01(\tR\x04rule\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\"r\n" +
+	"\x15ExecuteStreamResponse\x12\x18\n" +
+	"\x06output\x18\x01 \x01(\fH\x00R\x06output\x126\n" +
+	"\x06result\x18\x02 \x01(\v2\x1c.codeexec.v1.ExecuteResponseH\x00R\x06resultB\a\n" +
+	"\x05event\"\x16\n" +
+	"\x14ListLanguagesRequest\"L\n" +
+	"\x15ListLanguagesResponse\x123\n" +
+	"\tlanguages\x18\x01 \x03(\v2\x15.codeexec.v1.LanguageR\tlanguages\"\x84\x01\n" +
+	"\bLanguage\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05image\x18\x02 \x01(\tR\x05image\x12\x18\n" +
+	"\abackend\x18\x03 \x01(\tR\abackend\x12\x1c\n" +
+	"\tavailable\x18\x04 \x01(\bR\tavailable\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason2\x84\x02\n" +
+	"\x10ExecutionService\x12D\n" +
+	"\aExecute\x12\x1b.codeexec.v1.ExecuteRequest\x1a\x1c.codeexec.v1.ExecuteResponse\x12R\n" +
+	"\rExecuteStream\x12\x1b.codeexec.v1.ExecuteRequest\x1a\".codeexec.v1.ExecuteStreamResponse0\x01\x12V\n" +
+	"\rListLanguages\x12!.codeexec.v1.ListLanguagesRequest\x1a\".codeexec.v1.ListLanguagesResponseB*Z(code-editor/proto/codeexec/v1;codeexecv1b\x06proto3"
+
+var (
+	file_codeexec_v1_codeexec_proto_rawDescOnce sync.Once
+	file_codeexec_v1_codeexec_proto_rawDescData []byte
+)
+
+func file_codeexec_v1_codeexec_proto_rawDescGZIP() []byte {
+	file_codeexec_v1_codeexec_proto_rawDescOnce.Do(func() {
+		file_codeexec_v1_codeexec_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_codeexec_v1_codeexec_proto_rawDesc), len(file_codeexec_v1_codeexec_proto_rawDesc)))
+	})
+	return file_codeexec_v1_codeexec_proto_rawDescData
+}
+
+var file_codeexec_v1_codeexec_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_codeexec_v1_codeexec_proto_goTypes = []any{
+	(*ExecuteRequest)(nil),        // 0: codeexec.v1.ExecuteRequest
+	(*ExecuteResponse)(nil),       // 1: codeexec.v1.ExecuteResponse
+	(*Usage)(nil),                 // 2: codeexec.v1.Usage
+	(*Diagnostic)(nil),            // 3: codeexec.v1.Diagnostic
+	(*ExecuteStreamResponse)(nil), // 4: codeexec.v1.ExecuteStreamResponse
+	(*ListLanguagesRequest)(nil),  // 5: codeexec.v1.ListLanguagesRequest
+	(*ListLanguagesResponse)(nil), // 6: codeexec.v1.ListLanguagesResponse
+	(*Language)(nil),              // 7: codeexec.v1.Language
+	nil,                           // 8: codeexec.v1.ExecuteRequest.FilesEntry
+	nil,                           // 9: codeexec.v1.ExecuteRequest.DataEntry
+	nil,                           // 10: codeexec.v1.ExecuteRequest.EnvEntry
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_codeexec_v1_codeexec_proto_depIdxs = []int32{
+	8,  // 0: codeexec.v1.ExecuteRequest.files:type_name -> codeexec.v1.ExecuteRequest.FilesEntry
+	9,  // 1: codeexec.v1.ExecuteRequest.data:type_name -> codeexec.v1.ExecuteRequest.DataEntry
+	10, // 2: codeexec.v1.ExecuteRequest.env:type_name -> codeexec.v1.ExecuteRequest.EnvEntry
+	11, // 3: codeexec.v1.ExecuteResponse.started_at:type_name -> google.protobuf.Timestamp
+	2,  // 4: codeexec.v1.ExecuteResponse.usage:type_name -> codeexec.v1.Usage
+	3,  // 5: codeexec.v1.ExecuteResponse.diagnostics:type_name -> codeexec.v1.Diagnostic
+	1,  // 6: codeexec.v1.ExecuteStreamResponse.result:type_name -> codeexec.v1.ExecuteResponse
+	7,  // 7: codeexec.v1.ListLanguagesResponse.languages:type_name -> codeexec.v1.Language
+	0,  // 8: codeexec.v1.ExecutionService.Execute:input_type -> codeexec.v1.ExecuteRequest
+	0,  // 9: codeexec.v1.ExecutionService.ExecuteStream:input_type -> codeexec.v1.ExecuteRequest
+	5,  // 10: codeexec.v1.ExecutionService.ListLanguages:input_type -> codeexec.v1.ListLanguagesRequest
+	1,  // 11: codeexec.v1.ExecutionService.Execute:output_type -> codeexec.v1.ExecuteResponse
+	4,  // 12: codeexec.v1.ExecutionService.ExecuteStream:output_type -> codeexec.v1.ExecuteStreamResponse
+	6,  // 13: codeexec.v1.ExecutionService.ListLanguages:output_type -> codeexec.v1.ListLanguagesResponse
+	11, // [11:14] is the sub-list for method output_type
+	8,  // [8:11] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_codeexec_v1_codeexec_proto_init() }
+func file_codeexec_v1_codeexec_proto_init() {
+	if File_codeexec_v1_codeexec_proto != nil {
+		return
+	}
+	file_codeexec_v1_codeexec_proto_msgTypes[4].OneofWrappers = []any{
+		(*ExecuteStreamResponse_Output)(nil),
+		(*ExecuteStreamResponse_Result)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_codeexec_v1_codeexec_proto_rawDesc), len(file_codeexec_v1_codeexec_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_codeexec_v1_codeexec_proto_goTypes,
+		DependencyIndexes: file_codeexec_v1_codeexec_proto_depIdxs,
+		MessageInfos:      file_codeexec_v1_codeexec_proto_msgTypes,
+	}.Build()
+	File_codeexec_v1_codeexec_proto = out.File
+	file_codeexec_v1_codeexec_proto_goTypes = nil
+	file_codeexec_v1_codeexec_proto_depIdxs = nil
+}
@@ -0,0 +1,5 @@
+package codeexecv1
+
+// Regenerate after editing codeexec.proto; needs protoc, protoc-gen-go and
+// protoc-gen-go-grpc on the PATH.
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative codeexec/v1/codeexec.proto
@@ -0,0 +1,81 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"code-editor/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ExportFilter selects the samples to export. Zero values select everything.
+type ExportFilter struct {
+	Language string
+	Since    time.Time
+	Limit    int // Picked at random when set
+}
+
+// Export writes the matching samples from the collection as JSON lines, the
+// dataset format Load reads, and returns how many it wrote.
+func Export(ctx context.Context, samples *mongo.Collection, filter ExportFilter, w io.Writer) (int, error) {
+	match := bson.M{}
+	if filter.Language != "" {
+		match["language"] = filter.Language
+	}
+	if !filter.Since.IsZero() {
+		match["createdAt"] = bson.M{"$gte": filter.Since}
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	if filter.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sample", Value: bson.M{"size": filter.Limit}}})
+	} else {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.M{"createdAt": 1}}})
+	}
+
+	cursor, err := samples.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query samples: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	n := 0
+	for cursor.Next(ctx) {
+		var sample models.ExecutionSample
+		if err := cursor.Decode(&sample); err != nil {
+			return n, fmt.Errorf("failed to decode sample: %w", err)
+		}
+		if err := enc.Encode(sample); err != nil {
+			return n, fmt.Errorf("failed to write sample: %w", err)
+		}
+		n++
+	}
+	if err := cursor.Err(); err != nil {
+		return n, fmt.Errorf("failed to read samples: %w", err)
+	}
+	return n, out.Flush()
+}
+
+// Load reads a dataset written by Export.
+func Load(r io.Reader) ([]models.ExecutionSample, error) {
+	var samples []models.ExecutionSample
+	dec := json.NewDecoder(r)
+	for {
+		var sample models.ExecutionSample
+		err := dec.Decode(&sample)
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample %d: %w", len(samples)+1, err)
+		}
+		samples = append(samples, sample)
+	}
+}
@@ -0,0 +1,180 @@
+package replay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"code-editor/models"
+	"code-editor/sandbox"
+)
+
+// Outcomes of replaying a sample.
+const (
+	KindUnchanged = "unchanged"
+	KindChanged   = "changed"
+	// The run under the current configuration already differed from the
+	// recording, so the program is not deterministic enough to compare.
+	KindUnstable = "unstable"
+)
+
+// maxLineLength shortens the lines quoted in output differences.
+const maxLineLength = 200
+
+// Change compares the replay of a sample with its recording.
+type Change struct {
+	ExecutionID      string `json:"executionId"`
+	Language         string `json:"language"`
+	Kind             string `json:"kind"`
+	RecordedImage    string `json:"recordedImage,omitempty"`
+	RecordedStatus   string `json:"recordedStatus"`
+	Status           string `json:"status"`
+	RecordedExitCode int    `json:"recordedExitCode"`
+	ExitCode         int    `json:"exitCode"`
+	// OutputDiff points at the first line where the outputs differ.
+	OutputDiff string `json:"outputDiff,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Counts tallies the outcomes of one language.
+type Counts struct {
+	Total     int `json:"total"`
+	Unchanged int `json:"unchanged"`
+	Changed   int `json:"changed"`
+	Unstable  int `json:"unstable"`
+}
+
+// Report is the result of replaying a dataset. Changes lists every sample
+// that was not unchanged.
+type Report struct {
+	Counts
+	Languages map[string]*Counts `json:"languages"`
+	Changes   []Change           `json:"changes"`
+}
+
+// Run replays the samples under the current language configuration,
+// concurrency at a time, and returns the results in the same order.
+func Run(samples []models.ExecutionSample, concurrency int, progress func(done int)) []*sandbox.Result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]*sandbox.Result, len(samples))
+	indexes := make(chan int)
+	var mu sync.Mutex
+	done := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				s := samples[i]
+				results[i] = sandbox.Execute(sandbox.Request{
+					Owner:    "replay",
+					Language: s.Language,
+					Code:     s.Code,
+					Files:    s.Files,
+					Input:    s.Input,
+					Args:     s.Args,
+					Env:      s.Env,
+				})
+				if progress != nil {
+					mu.Lock()
+					done++
+					progress(done)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range samples {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// Compare builds the report for the candidate results of the samples. With
+// control results from the current configuration, samples whose control run
+// differs from the recording are reported as unstable instead of changed.
+func Compare(samples []models.ExecutionSample, control, candidate []*sandbox.Result) *Report {
+	report := &Report{Languages: map[string]*Counts{}}
+	for i, s := range samples {
+		change := compare(s, candidate[i])
+		if change.Kind == KindChanged && control != nil && compare(s, control[i]).Kind != KindUnchanged {
+			change.Kind = KindUnstable
+		}
+
+		counts := report.Languages[s.Language]
+		if counts == nil {
+			counts = &Counts{}
+			report.Languages[s.Language] = counts
+		}
+		for _, c := range []*Counts{&report.Counts, counts} {
+			c.Total++
+			switch change.Kind {
+			case KindUnchanged:
+				c.Unchanged++
+			case KindChanged:
+				c.Changed++
+			case KindUnstable:
+				c.Unstable++
+			}
+		}
+		if change.Kind != KindUnchanged {
+			report.Changes = append(report.Changes, change)
+		}
+	}
+	sort.SliceStable(report.Changes, func(i, j int) bool {
+		return report.Changes[i].Language < report.Changes[j].Language
+	})
+	return report
+}
+
+func compare(s models.ExecutionSample, result *sandbox.Result) Change {
+	change := Change{
+		ExecutionID:      s.ExecutionID,
+		Language:         s.Language,
+		Kind:             KindUnchanged,
+		RecordedImage:    s.Image,
+		RecordedStatus:   s.Status,
+		Status:           result.Status,
+		RecordedExitCode: s.ExitCode,
+		ExitCode:         result.ExitCode,
+		OutputDiff:       diffOutput(s.Output, result.Output),
+		Error:            result.Error,
+	}
+	if change.Status != change.RecordedStatus || change.ExitCode != change.RecordedExitCode || change.OutputDiff != "" {
+		change.Kind = KindChanged
+	}
+	return change
+}
+
+// diffOutput describes the first line where after differs from before, or
+// returns "" if they are equal.
+func diffOutput(before, after string) string {
+	if before == after {
+		return ""
+	}
+	beforeLines := strings.Split(before, "\n")
+	afterLines := strings.Split(after, "\n")
+	for i := 0; ; i++ {
+		if i >= len(beforeLines) || i >= len(afterLines) || beforeLines[i] != afterLines[i] {
+			return fmt.Sprintf("line %d: %s became %s", i+1, quoteLine(beforeLines, i), quoteLine(afterLines, i))
+		}
+	}
+}
+
+func quoteLine(lines []string, i int) string {
+	if i >= len(lines) {
+		return "(end of output)"
+	}
+	line := lines[i]
+	if len(line) > maxLineLength {
+		line = line[:maxLineLength] + "..."
+	}
+	return fmt.Sprintf("%q", line)
+}
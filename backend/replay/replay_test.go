@@ -0,0 +1,158 @@
+package replay
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"code-editor/models"
+	"code-editor/sandbox"
+)
+
+func TestDiffOutput(t *testing.T) {
+	long := strings.Repeat("a", maxLineLength+10)
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{name: "equal", before: "1\n2\n", after: "1\n2\n"},
+		{name: "both empty"},
+		{name: "changed line", before: "1\n2\n3\n", after: "1\nx\n3\n", want: `line 2: "2" became "x"`},
+		{name: "first line", before: "hello", after: "Hello", want: `line 1: "hello" became "Hello"`},
+		{name: "output got shorter", before: "1\n2\n", after: "1\n", want: `line 2: "2" became ""`},
+		{name: "missing final newline", before: "1\n", after: "1", want: `line 2: "" became (end of output)`},
+		{name: "output got longer", before: "1", after: "1\n2", want: `line 2: (end of output) became "2"`},
+		{name: "from nothing", before: "", after: "x", want: `line 1: "" became "x"`},
+		{name: "quotes control characters", before: "a\tb", after: "a b", want: `line 1: "a\tb" became "a b"`},
+		{name: "long lines are shortened", before: long, after: long + "b",
+			want: `line 1: "` + long[:maxLineLength] + `..." became "` + long[:maxLineLength] + `..."`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffOutput(tt.before, tt.after); got != tt.want {
+				t.Errorf("diffOutput(%q, %q) = %s, want %s", tt.before, tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	sample := func(id, language, output string) models.ExecutionSample {
+		return models.ExecutionSample{ExecutionID: id, Language: language, Image: language + ":1", Status: sandbox.StatusSuccess, Output: output}
+	}
+	result := func(output string) *sandbox.Result {
+		return &sandbox.Result{Status: sandbox.StatusSuccess, Output: output}
+	}
+	failed := &sandbox.Result{Status: sandbox.StatusError, ExitCode: 1, Output: "ok\n", Error: "exit status 1"}
+
+	tests := []struct {
+		name      string
+		samples   []models.ExecutionSample
+		control   []*sandbox.Result
+		candidate []*sandbox.Result
+		want      *Report
+	}{
+		{
+			name:      "unchanged",
+			samples:   []models.ExecutionSample{sample("a", "go", "ok\n")},
+			candidate: []*sandbox.Result{result("ok\n")},
+			want: &Report{
+				Counts:    Counts{Total: 1, Unchanged: 1},
+				Languages: map[string]*Counts{"go": {Total: 1, Unchanged: 1}},
+			},
+		},
+		{
+			name:      "changed output without a control run",
+			samples:   []models.ExecutionSample{sample("a", "go", "ok\n")},
+			candidate: []*sandbox.Result{result("no\n")},
+			want: &Report{
+				Counts:    Counts{Total: 1, Changed: 1},
+				Languages: map[string]*Counts{"go": {Total: 1, Changed: 1}},
+				Changes: []Change{{ExecutionID: "a", Language: "go", Kind: KindChanged, RecordedImage: "go:1",
+					RecordedStatus: sandbox.StatusSuccess, Status: sandbox.StatusSuccess, OutputDiff: `line 1: "ok" became "no"`}},
+			},
+		},
+		{
+			name:      "changed status and exit code with the same output",
+			samples:   []models.ExecutionSample{sample("a", "python", "ok\n")},
+			candidate: []*sandbox.Result{failed},
+			want: &Report{
+				Counts:    Counts{Total: 1, Changed: 1},
+				Languages: map[string]*Counts{"python": {Total: 1, Changed: 1}},
+				Changes: []Change{{ExecutionID: "a", Language: "python", Kind: KindChanged, RecordedImage: "python:1",
+					RecordedStatus: sandbox.StatusSuccess, Status: sandbox.StatusError, ExitCode: 1, Error: "exit status 1"}},
+			},
+		},
+		{
+			name:      "stable control keeps the change",
+			samples:   []models.ExecutionSample{sample("a", "go", "ok\n")},
+			control:   []*sandbox.Result{result("ok\n")},
+			candidate: []*sandbox.Result{result("no\n")},
+			want: &Report{
+				Counts:    Counts{Total: 1, Changed: 1},
+				Languages: map[string]*Counts{"go": {Total: 1, Changed: 1}},
+				Changes: []Change{{ExecutionID: "a", Language: "go", Kind: KindChanged, RecordedImage: "go:1",
+					RecordedStatus: sandbox.StatusSuccess, Status: sandbox.StatusSuccess, OutputDiff: `line 1: "ok" became "no"`}},
+			},
+		},
+		{
+			name:      "differing control makes the change unstable",
+			samples:   []models.ExecutionSample{sample("a", "go", "1\n")},
+			control:   []*sandbox.Result{result("2\n")},
+			candidate: []*sandbox.Result{result("3\n")},
+			want: &Report{
+				Counts:    Counts{Total: 1, Unstable: 1},
+				Languages: map[string]*Counts{"go": {Total: 1, Unstable: 1}},
+				Changes: []Change{{ExecutionID: "a", Language: "go", Kind: KindUnstable, RecordedImage: "go:1",
+					RecordedStatus: sandbox.StatusSuccess, Status: sandbox.StatusSuccess, OutputDiff: `line 1: "1" became "3"`}},
+			},
+		},
+		{
+			name:      "differing control does not matter when the candidate is unchanged",
+			samples:   []models.ExecutionSample{sample("a", "go", "1\n")},
+			control:   []*sandbox.Result{result("2\n")},
+			candidate: []*sandbox.Result{result("1\n")},
+			want: &Report{
+				Counts:    Counts{Total: 1, Unchanged: 1},
+				Languages: map[string]*Counts{"go": {Total: 1, Unchanged: 1}},
+			},
+		},
+		{
+			name: "counts per language and changes sorted by language",
+			samples: []models.ExecutionSample{
+				sample("a", "python", "1\n"), sample("b", "go", "1\n"), sample("c", "go", "1\n"), sample("d", "cpp", "1\n"),
+			},
+			candidate: []*sandbox.Result{result("2\n"), result("1\n"), result("2\n"), result("2\n")},
+			want: &Report{
+				Counts: Counts{Total: 4, Unchanged: 1, Changed: 3},
+				Languages: map[string]*Counts{
+					"python": {Total: 1, Changed: 1},
+					"go":     {Total: 2, Unchanged: 1, Changed: 1},
+					"cpp":    {Total: 1, Changed: 1},
+				},
+				Changes: []Change{
+					{ExecutionID: "d", Language: "cpp", Kind: KindChanged, RecordedImage: "cpp:1", RecordedStatus: sandbox.StatusSuccess,
+						Status: sandbox.StatusSuccess, OutputDiff: `line 1: "1" became "2"`},
+					{ExecutionID: "c", Language: "go", Kind: KindChanged, RecordedImage: "go:1", RecordedStatus: sandbox.StatusSuccess,
+						Status: sandbox.StatusSuccess, OutputDiff: `line 1: "1" became "2"`},
+					{ExecutionID: "a", Language: "python", Kind: KindChanged, RecordedImage: "python:1", RecordedStatus: sandbox.StatusSuccess,
+						Status: sandbox.StatusSuccess, OutputDiff: `line 1: "1" became "2"`},
+				},
+			},
+		},
+		{
+			name:    "no samples",
+			samples: nil,
+			want:    &Report{Languages: map[string]*Counts{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(tt.samples, tt.control, tt.candidate)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
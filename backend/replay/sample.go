@@ -0,0 +1,92 @@
+// Package replay samples executions with their code and output and replays
+// them against another language configuration, typically a newer image, to
+// find programs whose results change.
+package replay
+
+import (
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"code-editor/models"
+	"code-editor/sandbox"
+)
+
+// maxSampleSize keeps samples well below MongoDB's document limit; larger
+// runs are not sampled.
+const maxSampleSize = 1 << 20
+
+var (
+	rateOnce   sync.Once
+	sampleRate float64
+)
+
+// SampleRate is the fraction of plain runs that are sampled, set by
+// EXECUTION_SAMPLE_RATE between 0 (the default) and 1.
+func SampleRate() float64 {
+	rateOnce.Do(func() {
+		v := os.Getenv("EXECUTION_SAMPLE_RATE")
+		if v == "" {
+			return
+		}
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			log.Printf("Ignoring EXECUTION_SAMPLE_RATE %q: must be between 0 and 1", v)
+			return
+		}
+		sampleRate = rate
+	})
+	return sampleRate
+}
+
+// Sample returns a sample of the finished run, or nil if it is not picked.
+// Only runs that can be replayed offline are eligible: run mode without
+// network access or data files, which ran to completion.
+func Sample(req sandbox.Request, result *sandbox.Result) *models.ExecutionSample {
+	rate := SampleRate()
+	if rate == 0 || rand.Float64() >= rate {
+		return nil
+	}
+	if (req.Mode != "" && req.Mode != sandbox.ModeRun) || req.Network || len(req.Data) > 0 {
+		return nil
+	}
+	// Runs without usage never started, e.g. because the request was invalid
+	if result.Usage == nil || (result.Status != sandbox.StatusSuccess && result.Status != sandbox.StatusError) {
+		return nil
+	}
+
+	size := len(req.Code) + len(req.Input) + len(result.Output)
+	for name, content := range req.Files {
+		size += len(name) + len(content)
+	}
+	if size > maxSampleSize {
+		return nil
+	}
+
+	return &models.ExecutionSample{
+		ExecutionID: result.ID,
+		Language:    req.Language,
+		Image:       imageOf(req.Language),
+		Code:        req.Code,
+		Files:       req.Files,
+		Input:       req.Input,
+		Args:        req.Args,
+		Env:         req.Env,
+		Status:      result.Status,
+		ExitCode:    result.ExitCode,
+		Output:      result.Output,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func imageOf(language string) string {
+	for _, st := range sandbox.LanguageStatuses() {
+		if st.Name == language {
+			return st.Image
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits on the arguments and environment passed to a program.
+const (
+	maxArgs       = 64
+	maxEnvVars    = 64
+	maxArgsEnvSz  = 32 << 10 // 32 KiB for all arguments and variables together
+	maxArgOrValSz = 4 << 10
+)
+
+var ErrInvalidArgs = errors.New("invalid args or env")
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Variables that would change how the shell, the dynamic linker or a
+// toolchain behaves rather than the program itself. The whole GO prefix is
+// reserved because it steers the Go toolchain and runtime alike.
+var (
+	deniedEnv         = []string{"PATH", "HOME", "PWD", "OLDPWD", "SHELL", "IFS", "ENV", "BASH_ENV", "HOSTNAME"}
+	deniedEnvPrefixes = []string{"LD_", "DYLD_", "GO", "CGO_", "CODEEXEC"}
+)
+
+// ValidateArgsEnv checks program arguments and environment variables
+// against the size limits and the denylist.
+func ValidateArgsEnv(args []string, env map[string]string) error {
+	if len(args) > maxArgs {
+		return fmt.Errorf("%w: at most %d args are allowed", ErrInvalidArgs, maxArgs)
+	}
+	if len(env) > maxEnvVars {
+		return fmt.Errorf("%w: at most %d env variables are allowed", ErrInvalidArgs, maxEnvVars)
+	}
+	total := 0
+	for _, arg := range args {
+		if len(arg) > maxArgOrValSz || strings.ContainsRune(arg, 0) {
+			return fmt.Errorf("%w: args must be under %d bytes and free of NUL", ErrInvalidArgs, maxArgOrValSz)
+		}
+		total += len(arg)
+	}
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: %q is not a valid variable name", ErrInvalidArgs, name)
+		}
+		if deniedEnvName(name) {
+			return fmt.Errorf("%w: %s may not be set", ErrInvalidArgs, name)
+		}
+		if len(value) > maxArgOrValSz || strings.ContainsRune(value, 0) {
+			return fmt.Errorf("%w: %s must be under %d bytes and free of NUL", ErrInvalidArgs, name, maxArgOrValSz)
+		}
+		total += len(name) + len(value)
+	}
+	if total > maxArgsEnvSz {
+		return fmt.Errorf("%w: args and env exceed %d bytes in total", ErrInvalidArgs, maxArgsEnvSz)
+	}
+	return nil
+}
+
+func deniedEnvName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, denied := range deniedEnv {
+		if upper == denied {
+			return true
+		}
+	}
+	for _, prefix := range deniedEnvPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// runWith wraps the run command so that args are appended to it and env is
+// set for it alone, leaving the compile step untouched.
+func runWith(run string, args []string, env map[string]string) string {
+	if len(args) == 0 && len(env) == 0 {
+		return run
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("env")
+	for _, name := range names {
+		b.WriteString(" '" + shellQuote(name+"="+env[name]) + "'")
+	}
+	b.WriteString(` sh -c '` + shellQuote(run+` "$@"`) + `' sh`)
+	for _, arg := range args {
+		b.WriteString(" '" + shellQuote(arg) + "'")
+	}
+	return b.String()
+}
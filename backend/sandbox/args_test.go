@@ -0,0 +1,85 @@
+package sandbox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateArgsEnv(t *testing.T) {
+	many := func(n int) []string { return make([]string, n) }
+	manyEnv := func(n int) map[string]string {
+		env := map[string]string{}
+		for i := 0; i < n; i++ {
+			env["V"+strings.Repeat("X", i)] = "1"
+		}
+		return env
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr bool
+	}{
+		{name: "nothing"},
+		{name: "plain args and env", args: []string{"-n", "3", "hello world", "it's"}, env: map[string]string{"MODE": "fast", "_x1": ""}},
+		{name: "too many args", args: many(maxArgs + 1), wantErr: true},
+		{name: "too many variables", env: manyEnv(maxEnvVars + 1), wantErr: true},
+		{name: "arg too long", args: []string{strings.Repeat("a", maxArgOrValSz+1)}, wantErr: true},
+		{name: "NUL in arg", args: []string{"a\x00b"}, wantErr: true},
+		{name: "NUL in value", env: map[string]string{"A": "a\x00b"}, wantErr: true},
+		{name: "value too long", env: map[string]string{"A": strings.Repeat("a", maxArgOrValSz+1)}, wantErr: true},
+		{name: "total too large", args: []string{strings.Repeat("a", maxArgOrValSz), strings.Repeat("a", maxArgOrValSz), strings.Repeat("a", maxArgOrValSz)},
+			env: map[string]string{"A": strings.Repeat("a", maxArgOrValSz), "B": strings.Repeat("a", maxArgOrValSz), "C": strings.Repeat("a", maxArgOrValSz),
+				"D": strings.Repeat("a", maxArgOrValSz), "E": strings.Repeat("a", maxArgOrValSz), "F": strings.Repeat("a", maxArgOrValSz)}, wantErr: true},
+		{name: "name starting with a digit", env: map[string]string{"1A": "x"}, wantErr: true},
+		{name: "name with a dash", env: map[string]string{"A-B": "x"}, wantErr: true},
+		{name: "name with shell syntax", env: map[string]string{"A;rm": "x"}, wantErr: true},
+		{name: "empty name", env: map[string]string{"": "x"}, wantErr: true},
+		{name: "PATH", env: map[string]string{"PATH": "/tmp"}, wantErr: true},
+		{name: "denylist ignores case", env: map[string]string{"path": "/tmp"}, wantErr: true},
+		{name: "HOME", env: map[string]string{"HOME": "/"}, wantErr: true},
+		{name: "IFS", env: map[string]string{"IFS": ":"}, wantErr: true},
+		{name: "BASH_ENV", env: map[string]string{"BASH_ENV": "/tmp/x"}, wantErr: true},
+		{name: "LD_PRELOAD", env: map[string]string{"LD_PRELOAD": "/tmp/x.so"}, wantErr: true},
+		{name: "DYLD_ prefix", env: map[string]string{"DYLD_INSERT_LIBRARIES": "x"}, wantErr: true},
+		{name: "GO prefix", env: map[string]string{"GOFLAGS": "-toolexec=x"}, wantErr: true},
+		{name: "GODEBUG", env: map[string]string{"GODEBUG": "x"}, wantErr: true},
+		{name: "CGO_ prefix", env: map[string]string{"CGO_CFLAGS": "x"}, wantErr: true},
+		{name: "CODEEXEC prefix", env: map[string]string{"CODEEXEC_PROXY": "x"}, wantErr: true},
+		{name: "prefix only at the start", env: map[string]string{"MY_LD_PATH": "x", "PATHS": "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateArgsEnv(tt.args, tt.env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateArgsEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidArgs) {
+				t.Errorf("ValidateArgsEnv() error = %v, want ErrInvalidArgs", err)
+			}
+		})
+	}
+}
+
+func TestRunWith(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{name: "unchanged without args and env", want: "./main"},
+		{name: "args are quoted", args: []string{"a b", "it's", "$HOME"},
+			want: `env sh -c './main "$@"' sh 'a b' 'it'\''s' '$HOME'`},
+		{name: "env is sorted and quoted", env: map[string]string{"B": "2", "A": "x'y"},
+			want: `env 'A=x'\''y' 'B=2' sh -c './main "$@"' sh`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runWith("./main", tt.args, tt.env); got != tt.want {
+				t.Errorf("runWith() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
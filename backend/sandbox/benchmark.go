@@ -0,0 +1,276 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Benchmark limits. Every run gets the same container limits as a normal
+// execution; the timeout covers all runs of one submission.
+const (
+	benchmarkTimeout = 90 * time.Second
+	defaultRuns      = 10
+	maxRuns          = 50
+	defaultWarmup    = 1
+	maxWarmup        = 5
+
+	benchmarkFile = ".codeexec-bench"
+	inputFile     = ".codeexec-input"
+)
+
+var errNoTimings = errors.New("no timings recorded; the image needs a time utility")
+
+// Stats summarises one measurement over all benchmark runs.
+type Stats struct {
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+}
+
+// BenchmarkStats are the timings of one submission. Wall time has the 10ms
+// resolution of `time`.
+type BenchmarkStats struct {
+	Runs         int   `json:"runs"`
+	WallTimeMs   Stats `json:"wallTimeMs"`
+	CPUTimeMs    Stats `json:"cpuTimeMs"`
+	PeakMemoryKB Stats `json:"peakMemoryKb"`
+
+	wall []float64
+}
+
+// Comparison relates a submission to a baseline. Speedup is the baseline's
+// median wall time divided by the submission's, so values above 1 mean the
+// submission is faster. Confidence is derived from a Mann-Whitney U test on
+// the wall times: "high" for p < 0.01, "medium" for p < 0.05, else "low".
+type Comparison struct {
+	Baseline   *BenchmarkStats `json:"baseline"`
+	Speedup    float64         `json:"speedup"`
+	PValue     float64         `json:"pValue"`
+	Confidence string          `json:"confidence"`
+}
+
+// benchmarkCommand compiles once, runs the program (run, the language's Run
+// with any args and env) warmup times untimed and then runs times, appending
+// each run's usage to benchmarkFile. Standard output is discarded; every run
+// reads the same input from inputFile.
+func benchmarkCommand(lang Language, run string, runs, warmup int) string {
+	loop := fmt.Sprintf(`i=0; while [ $i -lt %d ]; do sh -c '%s' < %s > /dev/null || exit $?; i=$((i+1)); done; `+
+		`i=0; while [ $i -lt %d ]; do /usr/bin/time -a -o %s -f "%%e %%U %%S %%M" sh -c '%s' < %s > /dev/null || exit $?; i=$((i+1)); done`,
+		warmup, shellQuote(run), inputFile, runs, benchmarkFile, shellQuote(run), inputFile)
+	if lang.Compile == "" {
+		return loop
+	}
+	return lang.Compile + " && { " + loop + "; }"
+}
+
+// shellQuote escapes s for use inside single quotes.
+func shellQuote(s string) string {
+	return strings.ReplaceAll(s, `'`, `'\''`)
+}
+
+// benchmarkRuns clamps the requested run and warm-up counts.
+func benchmarkRuns(runs, warmup int) (int, int) {
+	if runs <= 0 {
+		runs = defaultRuns
+	}
+	if runs > maxRuns {
+		runs = maxRuns
+	}
+	if warmup <= 0 {
+		warmup = defaultWarmup
+	}
+	if warmup > maxWarmup {
+		warmup = maxWarmup
+	}
+	return runs, warmup
+}
+
+// parseBenchmark reads the usage lines appended by benchmarkCommand. Lines
+// that are not four numbers, such as busybox's exit status notes, are
+// skipped. Memory is clamped to the container limit like readPeakMemory does.
+func parseBenchmark(data []byte) (*BenchmarkStats, error) {
+	var wall, cpu, mem []float64
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		var values [4]float64
+		valid := true
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil || v < 0 {
+				valid = false
+				break
+			}
+			values[i] = v
+		}
+		if !valid {
+			continue
+		}
+		wall = append(wall, values[0]*1000)
+		cpu = append(cpu, (values[1]+values[2])*1000)
+		mem = append(mem, math.Min(values[3], memoryLimitKB))
+	}
+	if len(wall) == 0 {
+		return nil, errNoTimings
+	}
+	return &BenchmarkStats{
+		Runs:         len(wall),
+		WallTimeMs:   summarise(wall),
+		CPUTimeMs:    summarise(cpu),
+		PeakMemoryKB: summarise(mem),
+		wall:         wall,
+	}, nil
+}
+
+func summarise(values []float64) Stats {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return Stats{
+		Min:    sorted[0],
+		Median: percentile(sorted, 0.5),
+		P95:    percentile(sorted, 0.95),
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// percentile interpolates linearly between the closest ranks of sorted.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lower := int(pos)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// compare relates stats to baseline.
+func compare(stats, baseline *BenchmarkStats) *Comparison {
+	c := &Comparison{Baseline: baseline, PValue: mannWhitney(stats.wall, baseline.wall)}
+	if stats.WallTimeMs.Median > 0 {
+		c.Speedup = math.Round(baseline.WallTimeMs.Median/stats.WallTimeMs.Median*100) / 100
+	}
+	switch {
+	case c.PValue < 0.01:
+		c.Confidence = "high"
+	case c.PValue < 0.05:
+		c.Confidence = "medium"
+	default:
+		c.Confidence = "low"
+	}
+	return c
+}
+
+// mannWhitney returns the two-sided p-value of a Mann-Whitney U test using
+// the normal approximation with tie correction. Samples too small for the
+// approximation report 1.
+func mannWhitney(a, b []float64) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if len(a) < 5 || len(b) < 5 {
+		return 1
+	}
+
+	type sample struct {
+		value float64
+		fromA bool
+	}
+	all := make([]sample, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range b {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].value < all[j].value })
+
+	// Tied values share the average of their ranks
+	var rankSumA, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].fromA {
+				rankSumA += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	u := rankSumA - n1*(n1+1)/2
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := math.Abs(u-n1*n2/2) / math.Sqrt(variance)
+	return math.Round(math.Erfc(z/math.Sqrt2)*10000) / 10000
+}
+
+// benchmark runs the submission and, if given, the baseline one after the
+// other under identical limits. Usage covers both.
+func benchmark(req Request, lang Language, spec containerSpec) *Result {
+	res := &Result{ID: req.ID, StartedAt: time.Now(), Usage: &Usage{}, Runtime: runtimeName(lang)}
+	runs, warmup := benchmarkRuns(req.Runs, req.Warmup)
+	spec.command = benchmarkCommand(lang, runWith(lang.Run, req.Args, req.Env), runs, warmup)
+	spec.files[inputFile] = spec.input
+	spec.input = ""
+	spec.collect = []string{benchmarkFile}
+
+	measure := func(code string) (*BenchmarkStats, error) {
+		spec.files[lang.Filename] = code
+		out, err := runOnce(req.ID, req.Owner, benchmarkTimeout, spec)
+		res.Output += out.output
+		if out.usage != nil {
+			res.Usage.WallTimeMs += out.usage.WallTimeMs
+			res.Usage.CPUTimeMs += out.usage.CPUTimeMs
+			res.Usage.PeakMemoryKB = max(res.Usage.PeakMemoryKB, out.usage.PeakMemoryKB)
+		}
+		if err != nil {
+			res.Status, res.ExitCode = statusOf(err)
+			res.Error = err.Error()
+			if res.Status == StatusTimeout {
+				res.Error = "benchmark timed out"
+			}
+			return nil, err
+		}
+		return parseBenchmark(out.files[benchmarkFile])
+	}
+
+	stats, err := measure(req.Code)
+	if err != nil {
+		if res.Status == "" {
+			res.Status, res.Error = StatusError, err.Error()
+		}
+		if res.Status == StatusError && res.ExitCode != 0 {
+			res.Diagnostics = compileDiagnostics(lang, res.Output)
+		}
+		return res
+	}
+	res.Benchmark = stats
+
+	if req.Baseline != "" {
+		baseline, err := measure(req.Baseline)
+		if err != nil {
+			if res.Status == "" {
+				res.Status = StatusError
+			}
+			res.Error = "baseline: " + err.Error()
+			return res
+		}
+		res.Comparison = compare(stats, baseline)
+	}
+	res.Status = StatusSuccess
+	return res
+}
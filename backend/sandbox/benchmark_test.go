@@ -0,0 +1,177 @@
+package sandbox
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummarise(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   Stats
+	}{
+		{"single value", []float64{7}, Stats{Min: 7, Median: 7, P95: 7, Max: 7}},
+		{"odd count", []float64{30, 10, 20}, Stats{Min: 10, Median: 20, P95: 29, Max: 30}},
+		{"even count interpolates the median", []float64{4, 1, 3, 2}, Stats{Min: 1, Median: 2.5, P95: 3.85, Max: 4}},
+		{"equal values", []float64{5, 5, 5, 5}, Stats{Min: 5, Median: 5, P95: 5, Max: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]float64(nil), tt.values...)
+			got := summarise(input)
+			if !approxStats(got, tt.want) {
+				t.Errorf("summarise(%v) = %+v, want %+v", tt.values, got, tt.want)
+			}
+			if !reflect.DeepEqual(input, tt.values) {
+				t.Errorf("summarise sorted its input: %v", input)
+			}
+		})
+	}
+}
+
+func approxStats(a, b Stats) bool {
+	near := func(x, y float64) bool { return x-y < 1e-9 && y-x < 1e-9 }
+	return near(a.Min, b.Min) && near(a.Median, b.Median) && near(a.P95, b.P95) && near(a.Max, b.Max)
+}
+
+func TestParseBenchmark(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantRuns int
+		wantWall Stats
+		wantMem  float64 // Max peak memory
+		wantErr  error
+	}{
+		{
+			name:     "runs",
+			data:     "0.10 0.08 0.01 1000\n0.20 0.15 0.02 2000\n0.30 0.25 0.03 3000\n",
+			wantRuns: 3,
+			wantWall: Stats{Min: 100, Median: 200, P95: 290, Max: 300},
+			wantMem:  3000,
+		},
+		{
+			name:     "busybox notes and garbage are skipped",
+			data:     "Command exited with non-zero status 1\n0.10 0.08 0.01 1000\nx y z w\n0.10 -1 0 5\n\n",
+			wantRuns: 1,
+			wantWall: Stats{Min: 100, Median: 100, P95: 100, Max: 100},
+			wantMem:  1000,
+		},
+		{
+			name:     "memory is clamped to the container limit",
+			data:     "0.10 0.08 0.01 999999999999\n",
+			wantRuns: 1,
+			wantWall: Stats{Min: 100, Median: 100, P95: 100, Max: 100},
+			wantMem:  memoryLimitKB,
+		},
+		{name: "empty", data: "", wantErr: errNoTimings},
+		{name: "only garbage", data: "nan\n1 2 3\n", wantErr: errNoTimings},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := parseBenchmark([]byte(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseBenchmark() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if stats.Runs != tt.wantRuns || !approxStats(stats.WallTimeMs, tt.wantWall) || stats.PeakMemoryKB.Max != tt.wantMem {
+				t.Errorf("parseBenchmark() = %d runs, wall %+v, memory %v; want %d, %+v, %v",
+					stats.Runs, stats.WallTimeMs, stats.PeakMemoryKB.Max, tt.wantRuns, tt.wantWall, tt.wantMem)
+			}
+		})
+	}
+}
+
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+	}{
+		{"separated samples", []float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 0.009},
+		{"order does not matter", []float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}, 0.009},
+		{"interleaved samples", []float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}, 0.6015},
+		{"ties share ranks", []float64{1, 1, 2, 2, 3}, []float64{2, 3, 3, 4, 4}, 0.0406},
+		{"larger samples", []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, []float64{20, 21, 22, 23, 24, 25, 26, 27, 28, 29}, 0.0002},
+		{"identical samples have no variance", []float64{5, 5, 5, 5, 5}, []float64{5, 5, 5, 5, 5}, 1},
+		{"too few runs", []float64{1, 2, 3, 4}, []float64{6, 7, 8, 9, 10}, 1},
+		{"empty", nil, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mannWhitney(tt.a, tt.b); got != tt.want {
+				t.Errorf("mannWhitney() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	stats := func(wall ...float64) *BenchmarkStats {
+		return &BenchmarkStats{Runs: len(wall), WallTimeMs: summarise(wall), wall: wall}
+	}
+	tests := []struct {
+		name           string
+		stats, base    *BenchmarkStats
+		wantSpeedup    float64
+		wantConfidence string
+	}{
+		{"faster", stats(10, 10, 11, 11, 12, 12, 10, 11, 12, 10), stats(20, 21, 22, 20, 21, 22, 20, 21, 22, 20), 1.91, "high"},
+		{"slower", stats(40, 41, 42, 43, 44), stats(20, 21, 22, 23, 24), 0.52, "high"},
+		{"probably faster", stats(1, 1, 2, 2, 3), stats(2, 3, 3, 4, 4), 1.5, "medium"},
+		{"no difference", stats(1, 3, 5, 7, 9), stats(2, 4, 6, 8, 10), 1.2, "low"},
+		{"zero median", stats(0, 0, 0, 0, 0), stats(1, 1, 1, 1, 1), 0, "high"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := compare(tt.stats, tt.base)
+			if c.Speedup != tt.wantSpeedup || c.Confidence != tt.wantConfidence {
+				t.Errorf("compare() = speedup %v, confidence %s (p %v); want %v, %s",
+					c.Speedup, c.Confidence, c.PValue, tt.wantSpeedup, tt.wantConfidence)
+			}
+		})
+	}
+}
+
+func TestBenchmarkRuns(t *testing.T) {
+	tests := []struct {
+		runs, warmup         int
+		wantRuns, wantWarmup int
+	}{
+		{0, 0, defaultRuns, defaultWarmup},
+		{-3, -1, defaultRuns, defaultWarmup},
+		{7, 2, 7, 2},
+		{1000, 1000, maxRuns, maxWarmup},
+	}
+	for _, tt := range tests {
+		runs, warmup := benchmarkRuns(tt.runs, tt.warmup)
+		if runs != tt.wantRuns || warmup != tt.wantWarmup {
+			t.Errorf("benchmarkRuns(%d, %d) = %d, %d; want %d, %d", tt.runs, tt.warmup, runs, warmup, tt.wantRuns, tt.wantWarmup)
+		}
+	}
+}
+
+func TestBenchmarkCommandTimesOnlyTheRunStep(t *testing.T) {
+	for _, lang := range defaultLanguages {
+		if lang.Run == "" {
+			continue
+		}
+		t.Run(lang.Name, func(t *testing.T) {
+			cmd := benchmarkCommand(lang, lang.Run, 3, 1)
+			timed := cmd[strings.Index(cmd, "/usr/bin/time"):]
+			if lang.Compile != "" && strings.Contains(timed, lang.Compile) {
+				t.Errorf("compile step is timed: %s", cmd)
+			}
+			for _, compiler := range []string{"go run", "go build", "javac", "rustc", "gcc", "g++", "kotlinc", "tsc", "dotnet build"} {
+				if strings.Contains(lang.Run, compiler) {
+					t.Errorf("run step %q compiles with %s; move it to Compile", lang.Run, compiler)
+				}
+			}
+		})
+	}
+}
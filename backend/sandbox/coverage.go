@@ -0,0 +1,246 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrNoCoverage = errors.New("no coverage tool configured for this language")
+
+// maxCoverageLines caps the executable lines kept per file. The report is
+// written inside the sandbox, so its line ranges cannot be trusted.
+const maxCoverageLines = 100000
+
+// CoverageReport is line coverage in the same shape for every language.
+type CoverageReport struct {
+	Lines   int            `json:"lines"`   // Executable lines
+	Covered int            `json:"covered"` // Executable lines run at least once
+	Percent float64        `json:"percent"`
+	Files   []FileCoverage `json:"files"`
+}
+
+// FileCoverage holds the hit counts of one file's executable lines as
+// [line, hits] pairs sorted by line. Lines missing from Hits are not
+// executable. Tools that only record whether a line ran report 1 hit.
+type FileCoverage struct {
+	File    string   `json:"file"`
+	Lines   int      `json:"lines"`
+	Covered int      `json:"covered"`
+	Percent float64  `json:"percent"`
+	Hits    [][2]int `json:"hits"`
+}
+
+// lineHits collects hit counts per file and line while a report is parsed.
+// A line covered by several blocks or statements keeps the highest count.
+type lineHits map[string]map[int]int
+
+func (h lineHits) add(file string, line, hits int) {
+	if line <= 0 {
+		return
+	}
+	lines, ok := h[file]
+	if !ok {
+		lines = map[int]int{}
+		h[file] = lines
+	}
+	prev, ok := lines[line]
+	if !ok && len(lines) >= maxCoverageLines {
+		return
+	}
+	if !ok || hits > prev {
+		lines[line] = hits
+	}
+}
+
+// clamp drops lines past the end of the submitted source files, which a
+// tampered report may claim, and recomputes the totals.
+func (r *CoverageReport) clamp(sources map[string]string) {
+	r.Lines, r.Covered = 0, 0
+	for i := range r.Files {
+		fc := &r.Files[i]
+		if source, ok := sources[fc.File]; ok {
+			count := strings.Count(source, "\n") + 1
+			kept := fc.Hits[:0]
+			for _, h := range fc.Hits {
+				if h[0] <= count {
+					kept = append(kept, h)
+				}
+			}
+			fc.Hits = kept
+			fc.Lines, fc.Covered = len(kept), 0
+			for _, h := range kept {
+				if h[1] > 0 {
+					fc.Covered++
+				}
+			}
+			fc.Percent = percent(fc.Covered, fc.Lines)
+		}
+		r.Lines += fc.Lines
+		r.Covered += fc.Covered
+	}
+	r.Percent = percent(r.Covered, r.Lines)
+}
+
+func (h lineHits) report() *CoverageReport {
+	report := &CoverageReport{Files: []FileCoverage{}}
+	for file, lines := range h {
+		fc := FileCoverage{File: file, Lines: len(lines), Hits: make([][2]int, 0, len(lines))}
+		for line, hits := range lines {
+			if hits > 0 {
+				fc.Covered++
+			}
+			fc.Hits = append(fc.Hits, [2]int{line, hits})
+		}
+		sort.Slice(fc.Hits, func(i, j int) bool { return fc.Hits[i][0] < fc.Hits[j][0] })
+		fc.Percent = percent(fc.Covered, fc.Lines)
+		report.Lines += fc.Lines
+		report.Covered += fc.Covered
+		report.Files = append(report.Files, fc)
+	}
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].File < report.Files[j].File })
+	report.Percent = percent(report.Covered, report.Lines)
+	return report
+}
+
+// percent rounds to one decimal place.
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(covered)*1000/float64(total)) / 10
+}
+
+// coverageParsers are referenced by name from the coverage tool's Parser.
+var coverageParsers = map[string]func([]byte) (*CoverageReport, error){
+	"go-cover":    parseGoCoverProfile,
+	"coverage-py": parseCoveragePyJSON,
+	"gcovr":       parseGcovrJSON,
+	"istanbul":    parseIstanbulJSON,
+}
+
+// parseGoCoverProfile reads a `go test -coverprofile` file:
+//
+//	mode: count
+//	main.go:5.13,7.2 1 3
+func parseGoCoverProfile(data []byte) (*CoverageReport, error) {
+	hits := lineHits{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			continue
+		}
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			continue
+		}
+		start, end, ok := strings.Cut(fields[0], ",")
+		if !ok {
+			continue
+		}
+		startLine, _, _ := strings.Cut(start, ".")
+		endLine, _, _ := strings.Cut(end, ".")
+		first, last := atoi(startLine), atoi(endLine)
+		if first < 1 || last < first {
+			continue
+		}
+		last = min(last, first+maxCoverageLines-1)
+		count, _ := strconv.Atoi(fields[2])
+		for ln := first; ln <= last; ln++ {
+			hits.add(cleanPath(line[:colon]), ln, count)
+		}
+	}
+	return hits.report(), scanner.Err()
+}
+
+// parseCoveragePyJSON reads `coverage json` output. coverage.py does not
+// count hits, so executed lines report one.
+func parseCoveragePyJSON(data []byte) (*CoverageReport, error) {
+	var cov struct {
+		Files map[string]struct {
+			ExecutedLines []int `json:"executed_lines"`
+			MissingLines  []int `json:"missing_lines"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &cov); err != nil {
+		return nil, err
+	}
+
+	hits := lineHits{}
+	for file, lines := range cov.Files {
+		for _, ln := range lines.ExecutedLines {
+			hits.add(cleanPath(file), ln, 1)
+		}
+		for _, ln := range lines.MissingLines {
+			hits.add(cleanPath(file), ln, 0)
+		}
+	}
+	return hits.report(), nil
+}
+
+// parseGcovrJSON reads the JSON report gcovr builds from gcov data.
+func parseGcovrJSON(data []byte) (*CoverageReport, error) {
+	var cov struct {
+		Files []struct {
+			File  string `json:"file"`
+			Lines []struct {
+				LineNumber int  `json:"line_number"`
+				Count      int  `json:"count"`
+				NonCode    bool `json:"gcovr/noncode"`
+			} `json:"lines"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &cov); err != nil {
+		return nil, err
+	}
+
+	hits := lineHits{}
+	for _, f := range cov.Files {
+		for _, ln := range f.Lines {
+			if !ln.NonCode {
+				hits.add(cleanPath(f.File), ln.LineNumber, ln.Count)
+			}
+		}
+	}
+	return hits.report(), nil
+}
+
+// parseIstanbulJSON reads coverage-final.json as written by c8 and other
+// istanbul reporters. Like istanbul, a statement counts for the line it
+// starts on.
+func parseIstanbulJSON(data []byte) (*CoverageReport, error) {
+	var cov map[string]struct {
+		Path         string `json:"path"`
+		StatementMap map[string]struct {
+			Start struct {
+				Line int `json:"line"`
+			} `json:"start"`
+		} `json:"statementMap"`
+		S map[string]int `json:"s"`
+	}
+	if err := json.Unmarshal(data, &cov); err != nil {
+		return nil, err
+	}
+
+	hits := lineHits{}
+	for key, f := range cov {
+		file := f.Path
+		if file == "" {
+			file = key
+		}
+		for id, stmt := range f.StatementMap {
+			hits.add(cleanPath(file), stmt.Start.Line, f.S[id])
+		}
+	}
+	return hits.report(), nil
+}
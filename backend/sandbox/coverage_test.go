@@ -0,0 +1,103 @@
+package sandbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoCoverProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    map[string][][2]int
+	}{
+		{
+			name:    "blocks",
+			profile: "mode: count\nmain.go:3.13,5.2 1 2\nmain.go:7.2,7.10 1 0\n",
+			want:    map[string][][2]int{"main.go": {{3, 2}, {4, 2}, {5, 2}, {7, 0}}},
+		},
+		{
+			name:    "overlapping blocks keep the highest count",
+			profile: "mode: count\nmain.go:3.1,4.2 1 1\nmain.go:4.1,5.2 1 5\n",
+			want:    map[string][][2]int{"main.go": {{3, 1}, {4, 5}, {5, 5}}},
+		},
+		{
+			name:    "module prefix left by sed is cleaned",
+			profile: "mode: set\n/app/pkg/util.go:1.1,1.5 1 1\n",
+			want:    map[string][][2]int{"pkg/util.go": {{1, 1}}},
+		},
+		{
+			name:    "end before start is rejected",
+			profile: "mode: count\nmain.go:9.1,2.1 1 1\nmain.go:1.1,1.2 1 1\n",
+			want:    map[string][][2]int{"main.go": {{1, 1}}},
+		},
+		{
+			name:    "non-positive start is rejected",
+			profile: "mode: count\nmain.go:0.1,3.1 1 1\nmain.go:-4.1,3.1 1 1\n",
+			want:    map[string][][2]int{},
+		},
+		{
+			name:    "malformed lines are skipped",
+			profile: "mode: count\ngarbage\nmain.go:1.1 1 1\nmain.go:1.1,2.1 1\nmain.go:x.1,y.1 1 1\n",
+			want:    map[string][][2]int{},
+		},
+		{
+			name:    "empty profile",
+			profile: "",
+			want:    map[string][][2]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := parseGoCoverProfile([]byte(tt.profile))
+			if err != nil {
+				t.Fatalf("parseGoCoverProfile() error = %v", err)
+			}
+			got := map[string][][2]int{}
+			for _, f := range report.Files {
+				got[f.File] = f.Hits
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGoCoverProfile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGoCoverProfileCapsLines(t *testing.T) {
+	report, err := parseGoCoverProfile([]byte("mode: count\nmain.go:1.1,2147483647.1 1 1\nmain.go:5.1,5.2 1 1\n"))
+	if err != nil {
+		t.Fatalf("parseGoCoverProfile() error = %v", err)
+	}
+	if len(report.Files) != 1 || report.Files[0].Lines != maxCoverageLines {
+		t.Fatalf("got %d files with %d lines, want 1 with %d", len(report.Files), report.Files[0].Lines, maxCoverageLines)
+	}
+}
+
+func TestCoverageClamp(t *testing.T) {
+	report, err := parseGoCoverProfile([]byte("mode: count\nmain.go:1.1,10.1 1 1\nother.go:50.1,50.2 1 0\n"))
+	if err != nil {
+		t.Fatalf("parseGoCoverProfile() error = %v", err)
+	}
+	report.clamp(map[string]string{"main.go": "package main\n\nfunc main() {\n}"})
+
+	if report.Lines != 5 || report.Covered != 4 {
+		t.Errorf("totals = %d lines, %d covered, want 5 and 4", report.Lines, report.Covered)
+	}
+	for _, f := range report.Files {
+		switch f.File {
+		case "main.go":
+			if want := [][2]int{{1, 1}, {2, 1}, {3, 1}, {4, 1}}; !reflect.DeepEqual(f.Hits, want) {
+				t.Errorf("main.go hits = %v, want %v", f.Hits, want)
+			}
+			if f.Percent != 100 {
+				t.Errorf("main.go percent = %v, want 100", f.Percent)
+			}
+		case "other.go":
+			// Files that were not submitted are left alone
+			if f.Lines != 1 {
+				t.Errorf("other.go lines = %d, want 1", f.Lines)
+			}
+		}
+	}
+}
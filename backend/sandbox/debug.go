@@ -0,0 +1,93 @@
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	debugIdleTimeout  = 10 * time.Minute
+	debugMaxLifetime  = 30 * time.Minute
+	debugBuildTimeout = 60 * time.Second
+
+	// Debugger commands print debugReadyMarker once the program has been
+	// built, then start a DAP server on the container's loopback port 4711.
+	debugReadyMarker = "codeexec-debugger-ready"
+	debugAttach      = "exec socat STDIO TCP:127.0.0.1:4711,retry=100,interval=0.1"
+)
+
+var ErrNoDebugger = errors.New("no debugger configured for this language")
+
+// BuildError reports that the program could not be prepared for debugging.
+type BuildError struct {
+	Output      string
+	Diagnostics []Diagnostic
+}
+
+func (e *BuildError) Error() string {
+	return "build failed: " + lastLine(e.Output)
+}
+
+// StartDebugger builds code (plus extra files) in a new debug session for
+// owner and waits until its debug adapter is about to start. Clients then
+// open DAP connections with ConnectDebugger; the sources live in /app.
+func StartDebugger(owner, language, code string, files map[string]string) (*Session, error) {
+	lang, ok := lookupLanguage(language)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+	if lang.Debugger == nil {
+		return nil, ErrNoDebugger
+	}
+	if err := validateFiles(files, lang.Filename); err != nil {
+		return nil, err
+	}
+	all := map[string]string{lang.Filename: code}
+	for name, content := range files {
+		all[name] = content
+	}
+
+	s, err := startSession(owner, sessionSpec{
+		kind:        "debug",
+		image:       lang.Debugger.Image,
+		runtime:     lang.runtime(),
+		command:     lang.Debugger.Command,
+		files:       all,
+		idleTimeout: debugIdleTimeout,
+		maxLifetime: debugMaxLifetime,
+		metered:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.AfterFunc(debugBuildTimeout, s.Close)
+	defer timer.Stop()
+
+	var output strings.Builder
+	r := bufio.NewReader(s.Stdout())
+	for {
+		line, err := r.ReadString('\n')
+		if strings.TrimSpace(line) == debugReadyMarker {
+			// Nothing reads the adapter's own output, so keep draining it
+			go io.Copy(io.Discard, r)
+			return s, nil
+		}
+		output.WriteString(line)
+		if err != nil {
+			s.Close()
+			return nil, &BuildError{Output: output.String(), Diagnostics: compileDiagnostics(lang, output.String())}
+		}
+	}
+}
+
+// ConnectDebugger opens a new connection to the session's debug adapter.
+// Adapters that start child sessions, such as js-debug, expect a further
+// connection for each.
+func ConnectDebugger(s *Session) (*Attachment, error) {
+	return s.Attach(debugAttach)
+}
@@ -0,0 +1,327 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Diagnostic is a problem reported by a linter or compiler. Line and Column
+// are 1-based; Column is zero when the tool only reports lines.
+type Diagnostic struct {
+	File     string `json:"file" bson:"file"`
+	Line     int    `json:"line" bson:"line"`
+	Column   int    `json:"column" bson:"column"`
+	Severity string `json:"severity" bson:"severity"`
+	Rule     string `json:"rule,omitempty" bson:"rule,omitempty"`
+	Message  string `json:"message" bson:"message"`
+}
+
+// diagnosticParser turns tool output into diagnostics.
+type diagnosticParser func(output string) []Diagnostic
+
+// diagnosticParsers are referenced by name from Tool.Parser and
+// Language.CompileErrors.
+var diagnosticParsers = map[string]diagnosticParser{
+	"gcc":      parseGCC,
+	"kotlinc":  parseGCC, // Same file:line:column: severity: message layout
+	"go":       parseGo,
+	"javac":    parseJavac,
+	"rustc":    parseRustc,
+	"tsc":      parseParenthesized,
+	"msbuild":  parseParenthesized,
+	"ruby":     parseRubySyntax,
+	"pyflakes": parsePyflakes,
+	"eslint":   parseESLint,
+	"python":   parsePythonTraceback,
+	"node":     parseNodeError,
+}
+
+// parseDiagnostics runs the named parser, returning nil for unknown names.
+func parseDiagnostics(parser, output string) []Diagnostic {
+	if p, ok := diagnosticParsers[parser]; ok {
+		return p(output)
+	}
+	return nil
+}
+
+// cleanPath strips the sandbox working directory from reported file names.
+func cleanPath(file string) string {
+	file = strings.TrimPrefix(file, "/app/")
+	return path.Clean(file)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// main.cpp:3:5: warning: unused variable 'x' [-Wunused-variable]
+var gccLine = regexp.MustCompile(`^(\S+?):(\d+):(\d+): (fatal error|error|warning|note): (.*?)(?: \[([\w.,=-]+)\])?$`)
+
+func parseGCC(output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := gccLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		severity := m[4]
+		switch severity {
+		case "fatal error":
+			severity = SeverityError
+		case "note":
+			severity = SeverityInfo
+		}
+		diags = append(diags, Diagnostic{
+			File: cleanPath(m[1]), Line: atoi(m[2]), Column: atoi(m[3]),
+			Severity: severity, Rule: m[6], Message: m[5],
+		})
+	}
+	return diags
+}
+
+// ./main.go:5:2: undefined: x
+// main.go:7:2: should use fmt.Println instead (S1038)
+var goLine = regexp.MustCompile(`^(?:vet: )?(\S+?\.go):(\d+):(\d+): (.*?)(?: \(([A-Z]+\d+)\))?$`)
+
+// parseGo handles the compiler, go vet and staticcheck. Compiler and vet
+// findings are errors, staticcheck findings warnings.
+func parseGo(output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := goLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		severity := SeverityError
+		if m[5] != "" {
+			severity = SeverityWarning
+		}
+		diags = append(diags, Diagnostic{
+			File: cleanPath(m[1]), Line: atoi(m[2]), Column: atoi(m[3]),
+			Severity: severity, Rule: m[5], Message: m[4],
+		})
+	}
+	return diags
+}
+
+// Main.java:3: warning: [rawtypes] found raw type: List
+var javacLine = regexp.MustCompile(`^(\S+?\.java):(\d+): (error|warning): (?:\[([\w-]+)\] )?(.*)$`)
+
+func parseJavac(output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := javacLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			File: cleanPath(m[1]), Line: atoi(m[2]),
+			Severity: m[3], Rule: m[4], Message: m[5],
+		})
+	}
+	return diags
+}
+
+// error[E0425]: cannot find value `x` in this scope
+// --> main.rs:2:20 (indented by one space)
+var (
+	rustcHeader   = regexp.MustCompile(`^(error|warning)(?:\[(\w+)\])?: (.*)$`)
+	rustcLocation = regexp.MustCompile(`^--> (\S+?):(\d+):(\d+)$`)
+)
+
+// parseRustc pairs each message with the location on the lines below it.
+// Summaries such as "aborting due to previous error" have no location and
+// are dropped.
+func parseRustc(output string) []Diagnostic {
+	var diags []Diagnostic
+	var pending []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if m := rustcHeader.FindStringSubmatch(line); m != nil {
+			pending = m
+			continue
+		}
+		m := rustcLocation.FindStringSubmatch(line)
+		if m == nil || pending == nil {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			File: cleanPath(m[1]), Line: atoi(m[2]), Column: atoi(m[3]),
+			Severity: pending[1], Rule: pending[2], Message: pending[3],
+		})
+		pending = nil
+	}
+	return diags
+}
+
+// main.ts(1,7): error TS2322: Type 'string' is not assignable to type 'number'.
+// /app/Program.cs(3,9): error CS0103: The name 'x' does not exist [/app/codeexec.csproj]
+var parenthesizedLine = regexp.MustCompile(`^(\S+?)\((\d+),(\d+)\): (error|warning) ([A-Z]+\d+): (.*?)(?: \[[^\]]+\])?$`)
+
+// parseParenthesized handles tsc and MSBuild, which both put the line and
+// column in parentheses after the file. MSBuild may repeat a message, so
+// duplicates are dropped.
+func parseParenthesized(output string) []Diagnostic {
+	var diags []Diagnostic
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		m := parenthesizedLine.FindStringSubmatch(line)
+		if m == nil || seen[line] {
+			continue
+		}
+		seen[line] = true
+		diags = append(diags, Diagnostic{
+			File: cleanPath(m[1]), Line: atoi(m[2]), Column: atoi(m[3]),
+			Severity: m[4], Rule: m[5], Message: m[6],
+		})
+	}
+	return diags
+}
+
+// main.rb:3: syntax error, unexpected end-of-input (SyntaxError)
+var rubySyntaxLine = regexp.MustCompile(`^(\S+?\.rb):(\d+): (.*syntax error.*?)(?: \(SyntaxError\))?$`)
+
+// parseRubySyntax reports syntax errors, the only failures Ruby detects
+// before running.
+func parseRubySyntax(output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := rubySyntaxLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			File: cleanPath(m[1]), Line: atoi(m[2]),
+			Severity: SeverityError, Message: m[3],
+		})
+	}
+	return diags
+}
+
+// main.py:1:1: 'os' imported but unused
+var pyflakesLine = regexp.MustCompile(`^(\S+?\.py):(\d+):(?:(\d+):?)? (.*)$`)
+
+func parsePyflakes(output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := pyflakesLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		severity := SeverityWarning
+		if strings.Contains(m[4], "syntax") || strings.HasPrefix(m[4], "undefined name") {
+			severity = SeverityError
+		}
+		diags = append(diags, Diagnostic{
+			File: cleanPath(m[1]), Line: atoi(m[2]), Column: atoi(m[3]),
+			Severity: severity, Message: m[4],
+		})
+	}
+	return diags
+}
+
+// parseESLint reads `eslint --format json` output.
+func parseESLint(output string) []Diagnostic {
+	var files []struct {
+		FilePath string `json:"filePath"`
+		Messages []struct {
+			RuleID   string `json:"ruleId"`
+			Severity int    `json:"severity"`
+			Message  string `json:"message"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+		} `json:"messages"`
+	}
+	start := strings.Index(output, "[")
+	if start < 0 || json.Unmarshal([]byte(output[start:]), &files) != nil {
+		return nil
+	}
+
+	var diags []Diagnostic
+	for _, f := range files {
+		for _, msg := range f.Messages {
+			severity := SeverityWarning
+			if msg.Severity == 2 {
+				severity = SeverityError
+			}
+			diags = append(diags, Diagnostic{
+				File: cleanPath(f.FilePath), Line: msg.Line, Column: msg.Column,
+				Severity: severity, Rule: msg.RuleID, Message: msg.Message,
+			})
+		}
+	}
+	return diags
+}
+
+// File "/app/main.py", line 3
+var pythonFrame = regexp.MustCompile(`^File "([^"]+)", line (\d+)`)
+
+// parsePythonTraceback reports syntax errors, the only failures Python
+// detects before running.
+func parsePythonTraceback(output string) []Diagnostic {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if !strings.HasPrefix(last, "SyntaxError") && !strings.HasPrefix(last, "IndentationError") && !strings.HasPrefix(last, "TabError") {
+		return nil
+	}
+
+	diag := Diagnostic{Severity: SeverityError, Message: last}
+	for i, line := range lines {
+		m := pythonFrame.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		diag.File, diag.Line = cleanPath(m[1]), atoi(m[2])
+		// The caret line under the source excerpt marks the column
+		if i+2 < len(lines) {
+			if caret := strings.Index(lines[i+2], "^"); caret >= 0 {
+				diag.Column = caret - (len(lines[i+1]) - len(strings.TrimLeft(lines[i+1], " "))) + 1
+				if diag.Column < 1 {
+					diag.Column = 0
+				}
+			}
+		}
+	}
+	if diag.File == "" {
+		return nil
+	}
+	return []Diagnostic{diag}
+}
+
+// /app/main.js:3
+var nodeLocation = regexp.MustCompile(`^(\S+?\.[cm]?[jt]s):(\d+)$`)
+
+// parseNodeError reports syntax errors, which node prints as the location,
+// the source line, a caret line and finally the error.
+func parseNodeError(output string) []Diagnostic {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i, line := range lines {
+		m := nodeLocation.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		diag := Diagnostic{File: cleanPath(m[1]), Line: atoi(m[2]), Severity: SeverityError}
+		for j := i + 1; j < len(lines) && j <= i+5; j++ {
+			if caret := strings.Index(lines[j], "^"); caret >= 0 && diag.Column == 0 {
+				diag.Column = caret + 1
+			}
+			if strings.HasPrefix(lines[j], "SyntaxError") {
+				diag.Message = strings.TrimSpace(lines[j])
+				return []Diagnostic{diag}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package sandbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		parser string
+		output string
+		want   []Diagnostic
+	}{
+		{
+			name:   "gcc",
+			parser: "gcc",
+			output: "main.cpp: In function 'int main()':\n" +
+				"main.cpp:3:5: warning: unused variable 'x' [-Wunused-variable]\n" +
+				"/app/main.cpp:4:1: error: expected ';' before '}' token\n" +
+				"main.cpp:1:10: fatal error: nope.h: No such file or directory\n" +
+				"main.cpp:2:3: note: declared here\n",
+			want: []Diagnostic{
+				{File: "main.cpp", Line: 3, Column: 5, Severity: SeverityWarning, Rule: "-Wunused-variable", Message: "unused variable 'x'"},
+				{File: "main.cpp", Line: 4, Column: 1, Severity: SeverityError, Message: "expected ';' before '}' token"},
+				{File: "main.cpp", Line: 1, Column: 10, Severity: SeverityError, Message: "nope.h: No such file or directory"},
+				{File: "main.cpp", Line: 2, Column: 3, Severity: SeverityInfo, Message: "declared here"},
+			},
+		},
+		{
+			name:   "kotlinc",
+			parser: "kotlinc",
+			output: "Main.kt:2:13: error: unresolved reference: x\nMain.kt:1:5: warning: parameter 'args' is never used\n",
+			want: []Diagnostic{
+				{File: "Main.kt", Line: 2, Column: 13, Severity: SeverityError, Message: "unresolved reference: x"},
+				{File: "Main.kt", Line: 1, Column: 5, Severity: SeverityWarning, Message: "parameter 'args' is never used"},
+			},
+		},
+		{
+			name:   "go compiler, vet and staticcheck",
+			parser: "go",
+			output: "# command-line-arguments\n./main.go:5:2: undefined: x\nvet: main.go:6:3: unreachable code\nmain.go:7:2: should use fmt.Println instead (S1038)\n",
+			want: []Diagnostic{
+				{File: "main.go", Line: 5, Column: 2, Severity: SeverityError, Message: "undefined: x"},
+				{File: "main.go", Line: 6, Column: 3, Severity: SeverityError, Message: "unreachable code"},
+				{File: "main.go", Line: 7, Column: 2, Severity: SeverityWarning, Rule: "S1038", Message: "should use fmt.Println instead"},
+			},
+		},
+		{
+			name:   "javac",
+			parser: "javac",
+			output: "Main.java:3: warning: [rawtypes] found raw type: List\nMain.java:5: error: ';' expected\n1 error\n",
+			want: []Diagnostic{
+				{File: "Main.java", Line: 3, Severity: SeverityWarning, Rule: "rawtypes", Message: "found raw type: List"},
+				{File: "Main.java", Line: 5, Severity: SeverityError, Message: "';' expected"},
+			},
+		},
+		{
+			name:   "rustc pairs messages with locations",
+			parser: "rustc",
+			output: "error[E0425]: cannot find value `x` in this scope\n --> main.rs:2:20\n  |\nwarning: unused variable: `y`\n --> main.rs:3:9\nerror: aborting due to previous error\n",
+			want: []Diagnostic{
+				{File: "main.rs", Line: 2, Column: 20, Severity: SeverityError, Rule: "E0425", Message: "cannot find value `x` in this scope"},
+				{File: "main.rs", Line: 3, Column: 9, Severity: SeverityWarning, Message: "unused variable: `y`"},
+			},
+		},
+		{
+			name:   "tsc",
+			parser: "tsc",
+			output: "main.ts(1,7): error TS2322: Type 'string' is not assignable to type 'number'.\n",
+			want: []Diagnostic{
+				{File: "main.ts", Line: 1, Column: 7, Severity: SeverityError, Rule: "TS2322", Message: "Type 'string' is not assignable to type 'number'."},
+			},
+		},
+		{
+			name:   "msbuild drops repeats",
+			parser: "msbuild",
+			output: "/app/Program.cs(3,9): error CS0103: The name 'x' does not exist [/app/codeexec.csproj]\n" +
+				"/app/Program.cs(3,9): error CS0103: The name 'x' does not exist [/app/codeexec.csproj]\n",
+			want: []Diagnostic{
+				{File: "Program.cs", Line: 3, Column: 9, Severity: SeverityError, Rule: "CS0103", Message: "The name 'x' does not exist"},
+			},
+		},
+		{
+			name:   "ruby",
+			parser: "ruby",
+			output: "main.rb:3: syntax error, unexpected end-of-input (SyntaxError)\n",
+			want: []Diagnostic{
+				{File: "main.rb", Line: 3, Severity: SeverityError, Message: "syntax error, unexpected end-of-input"},
+			},
+		},
+		{
+			name:   "pyflakes",
+			parser: "pyflakes",
+			output: "main.py:1:1: 'os' imported but unused\nmain.py:3:7: undefined name 'x'\nmain.py:5: invalid syntax\n",
+			want: []Diagnostic{
+				{File: "main.py", Line: 1, Column: 1, Severity: SeverityWarning, Message: "'os' imported but unused"},
+				{File: "main.py", Line: 3, Column: 7, Severity: SeverityError, Message: "undefined name 'x'"},
+				{File: "main.py", Line: 5, Severity: SeverityError, Message: "invalid syntax"},
+			},
+		},
+		{
+			name:   "eslint after a banner",
+			parser: "eslint",
+			output: `npm notice\n[{"filePath":"/app/main.js","messages":[{"ruleId":"no-unused-vars","severity":2,"message":"'x' is unused","line":1,"column":5},{"ruleId":"semi","severity":1,"message":"Missing semicolon","line":2,"column":9}]}]`,
+			want: []Diagnostic{
+				{File: "main.js", Line: 1, Column: 5, Severity: SeverityError, Rule: "no-unused-vars", Message: "'x' is unused"},
+				{File: "main.js", Line: 2, Column: 9, Severity: SeverityWarning, Rule: "semi", Message: "Missing semicolon"},
+			},
+		},
+		{
+			name:   "python syntax error",
+			parser: "python",
+			output: "  File \"/app/main.py\", line 2\n    print(\"a\"\n         ^\nSyntaxError: '(' was never closed\n",
+			want: []Diagnostic{
+				{File: "main.py", Line: 2, Column: 6, Severity: SeverityError, Message: "SyntaxError: '(' was never closed"},
+			},
+		},
+		{
+			name:   "node syntax error",
+			parser: "node",
+			output: "/app/main.js:3\nlet x = ;\n        ^\n\nSyntaxError: Unexpected token ';'\n    at internalCompileFunction\n",
+			want: []Diagnostic{
+				{File: "main.js", Line: 3, Column: 9, Severity: SeverityError, Message: "SyntaxError: Unexpected token ';'"},
+			},
+		},
+		{name: "python runtime error is not a diagnostic", parser: "python", output: "Traceback (most recent call last):\n  File \"/app/main.py\", line 1, in <module>\nZeroDivisionError: division by zero\n"},
+		{name: "node runtime error is not a diagnostic", parser: "node", output: "/app/main.js:1\nthrow new Error('x')\n^\n\nError: x\n"},
+		{name: "eslint garbage", parser: "eslint", output: "[{not json"},
+		{name: "eslint without JSON", parser: "eslint", output: "command not found"},
+		{name: "python empty output", parser: "python", output: ""},
+		{name: "node empty output", parser: "node", output: ""},
+		{name: "gcc unrelated output", parser: "gcc", output: "collect2: error: ld returned 1 exit status\nmain.cpp:x:y: error: nope\n"},
+		{name: "rustc location without a message", parser: "rustc", output: " --> main.rs:2:20\n"},
+		{name: "unknown parser", parser: "nope", output: "main.c:1:1: error: x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseDiagnostics(tt.parser, tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDiagnostics(%q) =\n%+v\nwant\n%+v", tt.parser, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileDiagnosticsKeepsSubmittedFiles(t *testing.T) {
+	lang := Language{CompileErrors: "gcc"}
+	output := "main.c:2:1: error: in the submission\n" +
+		"/usr/include/stdio.h:10:1: error: in a system header\n" +
+		"../outside.c:1:1: error: outside the working directory\n"
+	want := []Diagnostic{{File: "main.c", Line: 2, Column: 1, Severity: SeverityError, Message: "in the submission"}}
+	if got := compileDiagnostics(lang, output); !reflect.DeepEqual(got, want) {
+		t.Errorf("compileDiagnostics() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,559 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultEgressQuota  = 10 << 20 // 10 MiB per execution, both directions
+	egressNetworkPrefix = "codeexec-egress-"
+	maxEgressLog        = 200
+	egressDialTimeout   = 10 * time.Second
+)
+
+var (
+	ErrEgressDisabled = errors.New("network access is not enabled on this server")
+	errEgressBlocked  = errors.New("destination is not allowed")
+	errEgressQuota    = errors.New("egress quota exceeded")
+)
+
+// EgressRequest is one request an execution made through the egress proxy.
+type EgressRequest struct {
+	Time          time.Time `json:"time" bson:"time"`
+	Method        string    `json:"method" bson:"method"`
+	Host          string    `json:"host" bson:"host"`                   // host:port
+	URL           string    `json:"url,omitempty" bson:"url,omitempty"` // Plain HTTP only; HTTPS is tunnelled
+	Status        int       `json:"status" bson:"status"`
+	BytesSent     int64     `json:"bytesSent" bson:"bytesSent"`
+	BytesReceived int64     `json:"bytesReceived" bson:"bytesReceived"`
+	Blocked       string    `json:"blocked,omitempty" bson:"blocked,omitempty"` // Why the proxy refused
+}
+
+// EgressReport summarises an execution's network use.
+type EgressReport struct {
+	Requests      []EgressRequest `json:"requests"`
+	Dropped       int             `json:"dropped,omitempty"` // Requests beyond the log limit
+	BytesSent     int64           `json:"bytesSent"`
+	BytesReceived int64           `json:"bytesReceived"`
+	QuotaBytes    int64           `json:"quotaBytes"`
+	QuotaExceeded bool            `json:"quotaExceeded,omitempty"`
+}
+
+// allowEntry is one allowlisted destination. Without a port, HTTP and HTTPS
+// (80 and 443) are allowed. "*.example.com" matches subdomains only.
+type allowEntry struct {
+	host string
+	port string
+}
+
+func parseAllowlist(list string) []allowEntry {
+	var entries []allowEntry
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		e := allowEntry{host: item}
+		if host, port, err := net.SplitHostPort(item); err == nil {
+			e = allowEntry{host: host, port: port}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (e allowEntry) matches(host, port string) bool {
+	if e.port == "" && port != "80" && port != "443" || e.port != "" && e.port != port {
+		return false
+	}
+	if suffix, ok := strings.CutPrefix(e.host, "*"); ok {
+		return strings.HasSuffix(host, suffix)
+	}
+	return host == e.host
+}
+
+// private reports whether the entry names a loopback or private address
+// itself. Only such entries may reach those addresses, so that a public
+// name cannot be pointed at the backend's own network.
+func (e allowEntry) private() bool {
+	return e.host == "localhost" || net.ParseIP(e.host) != nil
+}
+
+// EgressProxy is an HTTP forward proxy for sandbox containers. Containers
+// reach it on an internal Docker network with no other route out, and
+// authenticate with credentials issued per execution, so every request is
+// checked against the allowlist and counted against that execution's quota.
+type EgressProxy struct {
+	allowlist []allowEntry
+	quota     int64
+
+	mu       sync.Mutex
+	sessions map[string]*egressSession // By token
+}
+
+// NewEgressProxy creates a proxy allowing the comma-separated destinations
+// in allowlist, with quota bytes per execution.
+func NewEgressProxy(allowlist string, quota int64) *EgressProxy {
+	return &EgressProxy{
+		allowlist: parseAllowlist(allowlist),
+		quota:     quota,
+		sessions:  map[string]*egressSession{},
+	}
+}
+
+type egressSession struct {
+	id    string
+	token string
+	quota int64
+
+	mu       sync.Mutex
+	report   EgressReport
+	conns    map[net.Conn]bool
+	finished bool
+}
+
+// Open issues credentials for one execution.
+func (p *EgressProxy) Open(executionID string) *egressSession {
+	b := make([]byte, 16)
+	rand.Read(b)
+	s := &egressSession{
+		id:     executionID,
+		token:  hex.EncodeToString(b),
+		quota:  p.quota,
+		report: EgressReport{Requests: []EgressRequest{}, QuotaBytes: p.quota},
+		conns:  map[net.Conn]bool{},
+	}
+	p.mu.Lock()
+	p.sessions[s.token] = s
+	p.mu.Unlock()
+	return s
+}
+
+// Close revokes the execution's credentials, cuts its open connections and
+// returns what it did.
+func (p *EgressProxy) Close(s *egressSession) *EgressReport {
+	p.mu.Lock()
+	delete(p.sessions, s.token)
+	p.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	report := s.report
+	return &report
+}
+
+// count adds traffic to the session and reports whether it is within quota.
+func (s *egressSession) count(sent, received int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.BytesSent += sent
+	s.report.BytesReceived += received
+	if s.report.BytesSent+s.report.BytesReceived > s.quota {
+		s.report.QuotaExceeded = true
+		return false
+	}
+	return true
+}
+
+func (s *egressSession) log(req EgressRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.report.Requests) >= maxEgressLog {
+		s.report.Dropped++
+		return
+	}
+	s.report.Requests = append(s.report.Requests, req)
+}
+
+// track registers an upstream connection so Close can cut it. It fails once
+// the session has finished.
+func (s *egressSession) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return false
+	}
+	s.conns[conn] = true
+	return true
+}
+
+func (s *egressSession) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+func (s *egressSession) overQuota() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report.QuotaExceeded
+}
+
+// proxyURL is the proxy address with the session's credentials, as set in
+// the container's HTTP_PROXY.
+func (s *egressSession) proxyURL(addr string) string {
+	return fmt.Sprintf("http://%s:%s@%s", s.id, s.token, addr)
+}
+
+func (p *EgressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	s := p.sessions[proxyToken(r)]
+	p.mu.Unlock()
+	if s == nil {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="codeexec"`)
+		http.Error(w, "proxy credentials required", http.StatusProxyAuthRequired)
+		return
+	}
+
+	host, port := r.URL.Hostname(), r.URL.Port()
+	if r.Method == http.MethodConnect {
+		host, port, _ = net.SplitHostPort(r.Host)
+	} else if port == "" {
+		port = "80"
+	}
+	host = strings.ToLower(host)
+	entry := EgressRequest{Time: time.Now(), Method: r.Method, Host: net.JoinHostPort(host, port)}
+	if r.Method != http.MethodConnect {
+		entry.URL = r.URL.String()
+	}
+
+	allowed, found := p.match(host, port)
+	switch {
+	case r.Method != http.MethodConnect && r.URL.Scheme != "http":
+		entry.Status, entry.Blocked = http.StatusBadRequest, "only absolute http URLs and CONNECT are supported"
+	case !found:
+		entry.Status, entry.Blocked = http.StatusForbidden, errEgressBlocked.Error()
+	case s.overQuota():
+		entry.Status, entry.Blocked = http.StatusForbidden, errEgressQuota.Error()
+	}
+	if entry.Blocked != "" {
+		s.log(entry)
+		http.Error(w, entry.Blocked, entry.Status)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, s, allowed, &entry)
+	} else {
+		p.forward(w, r, s, allowed, &entry)
+	}
+	s.log(entry)
+}
+
+// proxyToken is the password of the request's Basic proxy credentials.
+func proxyToken(r *http.Request) string {
+	scheme, value, _ := strings.Cut(r.Header.Get("Proxy-Authorization"), " ")
+	if !strings.EqualFold(scheme, "Basic") {
+		return ""
+	}
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return ""
+	}
+	_, token, _ := strings.Cut(string(decoded), ":")
+	return token
+}
+
+func (p *EgressProxy) match(host, port string) (allowEntry, bool) {
+	for _, e := range p.allowlist {
+		if e.matches(host, port) {
+			return e, true
+		}
+	}
+	return allowEntry{}, false
+}
+
+// dial connects to an allowed destination. The address actually dialled is
+// checked, so names resolving to private addresses stay unreachable.
+func dial(ctx context.Context, entry allowEntry, addr string) (net.Conn, error) {
+	d := net.Dialer{
+		Timeout: egressDialTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, _ := net.SplitHostPort(address)
+			ip := net.ParseIP(host)
+			if !entry.private() && (ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+				return errEgressBlocked
+			}
+			return nil
+		},
+	}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// tunnel serves CONNECT, copying bytes both ways until either side closes
+// or the quota runs out.
+func (p *EgressProxy) tunnel(w http.ResponseWriter, s *egressSession, allowed allowEntry, entry *EgressRequest) {
+	upstream, err := dial(context.Background(), allowed, entry.Host)
+	if err != nil {
+		entry.Status, entry.Blocked = http.StatusBadGateway, err.Error()
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	if !s.track(upstream) {
+		entry.Status = http.StatusGone
+		http.Error(w, "execution finished", http.StatusGone)
+		return
+	}
+	defer s.untrack(upstream)
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		entry.Status = http.StatusInternalServerError
+		http.Error(w, "tunnelling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		entry.Status = http.StatusInternalServerError
+		return
+	}
+	defer client.Close()
+	entry.Status = http.StatusOK
+	client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	done := make(chan struct{}, 2)
+	go func() {
+		n, _ := copyCounted(upstream, buffered, s, true)
+		entry.BytesSent = n
+		upstream.Close()
+		done <- struct{}{}
+	}()
+	go func() {
+		n, _ := copyCounted(client, upstream, s, false)
+		entry.BytesReceived = n
+		client.Close()
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+// forward serves a plain HTTP request.
+func (p *EgressProxy) forward(w http.ResponseWriter, r *http.Request, s *egressSession, allowed allowEntry, entry *EgressRequest) {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, allowed, addr)
+			if err == nil && !s.track(conn) {
+				conn.Close()
+				return nil, errors.New("execution finished")
+			}
+			return conn, err
+		},
+		DisableKeepAlives:     true,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range []string{"Proxy-Authorization", "Proxy-Connection", "Connection", "Keep-Alive", "Te", "Trailer", "Upgrade"} {
+		out.Header.Del(h)
+	}
+	body := &countingReader{r: r.Body, session: s}
+	if r.Body != nil {
+		out.Body = body
+	}
+
+	resp, err := transport.RoundTrip(out)
+	entry.BytesSent = body.n
+	if err != nil {
+		entry.Status, entry.Blocked = http.StatusBadGateway, err.Error()
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	entry.Status = resp.StatusCode
+	entry.BytesReceived, _ = copyCounted(w, resp.Body, s, false)
+}
+
+// copyCounted copies src to dst, stopping once the session's quota is used
+// up.
+func copyCounted(dst io.Writer, src io.Reader, s *egressSession, sent bool) (int64, error) {
+	buf := make([]byte, 32<<10)
+	var total int64
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			total += int64(n)
+			up, down := int64(0), int64(n)
+			if sent {
+				up, down = down, up
+			}
+			within := s.count(up, down)
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return total, werr
+			}
+			if !within {
+				return total, errEgressQuota
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return total, nil
+			}
+			return total, err
+		}
+	}
+}
+
+type countingReader struct {
+	r       io.ReadCloser
+	session *egressSession
+	n       int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if n > 0 && !c.session.count(int64(n), 0) {
+		return n, errEgressQuota
+	}
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	return c.r.Close()
+}
+
+// The egress proxy of this server, nil when egress is disabled.
+var (
+	egressProxy     *EgressProxy
+	egressAddr      string // Proxy address as containers see it
+	egressContainer string // Container the proxy runs in
+)
+
+// StartEgress enables network access for executions that ask for it when
+// SANDBOX_EGRESS_ALLOWLIST is set. The proxy listens on
+// SANDBOX_EGRESS_LISTEN (default :3128). Each run with network access gets
+// an internal Docker network of its own, shared only with the proxy's
+// container, SANDBOX_EGRESS_CONTAINER (default this container), which
+// joins it under the host name of SANDBOX_EGRESS_PROXY. Sandboxes thus
+// cannot reach each other, and the proxy is their only way out.
+func StartEgress() error {
+	allowlist := os.Getenv("SANDBOX_EGRESS_ALLOWLIST")
+	if allowlist == "" {
+		return nil
+	}
+	quota := int64(defaultEgressQuota)
+	if v := os.Getenv("SANDBOX_EGRESS_QUOTA_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid SANDBOX_EGRESS_QUOTA_BYTES %q", v)
+		}
+		quota = n
+	}
+	addr := os.Getenv("SANDBOX_EGRESS_PROXY")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("SANDBOX_EGRESS_PROXY must be the proxy's host:port as sandboxes see it")
+	}
+	container := os.Getenv("SANDBOX_EGRESS_CONTAINER")
+	if container == "" {
+		container = instanceID
+	}
+	listen := os.Getenv("SANDBOX_EGRESS_LISTEN")
+	if listen == "" {
+		listen = ":3128"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := exec.CommandContext(ctx, "docker", "container", "inspect", container).Run(); err != nil {
+		return fmt.Errorf("egress proxy container %s not found, set SANDBOX_EGRESS_CONTAINER: %w", container, err)
+	}
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to start egress proxy: %w", err)
+	}
+	egressProxy = NewEgressProxy(allowlist, quota)
+	egressAddr, egressContainer = addr, container
+	go func() {
+		if err := http.Serve(ln, egressProxy); err != nil {
+			log.Printf("Egress proxy stopped: %v", err)
+		}
+	}()
+	log.Printf("Egress proxy listening on %s for %s", listen, allowlist)
+	return nil
+}
+
+// openEgressNetwork creates the internal network of one execution and
+// connects the proxy to it. It carries the same reaper labels as sandbox
+// containers.
+func openEgressNetwork(executionID string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	name := egressNetworkPrefix + executionID
+	args := append([]string{"network", "create", "--internal"}, containerLabels(executionID, time.Now().Add(maxRunTimeout))...)
+	if out, err := exec.CommandContext(ctx, "docker", append(args, name)...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to create network %s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	alias, _, _ := net.SplitHostPort(egressAddr)
+	if out, err := exec.CommandContext(ctx, "docker", "network", "connect", "--alias", alias, name, egressContainer).CombinedOutput(); err != nil {
+		removeNetwork(name)
+		return "", fmt.Errorf("failed to connect the egress proxy to %s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return name, nil
+}
+
+// removeNetwork disconnects whatever is still attached to a sandbox network
+// and removes it. What it fails to remove is left to the reaper.
+func removeNetwork(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "docker", "network", "inspect", "--format", `{{range $id, $c := .Containers}}{{$id}} {{end}}`, name).Output()
+	if err != nil {
+		return fmt.Errorf("failed to inspect network %s: %w", name, err)
+	}
+	for _, id := range strings.Fields(string(out)) {
+		exec.CommandContext(ctx, "docker", "network", "disconnect", "-f", name, id).Run()
+	}
+	if err := exec.CommandContext(ctx, "docker", "network", "rm", name).Run(); err != nil {
+		return fmt.Errorf("failed to remove network %s: %w", name, err)
+	}
+	return nil
+}
+
+// EgressEnabled reports whether executions may ask for network access.
+func EgressEnabled() bool {
+	return egressProxy != nil
+}
+
+// proxyEnv points the usual proxy variables at the session's credentials.
+func proxyEnv(s *egressSession) []string {
+	url := s.proxyURL(egressAddr)
+	var env []string
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		env = append(env, name+"="+url)
+	}
+	// Node's fetch only honours the variables when asked to
+	return append(env, "NODE_USE_ENV_PROXY=1")
+}
@@ -0,0 +1,214 @@
+package sandbox
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAllowlistMatches(t *testing.T) {
+	tests := []struct {
+		list, host, port string
+		want             bool
+	}{
+		{"example.com", "example.com", "443", true},
+		{"example.com", "example.com", "80", true},
+		{"example.com", "example.com", "8080", false},
+		{"Example.COM ", "example.com", "443", true},
+		{"example.com:8080", "example.com", "8080", true},
+		{"example.com:8080", "example.com", "443", false},
+		{"example.com", "api.example.com", "443", false},
+		{"*.example.com", "api.example.com", "443", true},
+		{"*.example.com", "example.com", "443", false},
+		{"*.example.com", "evilexample.com", "443", false},
+		{"a.com, b.com", "b.com", "80", true},
+		{"", "example.com", "443", false},
+		{" , ", "example.com", "443", false},
+	}
+	for _, tt := range tests {
+		p := NewEgressProxy(tt.list, 1)
+		if _, got := p.match(tt.host, tt.port); got != tt.want {
+			t.Errorf("allowlist %q match(%s, %s) = %v, want %v", tt.list, tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+// proxyClient sends requests through the proxy with the session's
+// credentials, trusting the TLS test server if one is given.
+func proxyClient(t *testing.T, proxy *httptest.Server, s *egressSession, tlsServer *httptest.Server) *http.Client {
+	t.Helper()
+	proxyURL, err := url.Parse(s.proxyURL(proxy.Listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &http.Transport{Proxy: http.ProxyURL(proxyURL), DisableKeepAlives: true}
+	if tlsServer != nil {
+		transport.TLSClientConfig = tlsServer.Client().Transport.(*http.Transport).TLSClientConfig
+	}
+	return &http.Client{Transport: transport}
+}
+
+func TestEgressProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "" {
+			t.Errorf("proxy credentials leaked upstream")
+		}
+		io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+	defer upstream.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	defer secure.Close()
+	_, upstreamPort, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+	_, securePort, _ := net.SplitHostPort(secure.Listener.Addr().String())
+
+	// The wildcard entry is not a private address, so it may not reach
+	// loopback even though it matches
+	p := NewEgressProxy("127.0.0.1:"+upstreamPort+", 127.0.0.1:"+securePort+", *:9", 1<<20)
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	tests := []struct {
+		name        string
+		url         string
+		wantStatus  int
+		wantBody    string
+		wantBlocked string
+	}{
+		{name: "allowed", url: upstream.URL, wantStatus: http.StatusOK, wantBody: strings.Repeat("x", 1000)},
+		{name: "allowed over a CONNECT tunnel", url: secure.URL, wantStatus: http.StatusOK, wantBody: "secure"},
+		{name: "not allowlisted", url: "http://127.0.0.2:" + upstreamPort, wantStatus: http.StatusForbidden, wantBlocked: errEgressBlocked.Error()},
+		{name: "wildcard may not dial loopback", url: "http://127.0.0.1:9", wantStatus: http.StatusBadGateway, wantBlocked: errEgressBlocked.Error()},
+		{name: "wildcard may not dial localhost by name", url: "http://localhost:9", wantStatus: http.StatusBadGateway, wantBlocked: errEgressBlocked.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := p.Open(strings.ReplaceAll(tt.name, " ", "-"))
+			client := proxyClient(t, proxy, s, secure)
+			resp, err := client.Get(tt.url)
+			report := func() *EgressReport { return p.Close(s) }
+			if tt.wantStatus == http.StatusOK {
+				if err != nil {
+					t.Fatalf("GET %s: %v", tt.url, err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantStatus || string(body) != tt.wantBody {
+					t.Fatalf("GET %s = %d %q, want %d %q", tt.url, resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+				}
+				r := report()
+				if len(r.Requests) != 1 || r.Requests[0].Status != http.StatusOK || r.BytesReceived < int64(len(tt.wantBody)) {
+					t.Errorf("report = %+v, want one logged request with the body counted", r)
+				}
+				return
+			}
+
+			// A refused CONNECT surfaces as a client error instead of a response
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("GET %s = %d, want %d", tt.url, resp.StatusCode, tt.wantStatus)
+				}
+			}
+			r := report()
+			if len(r.Requests) != 1 || r.Requests[0].Status != tt.wantStatus || !strings.Contains(r.Requests[0].Blocked, tt.wantBlocked) {
+				t.Errorf("report = %+v, want one request blocked with %d %q", r.Requests, tt.wantStatus, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestEgressProxyCredentials(t *testing.T) {
+	p := NewEgressProxy("example.com", 1<<20)
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{"no credentials", func() string { return "" }},
+		{"unknown token", func() string { return "0123456789abcdef" }},
+		{"closed session", func() string {
+			s := p.Open("closed")
+			p.Close(s)
+			return s.token
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if token := tt.token(); token != "" {
+				req.SetBasicAuth("exec", token)
+				req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+				req.Header.Del("Authorization")
+			}
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, req)
+			if w.Code != http.StatusProxyAuthRequired {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusProxyAuthRequired)
+			}
+		})
+	}
+
+	// Only absolute http URLs and CONNECT are proxied
+	s := p.Open("scheme")
+	defer p.Close(s)
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.SetBasicAuth("exec", s.token)
+	req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("https URL without CONNECT: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestEgressProxyQuota(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 100<<10))
+	}))
+	defer upstream.Close()
+	_, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+
+	const quota = 40 << 10
+	p := NewEgressProxy("127.0.0.1:"+port, quota)
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+	s := p.Open("quota")
+	client := proxyClient(t, proxy, s, nil)
+
+	// The first response is cut off once the quota is used up
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("first GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) >= 100<<10 {
+		t.Errorf("first GET read %d bytes, want fewer than the full body", len(body))
+	}
+
+	// Later requests are refused outright
+	resp, err = client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("second GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("second GET = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	r := p.Close(s)
+	if !r.QuotaExceeded || r.QuotaBytes != quota || len(r.Requests) != 2 || r.Requests[1].Blocked != errEgressQuota.Error() {
+		t.Errorf("report = %+v, want the quota exceeded and the second request blocked", r)
+	}
+	if r.BytesReceived > quota+32<<10 {
+		t.Errorf("received %d bytes, want at most one buffer past the %d byte quota", r.BytesReceived, quota)
+	}
+}
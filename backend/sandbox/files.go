@@ -0,0 +1,90 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Limits on the extra files submitted alongside the main source file.
+const (
+	maxExtraFiles  = 20
+	maxFileSize    = 1 << 20 // 1 MiB
+	maxTotalFileSz = 5 << 20 // 5 MiB
+)
+
+// Limits on uploaded stdin and data files, which may be larger than sources.
+const (
+	MaxInputSize     = 16 << 20 // 16 MiB
+	MaxDataFiles     = 20
+	MaxDataFileSize  = 8 << 20  // 8 MiB
+	MaxTotalDataSize = 32 << 20 // 32 MiB
+)
+
+var ErrInvalidFiles = errors.New("invalid files")
+
+// validateFiles checks extra files before anything is written to disk. Names
+// must be relative paths inside the working directory, must not be hidden
+// (the sandbox keeps its own bookkeeping in dot files) and must not replace
+// the main source file.
+func validateFiles(files map[string]string, mainFile string) error {
+	if len(files) > maxExtraFiles {
+		return fmt.Errorf("%w: at most %d files are allowed", ErrInvalidFiles, maxExtraFiles)
+	}
+	total := 0
+	for name, content := range files {
+		if err := validatePath(name); err != nil {
+			return err
+		}
+		if name == mainFile {
+			return fmt.Errorf("%w: %q is the main source file, send it as code", ErrInvalidFiles, name)
+		}
+		if len(content) > maxFileSize {
+			return fmt.Errorf("%w: %q exceeds %d bytes", ErrInvalidFiles, name, maxFileSize)
+		}
+		total += len(content)
+	}
+	if total > maxTotalFileSz {
+		return fmt.Errorf("%w: files exceed %d bytes in total", ErrInvalidFiles, maxTotalFileSz)
+	}
+	return nil
+}
+
+// validateDataFiles checks uploaded data files like source files, but with
+// the upload limits. They must not replace any source file.
+func validateDataFiles(data, files map[string]string, mainFile string) error {
+	if len(data) > MaxDataFiles {
+		return fmt.Errorf("%w: at most %d data files are allowed", ErrInvalidFiles, MaxDataFiles)
+	}
+	total := 0
+	for name, content := range data {
+		if err := validatePath(name); err != nil {
+			return err
+		}
+		if _, ok := files[name]; ok || name == mainFile {
+			return fmt.Errorf("%w: data file %q has the name of a source file", ErrInvalidFiles, name)
+		}
+		if len(content) > MaxDataFileSize {
+			return fmt.Errorf("%w: %q exceeds %d bytes", ErrInvalidFiles, name, MaxDataFileSize)
+		}
+		total += len(content)
+	}
+	if total > MaxTotalDataSize {
+		return fmt.Errorf("%w: data files exceed %d bytes in total", ErrInvalidFiles, MaxTotalDataSize)
+	}
+	return nil
+}
+
+func validatePath(name string) error {
+	clean := path.Clean(name)
+	if name == "" || clean != name || path.IsAbs(name) || strings.Contains(name, `\`) {
+		return fmt.Errorf("%w: %q is not a clean relative path", ErrInvalidFiles, name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return fmt.Errorf("%w: %q must not contain hidden or parent path elements", ErrInvalidFiles, name)
+		}
+	}
+	return nil
+}
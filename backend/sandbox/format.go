@@ -0,0 +1,86 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const formatTimeout = 15 * time.Second
+
+var ErrNoFormatter = errors.New("no formatter configured for this language")
+
+// SyntaxError is a formatter failure pointing at the offending position.
+// Line and Column are 1-based and zero when the tool did not report them.
+type SyntaxError struct {
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Position patterns of the default formatters, most specific first.
+var syntaxErrorPositions = []*regexp.Regexp{
+	regexp.MustCompile(`Cannot parse[^:]*: (\d+):(\d+)`), // black
+	regexp.MustCompile(`\((\d+):(\d+)\)`),                // prettier
+	regexp.MustCompile(`:(\d+):(\d+)`),                   // gofmt, clang-format, google-java-format
+}
+
+// Format runs the language's formatter over code. A *SyntaxError is returned
+// when the formatter rejects the code. The usage of the formatter's container
+// is returned whenever it ran.
+func Format(owner, language, code string) (string, *Usage, error) {
+	lang, ok := lookupLanguage(language)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+	if lang.Formatter == nil {
+		return "", nil, ErrNoFormatter
+	}
+
+	out, err := runOnce(uuid.New().String(), owner, formatTimeout, containerSpec{
+		image:   lang.Formatter.Image,
+		runtime: lang.runtime(),
+		files:   map[string]string{lang.Filename: code},
+		command: lang.Formatter.Command,
+		collect: []string{lang.Filename},
+	})
+	if status, exitCode := statusOf(err); status == StatusError && exitCode != 0 {
+		return "", out.usage, parseSyntaxError(out.output)
+	} else if err != nil {
+		return "", out.usage, fmt.Errorf("formatter failed: %w", err)
+	}
+
+	formatted, ok := out.files[lang.Filename]
+	if !ok {
+		return "", out.usage, fmt.Errorf("formatter did not leave %s behind", lang.Filename)
+	}
+	return string(formatted), out.usage, nil
+}
+
+func parseSyntaxError(output string) *SyntaxError {
+	output = strings.TrimSpace(output)
+	for _, line := range strings.Split(output, "\n") {
+		for _, re := range syntaxErrorPositions {
+			m := re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			ln, _ := strconv.Atoi(m[1])
+			col, _ := strconv.Atoi(m[2])
+			return &SyntaxError{Message: strings.TrimSpace(line), Line: ln, Column: col}
+		}
+	}
+	return &SyntaxError{Message: output}
+}
@@ -0,0 +1,170 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const selfTestOutput = "hello world"
+
+// LanguageStatus reports whether a language can currently be executed.
+type LanguageStatus struct {
+	Name      string    `json:"name"`
+	Image     string    `json:"image"`
+	Backend   string    `json:"backend"`
+	Available bool      `json:"available"`
+	Reason    string    `json:"reason,omitempty"`
+	CheckedAt time.Time `json:"checkedAt,omitempty"`
+}
+
+var (
+	statusMu sync.RWMutex
+	statuses = map[string]LanguageStatus{}
+)
+
+// LanguageStatuses lists every configured language with its last check
+// result. Languages that have not been checked yet are reported available.
+func LanguageStatuses() []LanguageStatus {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+
+	var list []LanguageStatus
+	for _, lang := range allLanguages() {
+		st, ok := statuses[lang.Name]
+		if !ok {
+			st = LanguageStatus{Name: lang.Name, Image: lang.Image, Backend: backendOf(lang), Available: true}
+		}
+		list = append(list, st)
+	}
+	return list
+}
+
+// unavailableReason returns why a language failed its last check, or "" if
+// it is usable.
+func unavailableReason(name string) string {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	if st, ok := statuses[name]; ok && !st.Available {
+		return st.Reason
+	}
+	return ""
+}
+
+func setStatus(st LanguageStatus) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	statuses[st.Name] = st
+}
+
+// CheckLanguages makes sure every language image is present, optionally
+// building custom images from their Dockerfile (or pulling stock ones), and
+// runs the hello-world self-test. Languages that fail are marked unavailable.
+func CheckLanguages(build bool) []LanguageStatus {
+	var results []LanguageStatus
+	for _, lang := range allLanguages() {
+		// Tools such as formatters never disable a language, they only fail
+		// their own requests, so a missing tool image is just logged
+		for _, tool := range lang.tools() {
+			if imageExists(tool.Image) {
+				continue
+			}
+			if !build {
+				log.Printf("Tool image %s for %s is not present", tool.Image, lang.Name)
+			} else if err := provideImage(tool.Image, tool.Dockerfile); err != nil {
+				log.Printf("Tool image for %s unavailable: %v", lang.Name, err)
+			}
+		}
+
+		st := checkLanguage(lang, build)
+		setStatus(st)
+		if st.Available {
+			log.Printf("Language %s is available (%s)", st.Name, st.Image)
+		} else {
+			log.Printf("Language %s is unavailable: %s", st.Name, st.Reason)
+		}
+		results = append(results, st)
+	}
+	return results
+}
+
+func checkLanguage(lang Language, build bool) LanguageStatus {
+	st := LanguageStatus{Name: lang.Name, Image: lang.Image, Backend: backendOf(lang), CheckedAt: time.Now()}
+
+	if wasmBackend(lang) {
+		if lang.Wasm.Module != "" {
+			if _, err := os.Stat(lang.Wasm.Module); err != nil {
+				st.Reason = fmt.Sprintf("module %s is not present", lang.Wasm.Module)
+				return st
+			}
+		}
+	} else if !imageExists(lang.Image) {
+		if !build {
+			st.Reason = fmt.Sprintf("image %s is not present", lang.Image)
+			return st
+		}
+		if err := provideImage(lang.Image, lang.Dockerfile); err != nil {
+			st.Reason = err.Error()
+			return st
+		}
+	}
+
+	res := Execute(Request{Owner: "selftest", Language: lang.Name, Code: lang.HelloWorld})
+	if res.Status != StatusSuccess {
+		st.Reason = fmt.Sprintf("self-test failed with status %s: %s", res.Status, strings.TrimSpace(res.Error+" "+res.Output))
+		return st
+	}
+	if strings.TrimSpace(res.Output) != selfTestOutput {
+		st.Reason = fmt.Sprintf("self-test printed %q, want %q", strings.TrimSpace(res.Output), selfTestOutput)
+		return st
+	}
+
+	st.Available = true
+	return st
+}
+
+func backendOf(lang Language) string {
+	if wasmBackend(lang) {
+		return BackendWasm
+	}
+	return BackendDocker
+}
+
+func imageExists(image string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return exec.CommandContext(ctx, "docker", "image", "inspect", image).Run() == nil
+}
+
+// provideImage builds a custom image from its Dockerfile or pulls a stock one.
+func provideImage(image, dockerfile string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if dockerfile != "" {
+		log.Printf("Building image %s from %s", image, dockerfile)
+		cmd = exec.CommandContext(ctx, "docker", "build", "-t", image, "-f", dockerfile, filepath.Dir(dockerfile))
+	} else {
+		log.Printf("Pulling image %s", image)
+		cmd = exec.CommandContext(ctx, "docker", "pull", image)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to provide image %s: %w: %s", image, err, lastLine(string(output)))
+	}
+	return nil
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "\n"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,556 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Language describes how code in one language is compiled and run.
+type Language struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// Dockerfile builds Image when it is not a stock image. Relative paths are
+	// resolved against the backend's working directory.
+	Dockerfile string `json:"dockerfile,omitempty"`
+	Filename   string `json:"filename"`
+	Compile    string `json:"compile,omitempty"` // Shell command run before Run, skipped when empty
+	// Run must not compile, since benchmarks time every run; compiling
+	// belongs in Compile, which they run once beforehand.
+	Run string `json:"run"`
+	// HelloWorld is the self-test program; it must print selfTestOutput.
+	HelloWorld string `json:"helloWorld"`
+
+	// CompileErrors names the diagnostics parser for failed runs, so compile
+	// and syntax errors are reported in the same structure as lint findings.
+	CompileErrors string `json:"compileErrors,omitempty"`
+
+	// Formatter rewrites Filename in place. Style options go into its command.
+	Formatter *Tool `json:"formatter,omitempty"`
+	// Linter reports problems in Filename without running it.
+	Linter *Tool `json:"linter,omitempty"`
+	// LanguageServer speaks LSP over stdin/stdout for editor sessions.
+	LanguageServer *Tool `json:"languageServer,omitempty"`
+	// TestRunner runs the test framework in test mode and writes Report.
+	TestRunner *Tool `json:"testRunner,omitempty"`
+	// Coverage replaces TestRunner when coverage is requested. It must write
+	// the test runner's report as well as its own coverage Report.
+	Coverage *Tool `json:"coverage,omitempty"`
+	// Profiler runs the program under a CPU profiler in profile mode. Report
+	// is parsed into the functions table, Artifact is offered for download.
+	Profiler *Tool `json:"profiler,omitempty"`
+	// Debugger builds the program, prints debugReadyMarker and serves DAP
+	// on 127.0.0.1:4711 inside a debug session. Its image needs socat.
+	Debugger *Tool `json:"debugger,omitempty"`
+	// Repl runs the named REPL Driver in a session that persists state
+	// between snippets.
+	Repl *Tool `json:"repl,omitempty"`
+
+	// Runtime is the OCI runtime (runc, runsc for gVisor, kata) for all of
+	// the language's containers, overriding SANDBOX_RUNTIME.
+	Runtime string `json:"runtime,omitempty"`
+
+	// Wasm runs the language in the embedded WebAssembly runtime when
+	// SANDBOX_BACKEND is wasm; languages without it stay on Docker.
+	Wasm *WasmRuntime `json:"wasm,omitempty"`
+}
+
+// Tool is an auxiliary program, such as a formatter, run in its own image
+// with the same isolation as user code.
+type Tool struct {
+	Image      string `json:"image"`
+	Dockerfile string `json:"dockerfile,omitempty"`
+	Command    string `json:"command"`
+	Parser     string `json:"parser,omitempty"`   // Diagnostics or test report parser
+	Report     string `json:"report,omitempty"`   // File the tool writes for Parser, relative to /app
+	Artifact   string `json:"artifact,omitempty"` // Raw output kept for download, defaults to Report
+	Driver     string `json:"driver,omitempty"`   // Embedded REPL driver, see replDrivers
+}
+
+// artifact is the file kept for download.
+func (t *Tool) artifact() string {
+	if t.Artifact != "" {
+		return t.Artifact
+	}
+	return t.Report
+}
+
+// clone copies the language including the tools it points to, so overrides
+// never modify the built-in defaults.
+func (l *Language) clone() *Language {
+	c := *l
+	for _, tool := range []**Tool{&c.Formatter, &c.Linter, &c.LanguageServer, &c.TestRunner, &c.Coverage, &c.Profiler, &c.Debugger, &c.Repl} {
+		if *tool != nil {
+			copied := **tool
+			*tool = &copied
+		}
+	}
+	if c.Wasm != nil {
+		wasm := *c.Wasm
+		wasm.Env = maps.Clone(wasm.Env)
+		wasm.Mounts = maps.Clone(wasm.Mounts)
+		c.Wasm = &wasm
+	}
+	return &c
+}
+
+// tools lists the tools configured for the language.
+func (l *Language) tools() []*Tool {
+	var list []*Tool
+	for _, tool := range []*Tool{l.Formatter, l.Linter, l.LanguageServer, l.TestRunner, l.Coverage, l.Profiler, l.Debugger, l.Repl} {
+		if tool != nil {
+			list = append(list, tool)
+		}
+	}
+	return list
+}
+
+// command is the shell command line executed inside the container, with
+// args and env applied to the run step only.
+func (l *Language) command(args []string, env map[string]string) string {
+	run := runWith(l.Run, args, env)
+	if l.Compile == "" {
+		return run
+	}
+	return l.Compile + " && " + run
+}
+
+var defaultLanguages = []Language{
+	{
+		Name:          "python",
+		Image:         "python:3.10-alpine",
+		Filename:      "main.py",
+		Run:           "python main.py",
+		HelloWorld:    `print("hello world")`,
+		CompileErrors: "python",
+		Formatter: &Tool{
+			Image:      "black-formatter",
+			Dockerfile: "Dockerfile.black.build",
+			Command:    "black -q main.py",
+		},
+		Linter: &Tool{
+			Image:      "pyflakes-alpine",
+			Dockerfile: "Dockerfile.pyflakes.build",
+			Command:    "pyflakes main.py",
+			Parser:     "pyflakes",
+		},
+		LanguageServer: &Tool{
+			Image:      "pyright-alpine",
+			Dockerfile: "Dockerfile.pyright.build",
+			Command:    "exec pyright-langserver --stdio",
+		},
+		TestRunner: &Tool{
+			Image:      "pytest-alpine",
+			Dockerfile: "Dockerfile.pytest.build",
+			Command:    "python -m pytest -q -p no:cacheprovider --junitxml=.test-report.xml",
+			Parser:     "junit",
+			Report:     ".test-report.xml",
+		},
+		Coverage: &Tool{
+			Image:      "pytest-alpine",
+			Dockerfile: "Dockerfile.pytest.build",
+			Command:    "COVERAGE_FILE=/tmp/.coverage coverage run -m pytest -q -p no:cacheprovider --junitxml=.test-report.xml; status=$?; COVERAGE_FILE=/tmp/.coverage coverage json -q -o .coverage.json; exit $status",
+			Parser:     "coverage-py",
+			Report:     ".coverage.json",
+		},
+		Profiler: &Tool{
+			Image:    "python:3.10-alpine",
+			Command:  `python -m cProfile -o .profile.pstats main.py; status=$?; python -c "import json, pstats; json.dump([[f[0], f[1], f[2], v[1], v[2], v[3]] for f, v in pstats.Stats('.profile.pstats').stats.items()], open('.profile.json', 'w'))"; exit $status`,
+			Parser:   "pstats-json",
+			Report:   ".profile.json",
+			Artifact: ".profile.pstats",
+		},
+		Debugger: &Tool{
+			Image:      "debugpy-alpine",
+			Dockerfile: "Dockerfile.debugpy.build",
+			Command:    "python -m py_compile main.py && echo codeexec-debugger-ready && exec python -m debugpy.adapter --host 127.0.0.1 --port 4711 >/dev/null 2>&1",
+		},
+		Repl: &Tool{
+			Image:   "python:3.10-alpine",
+			Command: "exec python -u .codeexec-repl",
+			Driver:  "python",
+		},
+		// CPython's WASI build finds its standard library under /usr/local
+		Wasm: &WasmRuntime{
+			Module: "/opt/wasm/python/bin/python.wasm",
+			Args:   []string{"python", "main.py"},
+			Env:    map[string]string{"PYTHONDONTWRITEBYTECODE": "1"},
+			Mounts: map[string]string{"/usr": "/opt/wasm/python/usr"},
+		},
+	},
+	{
+		Name:          "go",
+		Image:         "golang:1.20-alpine",
+		Filename:      "main.go",
+		Compile:       "go build -o /tmp/main main.go",
+		Run:           "/tmp/main",
+		HelloWorld:    "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello world\")\n}\n",
+		CompileErrors: "go",
+		Formatter: &Tool{
+			Image:   "golang:1.20-alpine",
+			Command: "gofmt -w main.go",
+		},
+		Linter: &Tool{
+			Image:      "staticcheck-alpine",
+			Dockerfile: "Dockerfile.staticcheck.build",
+			// staticcheck needs a module; both tools run even if vet fails
+			Command: "go mod init main >/dev/null 2>&1; go vet .; staticcheck .",
+			Parser:  "go",
+		},
+		LanguageServer: &Tool{
+			Image:      "gopls-alpine",
+			Dockerfile: "Dockerfile.gopls.build",
+			Command:    "[ -f go.mod ] || go mod init main >/dev/null 2>&1; exec gopls",
+		},
+		TestRunner: &Tool{
+			Image:   "golang:1.20-alpine",
+			Command: "[ -f go.mod ] || go mod init main >/dev/null 2>&1; go test -json ./... > .test-report.json",
+			Parser:  "go-test-json",
+			Report:  ".test-report.json",
+		},
+		// The profile names files by import path; the module prefix is
+		// stripped so they match the submitted paths
+		Coverage: &Tool{
+			Image:   "golang:1.20-alpine",
+			Command: "[ -f go.mod ] || go mod init main >/dev/null 2>&1; go test -json -covermode=count -coverprofile=.coverage.out ./... > .test-report.json; status=$?; sed -i \"s#^$(go list -m)/##\" .coverage.out; exit $status",
+			Parser:  "go-cover",
+			Report:  ".coverage.out",
+		},
+		// main is renamed so that the image's wrapper can start and stop
+		// the CPU profile around it
+		Profiler: &Tool{
+			Image:      "go-pprof-alpine",
+			Dockerfile: "Dockerfile.go-pprof.build",
+			Command:    "sed -i 's/^func main()/func codeexecMain()/' main.go && cp /opt/codeexec/pprof_main.go zz_codeexec_pprof.go && go build -o /tmp/main main.go zz_codeexec_pprof.go && /tmp/main",
+			Parser:     "pprof",
+			Report:     ".profile.pb.gz",
+		},
+		// Clients launch /tmp/main in "exec" mode
+		Debugger: &Tool{
+			Image:      "delve-alpine",
+			Dockerfile: "Dockerfile.delve.build",
+			Command:    "[ -f go.mod ] || go mod init main >/dev/null 2>&1; go build -gcflags='all=-N -l' -o /tmp/main . && echo codeexec-debugger-ready && exec dlv dap --listen=127.0.0.1:4711 >/dev/null 2>&1",
+		},
+		// The image holds a module with yaegi already built, so only the
+		// driver itself is compiled when a session starts
+		Repl: &Tool{
+			Image:      "yaegi-alpine",
+			Dockerfile: "Dockerfile.yaegi.build",
+			Command:    "cp .codeexec-repl /opt/repl/main.go && cd /opt/repl && go build -o /tmp/repl main.go && cd /app && exec /tmp/repl",
+			Driver:     "yaegi",
+		},
+		// Only the standard library is available; the build cache is shared
+		// between executions
+		Wasm: &WasmRuntime{
+			Compile: "GOOS=wasip1 GOARCH=wasm GOCACHE=/cache GOPROXY=off GOTOOLCHAIN=local go build -o main.wasm main.go",
+			Args:    []string{"main"},
+		},
+	},
+	{
+		Name:          "cpp",
+		Image:         "cpp-compiler-alpine",
+		Dockerfile:    "Dockerfile.cpp.build",
+		Filename:      "main.cpp",
+		Compile:       "g++ -o main main.cpp",
+		Run:           "./main",
+		HelloWorld:    "#include <iostream>\n\nint main() {\n    std::cout << \"hello world\" << std::endl;\n}\n",
+		CompileErrors: "gcc",
+		Formatter: &Tool{
+			Image:      "clang-tools-alpine",
+			Dockerfile: "Dockerfile.clang-tools.build",
+			Command:    "clang-format -i --style=Google main.cpp",
+		},
+		Linter: &Tool{
+			Image:      "clang-tools-alpine",
+			Dockerfile: "Dockerfile.clang-tools.build",
+			Command:    "clang-tidy --quiet main.cpp -- -std=c++17",
+			Parser:     "gcc",
+		},
+		LanguageServer: &Tool{
+			Image:      "clang-tools-alpine",
+			Dockerfile: "Dockerfile.clang-tools.build",
+			Command:    "exec clangd",
+		},
+		// Every .cpp file except main.cpp is linked against gtest_main, so
+		// code under test lives in headers or other source files
+		TestRunner: &Tool{
+			Image:      "gtest-alpine",
+			Dockerfile: "Dockerfile.gtest.build",
+			Command:    "g++ -std=c++17 -o /tmp/tests $(find . -name '*.cpp' ! -path ./main.cpp) -lgtest -lgtest_main -pthread && /tmp/tests --gtest_output=xml:.test-report.xml",
+			Parser:     "junit",
+			Report:     ".test-report.xml",
+		},
+		Coverage: &Tool{
+			Image:      "gtest-alpine",
+			Dockerfile: "Dockerfile.gtest.build",
+			Command:    "g++ -std=c++17 --coverage -o /tmp/tests $(find . -name '*.cpp' ! -path ./main.cpp) -lgtest -lgtest_main -pthread && { /tmp/tests --gtest_output=xml:.test-report.xml; status=$?; gcovr --root . --json .coverage.json /tmp; exit $status; }",
+			Parser:     "gcovr",
+			Report:     ".coverage.json",
+		},
+		Profiler: &Tool{
+			Image:      "gperftools-alpine",
+			Dockerfile: "Dockerfile.gperftools.build",
+			Command:    "g++ -g -O2 -o main main.cpp -Wl,--no-as-needed -lprofiler -Wl,--as-needed && CPUPROFILE=/tmp/cpu.prof CPUPROFILE_FREQUENCY=1000 ./main; status=$?; [ -f /tmp/cpu.prof ] && pprof -proto -output .profile.pb.gz main /tmp/cpu.prof >/dev/null 2>&1; exit $status",
+			Parser:     "pprof",
+			Report:     ".profile.pb.gz",
+		},
+		// gdb only speaks DAP on stdio, so socat serves it on the port; the
+		// program's own output would corrupt that stream and is discarded
+		Debugger: &Tool{
+			Image:      "gdb-alpine",
+			Dockerfile: "Dockerfile.gdb.build",
+			Command:    "g++ -g -O0 -o main main.cpp && echo 'set inferior-tty /dev/null' > /tmp/.gdbinit && echo codeexec-debugger-ready && exec socat TCP-LISTEN:4711,bind=127.0.0.1,reuseaddr,fork EXEC:'gdb -q -i dap' >/dev/null 2>&1",
+		},
+		// WASI has no exception support yet
+		Wasm: &WasmRuntime{
+			Compile: "/opt/wasi-sdk/bin/clang++ --target=wasm32-wasip1 -O2 -fno-exceptions -o main.wasm main.cpp",
+			Args:    []string{"main"},
+		},
+	},
+	{
+		Name:          "java",
+		Image:         "openjdk:17-alpine",
+		Filename:      "Main.java",
+		Compile:       "javac Main.java",
+		Run:           "java Main",
+		HelloWorld:    "public class Main {\n    public static void main(String[] args) {\n        System.out.println(\"hello world\");\n    }\n}\n",
+		CompileErrors: "javac",
+		Formatter: &Tool{
+			Image:      "google-java-format",
+			Dockerfile: "Dockerfile.google-java-format.build",
+			Command:    "google-java-format -i Main.java",
+		},
+		Linter: &Tool{
+			Image:   "openjdk:17-alpine",
+			Command: "javac -Xlint:all -d /tmp Main.java",
+			Parser:  "javac",
+		},
+		LanguageServer: &Tool{
+			Image:      "jdtls-alpine",
+			Dockerfile: "Dockerfile.jdtls.build",
+			Command:    "exec jdtls -data /tmp/jdtls-workspace",
+		},
+		TestRunner: &Tool{
+			Image:      "junit-alpine",
+			Dockerfile: "Dockerfile.junit.build",
+			Command:    "mkdir -p /tmp/classes && javac -d /tmp/classes -cp /opt/junit/junit-platform-console-standalone.jar $(find . -name '*.java') && java -jar /opt/junit/junit-platform-console-standalone.jar -cp /tmp/classes --scan-classpath --disable-banner --reports-dir=.test-reports",
+			Parser:     "junit",
+			Report:     ".test-reports/TEST-junit-jupiter.xml",
+		},
+	},
+	{
+		Name:          "javascript",
+		Image:         "node:alpine",
+		Filename:      "main.js",
+		Run:           "node main.js",
+		HelloWorld:    `console.log("hello world");`,
+		CompileErrors: "node",
+		Formatter: &Tool{
+			Image:      "prettier-alpine",
+			Dockerfile: "Dockerfile.prettier.build",
+			Command:    "prettier --write main.js",
+		},
+		Linter: &Tool{
+			Image:      "eslint-alpine",
+			Dockerfile: "Dockerfile.eslint.build",
+			Command:    "eslint --config /etc/eslint/eslint.config.js --format json main.js",
+			Parser:     "eslint",
+		},
+		LanguageServer: &Tool{
+			Image:      "typescript-language-server-alpine",
+			Dockerfile: "Dockerfile.typescript-language-server.build",
+			Command:    "exec typescript-language-server --stdio",
+		},
+		TestRunner: &Tool{
+			Image:      "jest-alpine",
+			Dockerfile: "Dockerfile.jest.build",
+			Command:    "jest --ci --rootDir /app --json --outputFile=.test-report.json",
+			Parser:     "jest",
+			Report:     ".test-report.json",
+		},
+		Coverage: &Tool{
+			Image:      "jest-alpine",
+			Dockerfile: "Dockerfile.jest.build",
+			Command:    "c8 --reporter=json --report-dir=.coverage --temp-directory=/tmp/c8 jest --ci --rootDir /app --json --outputFile=.test-report.json",
+			Parser:     "istanbul",
+			Report:     ".coverage/coverage-final.json",
+		},
+		Profiler: &Tool{
+			Image:   "node:alpine",
+			Command: "node --cpu-prof --cpu-prof-dir=. --cpu-prof-name=.profile.cpuprofile main.js",
+			Parser:  "v8-cpuprofile",
+			Report:  ".profile.cpuprofile",
+		},
+		// js-debug drives the node inspector and translates it to DAP
+		Debugger: &Tool{
+			Image:      "js-debug-alpine",
+			Dockerfile: "Dockerfile.js-debug.build",
+			Command:    "node --check main.js && echo codeexec-debugger-ready && exec node /opt/js-debug/src/dapDebugServer.js 4711 127.0.0.1 >/dev/null 2>&1",
+		},
+		Repl: &Tool{
+			Image:   "node:alpine",
+			Command: "exec node .codeexec-repl",
+			Driver:  "node",
+		},
+		Wasm: &WasmRuntime{
+			Module: "/opt/wasm/qjs.wasm",
+			Args:   []string{"qjs", "main.js"},
+		},
+	},
+	{
+		Name:          "c",
+		Image:         "c-compiler-alpine",
+		Dockerfile:    "Dockerfile.c.build",
+		Filename:      "main.c",
+		Compile:       "gcc -std=c17 -O2 -o main main.c -lm",
+		Run:           "./main",
+		HelloWorld:    "#include <stdio.h>\n\nint main(void) {\n    printf(\"hello world\\n\");\n    return 0;\n}\n",
+		CompileErrors: "gcc",
+	},
+	// Only the standard library is available, as there is no network to
+	// fetch crates from
+	{
+		Name:          "rust",
+		Image:         "rust-alpine",
+		Dockerfile:    "Dockerfile.rust.build",
+		Filename:      "main.rs",
+		Compile:       "rustc --edition 2021 -O -o main main.rs",
+		Run:           "./main",
+		HelloWorld:    "fn main() {\n    println!(\"hello world\");\n}\n",
+		CompileErrors: "rustc",
+	},
+	// Type errors fail the run like compile errors do. The output goes to
+	// /tmp so that only the submitted files are in /app
+	{
+		Name:          "typescript",
+		Image:         "typescript-alpine",
+		Dockerfile:    "Dockerfile.typescript.build",
+		Filename:      "main.ts",
+		Compile:       "tsc --strict --target es2022 --module commonjs --pretty false --typeRoots /usr/local/lib/node_modules/@types --types node --outDir /tmp/out main.ts",
+		Run:           "node /tmp/out/main.js",
+		HelloWorld:    `console.log("hello world");`,
+		CompileErrors: "tsc",
+	},
+	{
+		Name:          "kotlin",
+		Image:         "kotlin-alpine",
+		Dockerfile:    "Dockerfile.kotlin.build",
+		Filename:      "Main.kt",
+		Compile:       "kotlinc Main.kt -include-runtime -d /tmp/main.jar",
+		Run:           "java -jar /tmp/main.jar",
+		HelloWorld:    "fun main() {\n    println(\"hello world\")\n}\n",
+		CompileErrors: "kotlinc",
+	},
+	{
+		Name:          "ruby",
+		Image:         "ruby:3.3-alpine",
+		Filename:      "main.rb",
+		Run:           "ruby main.rb",
+		HelloWorld:    `puts "hello world"`,
+		CompileErrors: "ruby",
+	},
+	// Every .cs file in /app is compiled into the image's prepared project,
+	// which restores offline
+	{
+		Name:          "csharp",
+		Image:         "csharp-alpine",
+		Dockerfile:    "Dockerfile.csharp.build",
+		Filename:      "Program.cs",
+		Compile:       "cp /opt/csharp/codeexec.csproj . && dotnet build -nologo -v q -clp:NoSummary -o /tmp/out",
+		Run:           "dotnet /tmp/out/codeexec.dll",
+		HelloWorld:    "Console.WriteLine(\"hello world\");\n",
+		CompileErrors: "msbuild",
+	},
+}
+
+var (
+	languagesMu sync.RWMutex
+	languages   = indexLanguages(defaultLanguages)
+)
+
+func indexLanguages(list []Language) map[string]*Language {
+	m := make(map[string]*Language, len(list))
+	for i := range list {
+		lang := list[i]
+		m[lang.Name] = &lang
+	}
+	return m
+}
+
+// LoadLanguages applies overrides from the JSON file named by
+// SANDBOX_LANGUAGES_FILE, if set. Entries replace the built-in language with
+// the same name field by field; unknown names add a new language.
+func LoadLanguages() error {
+	path := os.Getenv("SANDBOX_LANGUAGES_FILE")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read languages file: %w", err)
+	}
+	return OverrideLanguages(data)
+}
+
+// OverrideLanguages applies overrides in the format of the languages file.
+func OverrideLanguages(data []byte) error {
+	languagesMu.Lock()
+	defer languagesMu.Unlock()
+
+	var overrides []json.RawMessage
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("failed to parse languages file: %w", err)
+	}
+	for _, raw := range overrides {
+		var named struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(raw, &named); err != nil || named.Name == "" {
+			return fmt.Errorf("languages file: every entry needs a name")
+		}
+		lang, ok := languages[named.Name]
+		if !ok {
+			lang = &Language{}
+		} else {
+			lang = lang.clone()
+		}
+		// Unmarshalling over the existing entry keeps fields the override omits
+		if err := json.Unmarshal(raw, lang); err != nil {
+			return fmt.Errorf("languages file: invalid entry %q: %w", named.Name, err)
+		}
+		if lang.Filename == "" || (lang.Wasm == nil && (lang.Image == "" || lang.Run == "")) {
+			return fmt.Errorf("languages file: %q needs filename, and image and run or wasm", named.Name)
+		}
+		if lang.Wasm != nil && ((lang.Wasm.Module == "") == (lang.Wasm.Compile == "") || len(lang.Wasm.Args) == 0) {
+			return fmt.Errorf("languages file: %q wasm needs args and either module or compile", named.Name)
+		}
+		languages[named.Name] = lang
+	}
+	return nil
+}
+
+// lookupLanguage returns a copy of the named language configuration.
+func lookupLanguage(name string) (Language, bool) {
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+	lang, ok := languages[name]
+	if !ok {
+		return Language{}, false
+	}
+	return *lang, true
+}
+
+// allLanguages returns every configured language sorted by name.
+func allLanguages() []Language {
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+	list := make([]Language, 0, len(languages))
+	for _, lang := range languages {
+		list = append(list, *lang)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
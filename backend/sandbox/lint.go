@@ -0,0 +1,57 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const lintTimeout = 20 * time.Second
+
+var ErrNoLinter = errors.New("no linter configured for this language")
+
+// Lint runs the language's linters over code without executing it. Linters
+// exit non-zero when they find problems, so only failures that produced no
+// diagnostics at all are treated as errors. The usage of the linter's
+// container is returned whenever it ran.
+func Lint(owner, language, code string) ([]Diagnostic, *Usage, error) {
+	lang, ok := lookupLanguage(language)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+	if lang.Linter == nil {
+		return nil, nil, ErrNoLinter
+	}
+
+	out, err := runOnce(uuid.New().String(), owner, lintTimeout, containerSpec{
+		image:   lang.Linter.Image,
+		runtime: lang.runtime(),
+		files:   map[string]string{lang.Filename: code},
+		command: lang.Linter.Command,
+	})
+	diags := parseDiagnostics(lang.Linter.Parser, out.output)
+
+	status, exitCode := statusOf(err)
+	if status != StatusSuccess && (exitCode == 0 || len(diags) == 0) {
+		return nil, out.usage, fmt.Errorf("linter failed: %w: %s", err, lastLine(out.output))
+	}
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	return diags, out.usage, nil
+}
+
+// compileDiagnostics extracts compiler and syntax errors from the output of a
+// failed run, keeping only those that point into the submitted files.
+func compileDiagnostics(lang Language, output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, d := range parseDiagnostics(lang.CompileErrors, output) {
+		if !strings.HasPrefix(d.File, "..") && !strings.HasPrefix(d.File, "/") {
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
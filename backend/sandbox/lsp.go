@@ -0,0 +1,74 @@
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lspIdleTimeout = 10 * time.Minute
+	lspMaxLifetime = 2 * time.Hour
+	// lspMaxMessage bounds a single JSON-RPC message from the server
+	lspMaxMessage = 16 << 20
+)
+
+var ErrNoLanguageServer = errors.New("no language server configured for this language")
+
+// StartLanguageServer starts a sandboxed language server session for owner.
+// The workspace root inside the container is /app (file:///app), which
+// initially holds an empty source file named after the language's Filename.
+func StartLanguageServer(owner, language string) (*Session, error) {
+	lang, ok := lookupLanguage(language)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+	if lang.LanguageServer == nil {
+		return nil, ErrNoLanguageServer
+	}
+
+	return startSession(owner, sessionSpec{
+		kind:        "lsp",
+		image:       lang.LanguageServer.Image,
+		runtime:     lang.runtime(),
+		command:     lang.LanguageServer.Command,
+		files:       map[string]string{lang.Filename: ""},
+		idleTimeout: lspIdleTimeout,
+		maxLifetime: lspMaxLifetime,
+	})
+}
+
+// ReadRPCMessage reads one Content-Length framed JSON-RPC message, the
+// framing shared by LSP and DAP.
+func ReadRPCMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header %q", header.Get("Content-Length"))
+	}
+	if length > lspMaxMessage {
+		return nil, fmt.Errorf("message of %d bytes exceeds limit", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// WriteRPCMessage writes body with Content-Length framing.
+func WriteRPCMessage(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
@@ -0,0 +1,18 @@
+package sandbox
+
+import "expvar"
+
+// Sandbox counters, published under "sandbox" on the expvar endpoint.
+var (
+	metrics = expvar.NewMap("sandbox")
+
+	reapedContainers = new(expvar.Int)
+	reapedDirs       = new(expvar.Int)
+	reapedNetworks   = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("reapedContainers", reapedContainers)
+	metrics.Set("reapedDirs", reapedDirs)
+	metrics.Set("reapedNetworks", reapedNetworks)
+}
@@ -0,0 +1,338 @@
+package sandbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// Profiled runs get twice the normal timeout so that profiler overhead does
+// not push a program over its limit.
+const (
+	profileTimeout = 2 * executionTimeout
+	topFunctions   = 25
+	maxArtifactSz  = 8 << 20 // 8 MiB
+	// Profiles are written inside the sandbox, so they are parsed with
+	// bounds on decompressed size and call tree depth
+	maxProfileSize  = 64 << 20 // 64 MiB
+	maxProfileDepth = 1024
+)
+
+var ErrNoProfiler = errors.New("no profiler configured for this language")
+
+// ProfileEntry is one function in a profile. Self time excludes callees,
+// total time includes them.
+type ProfileEntry struct {
+	Function     string  `json:"function"`
+	File         string  `json:"file,omitempty"`
+	Line         int     `json:"line,omitempty"`
+	SelfMs       float64 `json:"selfMs"`
+	TotalMs      float64 `json:"totalMs"`
+	SelfPercent  float64 `json:"selfPercent"`
+	TotalPercent float64 `json:"totalPercent"`
+	Calls        int     `json:"calls,omitempty"` // Only reported by tracing profilers
+}
+
+// Profile is the normalised result of a profiled run: the functions with
+// the most self time, and the raw profile for download.
+type Profile struct {
+	TotalMs   float64        `json:"totalMs"`
+	Functions []ProfileEntry `json:"functions"`
+	// Artifact is where the raw profile can be downloaded, set by the API.
+	Artifact string `json:"artifact,omitempty"`
+
+	Filename string `json:"-"`
+	Raw      []byte `json:"-"`
+}
+
+// profileParsers are referenced by name from the profiler's Parser.
+var profileParsers = map[string]func([]byte) (*Profile, error){
+	"pprof":         parsePprof,
+	"pstats-json":   parsePstatsJSON,
+	"v8-cpuprofile": parseV8CPUProfile,
+}
+
+// functionTimes accumulates self and total time per function.
+type functionTimes struct {
+	entries map[string]*ProfileEntry
+	totalMs float64
+}
+
+func newFunctionTimes() *functionTimes {
+	return &functionTimes{entries: map[string]*ProfileEntry{}}
+}
+
+func (t *functionTimes) entry(function, file string, line int) *ProfileEntry {
+	key := function + "\x00" + file
+	e, ok := t.entries[key]
+	if !ok {
+		e = &ProfileEntry{Function: function, Line: line}
+		if file != "" {
+			e.File = cleanPath(file)
+		}
+		t.entries[key] = e
+	}
+	return e
+}
+
+// profile keeps the functions with the most self time.
+func (t *functionTimes) profile() *Profile {
+	p := &Profile{TotalMs: round2(t.totalMs), Functions: []ProfileEntry{}}
+	for _, e := range t.entries {
+		if t.totalMs > 0 {
+			e.SelfPercent = math.Round(e.SelfMs*1000/t.totalMs) / 10
+			e.TotalPercent = math.Round(e.TotalMs*1000/t.totalMs) / 10
+		}
+		e.SelfMs, e.TotalMs = round2(e.SelfMs), round2(e.TotalMs)
+		p.Functions = append(p.Functions, *e)
+	}
+	sort.Slice(p.Functions, func(i, j int) bool {
+		a, b := p.Functions[i], p.Functions[j]
+		if a.SelfMs != b.SelfMs {
+			return a.SelfMs > b.SelfMs
+		}
+		return a.TotalMs > b.TotalMs
+	})
+	if len(p.Functions) > topFunctions {
+		p.Functions = p.Functions[:topFunctions]
+	}
+	return p
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// parsePprof reads a CPU profile in pprof's protobuf format, as written by
+// Go's runtime/pprof or converted from gperftools by `pprof -proto`.
+func parsePprof(data []byte) (*Profile, error) {
+	// profile.Parse would inflate a gzipped profile without a size limit
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing profile: %w", err)
+		}
+		data, err = io.ReadAll(io.LimitReader(gz, maxProfileSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing profile: %w", err)
+		}
+		if len(data) > maxProfileSize {
+			return nil, fmt.Errorf("profile is larger than %d bytes", maxProfileSize)
+		}
+	}
+	prof, err := profile.ParseData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer the sample value measured in time over the sample count
+	value := len(prof.SampleType) - 1
+	for i, st := range prof.SampleType {
+		if st.Unit == "nanoseconds" {
+			value = i
+		}
+	}
+	if value < 0 {
+		return nil, fmt.Errorf("profile has no sample values")
+	}
+	scale := 1e-6 // nanoseconds to milliseconds
+	if prof.SampleType[value].Unit != "nanoseconds" {
+		scale = float64(max(prof.Period, 0)) * 1e-6
+	}
+
+	times := newFunctionTimes()
+	for _, sample := range prof.Sample {
+		ms := float64(max(sample.Value[value], 0)) * scale
+		times.totalMs += ms
+		seen := map[*ProfileEntry]bool{}
+		for i, loc := range sample.Location {
+			// Inlined calls come first within a location
+			for j, line := range loc.Line {
+				if line.Function == nil {
+					continue
+				}
+				e := times.entry(line.Function.Name, line.Function.Filename, int(line.Function.StartLine))
+				if i == 0 && j == 0 {
+					e.SelfMs += ms
+				}
+				if !seen[e] {
+					seen[e] = true
+					e.TotalMs += ms
+				}
+			}
+		}
+	}
+	return times.profile(), nil
+}
+
+// parsePstatsJSON reads the summary the Python profiler command writes from
+// cProfile's stats: a list of [file, line, function, calls, self seconds,
+// cumulative seconds].
+func parsePstatsJSON(data []byte) (*Profile, error) {
+	var rows [][]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+
+	times := newFunctionTimes()
+	for _, row := range rows {
+		if len(row) != 6 {
+			continue
+		}
+		file, _ := row[0].(string)
+		line, _ := row[1].(float64)
+		function, _ := row[2].(string)
+		calls, _ := row[3].(float64)
+		self, _ := row[4].(float64)
+		total, _ := row[5].(float64)
+		if file == "~" {
+			file = "" // Built-in functions
+		}
+		e := times.entry(function, file, int(line))
+		e.Calls += int(calls)
+		e.SelfMs += self * 1000
+		e.TotalMs += total * 1000
+		times.totalMs += self * 1000
+	}
+	return times.profile(), nil
+}
+
+// parseV8CPUProfile reads the .cpuprofile JSON written by node --cpu-prof.
+// The call tree is walked iteratively, visiting each node once and ignoring
+// nodes deeper than maxProfileDepth, so cyclic or very deep trees are safe.
+func parseV8CPUProfile(data []byte) (*Profile, error) {
+	var prof struct {
+		Nodes []struct {
+			ID        int `json:"id"`
+			CallFrame struct {
+				FunctionName string `json:"functionName"`
+				URL          string `json:"url"`
+				LineNumber   int    `json:"lineNumber"` // 0-based
+			} `json:"callFrame"`
+			HitCount int   `json:"hitCount"`
+			Children []int `json:"children"`
+		} `json:"nodes"`
+		StartTime int64 `json:"startTime"` // Microseconds
+		EndTime   int64 `json:"endTime"`
+		Samples   []int `json:"samples"`
+	}
+	if err := json.Unmarshal(data, &prof); err != nil {
+		return nil, err
+	}
+	if len(prof.Nodes) == 0 || len(prof.Samples) == 0 || prof.EndTime <= prof.StartTime {
+		return newFunctionTimes().profile(), nil
+	}
+	intervalMs := float64(prof.EndTime-prof.StartTime) / 1000 / float64(len(prof.Samples))
+
+	byID := map[int]int{}
+	isChild := map[int]bool{}
+	for i, n := range prof.Nodes {
+		byID[n.ID] = i
+		for _, c := range n.Children {
+			isChild[c] = true
+		}
+	}
+
+	// Each node's self time is added to every distinct function on its
+	// stack. active holds those functions in the order they were entered
+	// and depth how often each is on the stack.
+	type step struct {
+		id    int
+		entry *ProfileEntry // Function to leave, for exit steps
+		exit  bool
+	}
+	times := newFunctionTimes()
+	visited := map[int]bool{}
+	depth := map[*ProfileEntry]int{}
+	var active []*ProfileEntry
+	var todo []step
+	stackLen := 0
+	for _, n := range prof.Nodes {
+		if !isChild[n.ID] {
+			todo = append(todo, step{id: n.ID})
+		}
+	}
+	for len(todo) > 0 {
+		st := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if st.exit {
+			stackLen--
+			if st.entry != nil {
+				if depth[st.entry]--; depth[st.entry] == 0 {
+					active = active[:len(active)-1]
+				}
+			}
+			continue
+		}
+		i, ok := byID[st.id]
+		if !ok || visited[st.id] || stackLen >= maxProfileDepth {
+			continue
+		}
+		visited[st.id] = true
+		n := prof.Nodes[i]
+
+		var e *ProfileEntry
+		if name := n.CallFrame.FunctionName; name != "(root)" && name != "(idle)" {
+			if name == "" {
+				name = "(anonymous)"
+			}
+			file := strings.TrimPrefix(n.CallFrame.URL, "file://")
+			e = times.entry(name, file, n.CallFrame.LineNumber+1)
+			if depth[e] == 0 {
+				active = append(active, e)
+			}
+			depth[e]++
+
+			ms := float64(max(n.HitCount, 0)) * intervalMs
+			e.SelfMs += ms
+			times.totalMs += ms
+			for _, a := range active {
+				a.TotalMs += ms
+			}
+		}
+		stackLen++
+		todo = append(todo, step{id: st.id, entry: e, exit: true})
+		for c := len(n.Children) - 1; c >= 0; c-- {
+			todo = append(todo, step{id: n.Children[c]})
+		}
+	}
+	return times.profile(), nil
+}
+
+// collectProfile parses the profiler's report and attaches the raw profile
+// unless it is too large to keep. It returns nil if no profile was written.
+func collectProfile(lang Language, id string, files map[string][]byte) (p *Profile) {
+	report, ok := files[lang.Profiler.Report]
+	parse, known := profileParsers[lang.Profiler.Parser]
+	if !ok || !known {
+		return nil
+	}
+	// A crafted profile must not take the server down with it
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Parsing %s profile of execution %s panicked: %v", lang.Name, id, r)
+			p = nil
+		}
+	}()
+	p, err := parse(report)
+	if err != nil {
+		log.Printf("Failed to parse %s profile of execution %s: %v", lang.Name, id, err)
+		return nil
+	}
+	artifact := lang.Profiler.artifact()
+	if raw, ok := files[artifact]; ok && len(raw) <= maxArtifactSz {
+		p.Raw = raw
+		p.Filename = strings.TrimPrefix(path.Base(artifact), ".")
+	}
+	return p
+}
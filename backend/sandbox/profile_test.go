@@ -0,0 +1,239 @@
+package sandbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// v8Node builds a .cpuprofile node for the tests.
+func v8Node(id int, name string, hits int, children ...int) string {
+	kids := make([]string, len(children))
+	for i, c := range children {
+		kids[i] = fmt.Sprint(c)
+	}
+	return fmt.Sprintf(`{"id":%d,"callFrame":{"functionName":%q,"url":"file:///app/main.js","lineNumber":0},"hitCount":%d,"children":[%s]}`,
+		id, name, hits, strings.Join(kids, ","))
+}
+
+func v8Profile(nodes ...string) []byte {
+	// 10 samples over 10ms, so every hit is worth 1ms
+	return []byte(`{"nodes":[` + strings.Join(nodes, ",") + `],"startTime":0,"endTime":10000,"samples":[1,1,1,1,1,1,1,1,1,1]}`)
+}
+
+func TestParseV8CPUProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    map[string][2]float64 // Function to self and total ms
+		wantErr bool
+	}{
+		{
+			name: "tree",
+			data: v8Profile(
+				v8Node(1, "(root)", 0, 2),
+				v8Node(2, "main", 1, 3, 4),
+				v8Node(3, "work", 3),
+				v8Node(4, "(anonymous)", 2),
+			),
+			want: map[string][2]float64{"main": {1, 6}, "work": {3, 3}, "(anonymous)": {2, 2}},
+		},
+		{
+			name: "recursion counts total time once",
+			data: v8Profile(
+				v8Node(1, "(root)", 0, 2),
+				v8Node(2, "fib", 1, 3),
+				v8Node(3, "fib", 2),
+			),
+			want: map[string][2]float64{"fib": {3, 3}},
+		},
+		{
+			name: "cycle is visited once",
+			data: v8Profile(
+				v8Node(1, "(root)", 0, 2),
+				v8Node(2, "a", 1, 3),
+				v8Node(3, "b", 1, 2),
+			),
+			want: map[string][2]float64{"a": {1, 2}, "b": {1, 1}},
+		},
+		{
+			name: "shared child is visited once",
+			data: v8Profile(
+				v8Node(1, "(root)", 0, 2, 3),
+				v8Node(2, "a", 0, 4),
+				v8Node(3, "b", 0, 4),
+				v8Node(4, "c", 1),
+			),
+			want: map[string][2]float64{"a": {0, 1}, "b": {0, 0}, "c": {1, 1}},
+		},
+		{
+			name: "unknown children and negative hits are ignored",
+			data: v8Profile(
+				v8Node(1, "(root)", 0, 2, 99),
+				v8Node(2, "main", -5),
+			),
+			want: map[string][2]float64{"main": {0, 0}},
+		},
+		{
+			name: "end before start",
+			data: []byte(`{"nodes":[` + v8Node(1, "main", 5) + `],"startTime":10,"endTime":0,"samples":[1]}`),
+			want: map[string][2]float64{},
+		},
+		{
+			name: "no samples",
+			data: []byte(`{"nodes":[` + v8Node(1, "main", 5) + `]}`),
+			want: map[string][2]float64{},
+		},
+		{
+			name:    "malformed JSON",
+			data:    []byte(`{"nodes":[`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseV8CPUProfile(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseV8CPUProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := map[string][2]float64{}
+			for _, f := range p.Functions {
+				got[f.Function] = [2]float64{f.SelfMs, f.TotalMs}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("parseV8CPUProfile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseV8CPUProfileDeepChain(t *testing.T) {
+	// A chain far deeper than maxProfileDepth must neither recurse nor hang
+	const n = 200000
+	nodes := make([]string, n)
+	for i := range nodes {
+		if i == n-1 {
+			nodes[i] = v8Node(i+1, "leaf", 1)
+		} else {
+			nodes[i] = v8Node(i+1, fmt.Sprintf("f%d", i%7), 1, i+2)
+		}
+	}
+	p, err := parseV8CPUProfile(v8Profile(nodes...))
+	if err != nil {
+		t.Fatalf("parseV8CPUProfile() error = %v", err)
+	}
+	for _, f := range p.Functions {
+		if f.Function == "leaf" {
+			t.Errorf("node beyond maxProfileDepth was counted")
+		}
+	}
+	if p.TotalMs != maxProfileDepth {
+		t.Errorf("TotalMs = %v, want %d", p.TotalMs, maxProfileDepth)
+	}
+}
+
+func TestParsePstatsJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string][2]float64
+		wantErr bool
+	}{
+		{
+			name: "rows",
+			data: `[["main.py", 1, "<module>", 1, 0.001, 0.005], ["~", 0, "<built-in method time.sleep>", 2, 0.004, 0.004]]`,
+			want: map[string][2]float64{"<module>": {1, 5}, "<built-in method time.sleep>": {4, 4}},
+		},
+		{
+			name: "short and mistyped rows are skipped or zeroed",
+			data: `[["main.py", 1, "f"], [1, "x", 2, "y", "z", null]]`,
+			want: map[string][2]float64{"": {0, 0}},
+		},
+		{
+			name:    "not a list",
+			data:    `{"rows": []}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePstatsJSON([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePstatsJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := map[string][2]float64{}
+			for _, f := range p.Functions {
+				got[f.Function] = [2]float64{f.SelfMs, f.TotalMs}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("parsePstatsJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func pprofData(t *testing.T) []byte {
+	t.Helper()
+	fn := &profile.Function{ID: 1, Name: "main.work", Filename: "/app/main.go", StartLine: 3}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn, Line: 4}}}
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10000000,
+		Sample:     []*profile.Sample{{Location: []*profile.Location{loc}, Value: []int64{3, 30000000}}},
+		Location:   []*profile.Location{loc},
+		Function:   []*profile.Function{fn},
+	}
+	var buf bytes.Buffer
+	if err := prof.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParsePprof(t *testing.T) {
+	valid := pprofData(t)
+
+	// Zeros compress extremely well, so this is a small file that inflates
+	// past maxProfileSize
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	gz.Write(make([]byte, maxProfileSize+1))
+	gz.Close()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantMs  float64
+		wantErr bool
+	}{
+		{name: "gzipped", data: valid, wantMs: 30},
+		{name: "gzip bomb", data: bomb.Bytes(), wantErr: true},
+		{name: "truncated gzip", data: valid[:len(valid)/2], wantErr: true},
+		{name: "garbage", data: []byte("not a profile"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePprof(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePprof() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if p.TotalMs != tt.wantMs || len(p.Functions) != 1 || p.Functions[0].File != "main.go" {
+				t.Errorf("parsePprof() = %+v, want main.work in main.go with %vms", p, tt.wantMs)
+			}
+		})
+	}
+}
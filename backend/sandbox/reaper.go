@@ -0,0 +1,181 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels attached to every sandbox container so that leftovers can be found
+// after a crash.
+const (
+	labelInstance  = "codeexec.instance"
+	labelExecution = "codeexec.execution"
+	labelDeadline  = "codeexec.deadline" // Unix seconds after which the container is stale
+)
+
+const (
+	execRoot = "/code-exec"
+	// reapGrace is how long past its deadline a container or temp dir may
+	// linger before the reaper removes it.
+	reapGrace = time.Minute
+)
+
+// instanceID names this backend process in container labels. It defaults to
+// the hostname, which is the container ID under docker-compose.
+var instanceID = func() string {
+	if id := os.Getenv("BACKEND_INSTANCE_ID"); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "code-editor"
+	}
+	return host
+}()
+
+// containerLabels returns the docker run flags labelling an execution.
+func containerLabels(executionID string, deadline time.Time) []string {
+	return []string{
+		"--label", labelInstance + "=" + instanceID,
+		"--label", labelExecution + "=" + executionID,
+		"--label", labelDeadline + "=" + strconv.FormatInt(deadline.Unix(), 10),
+	}
+}
+
+// StartReaper removes orphaned sandbox containers, networks and temp
+// directories once immediately and then every interval. On the first pass
+// every container or network of this instance that is not tracked by the
+// registry is orphaned.
+func StartReaper(interval time.Duration) {
+	reap(true)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			reap(false)
+		}
+	}()
+}
+
+func reap(startup bool) {
+	reapContainers(startup)
+	reapNetworks(startup)
+	reapTempDirs()
+}
+
+func reapContainers(startup bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "docker", "ps", "-a",
+		"--filter", "label="+labelExecution,
+		"--format", `{{.Names}}	{{.Label "`+labelInstance+`"}}	{{.Label "`+labelExecution+`"}}	{{.Label "`+labelDeadline+`"}}`,
+	).Output()
+	if err != nil {
+		log.Printf("Reaper: failed to list sandbox containers: %v", err)
+		return
+	}
+
+	now := time.Now()
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		name, instance, executionID := fields[0], fields[1], fields[2]
+		deadline, err := strconv.ParseInt(fields[3], 10, 64)
+		stale := err != nil || now.After(time.Unix(deadline, 0).Add(reapGrace))
+		orphaned := startup && instance == instanceID && !isRunning(executionID)
+		if !stale && !orphaned {
+			continue
+		}
+
+		if err := exec.CommandContext(ctx, "docker", "rm", "-f", name).Run(); err != nil {
+			log.Printf("Reaper: failed to remove container %s: %v", name, err)
+			continue
+		}
+		reapedContainers.Add(1)
+		log.Printf("Reaper: removed container %s (instance %s)", name, instance)
+	}
+}
+
+// reapNetworks removes the per-execution egress networks of finished runs,
+// after reapContainers has removed their sandboxes.
+func reapNetworks(startup bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "docker", "network", "ls",
+		"--filter", "label="+labelExecution,
+		"--format", `{{.Name}}	{{.Label "`+labelInstance+`"}}	{{.Label "`+labelExecution+`"}}	{{.Label "`+labelDeadline+`"}}`,
+	).Output()
+	if err != nil {
+		log.Printf("Reaper: failed to list sandbox networks: %v", err)
+		return
+	}
+
+	now := time.Now()
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		name, instance, executionID := fields[0], fields[1], fields[2]
+		deadline, err := strconv.ParseInt(fields[3], 10, 64)
+		stale := err != nil || now.After(time.Unix(deadline, 0).Add(reapGrace))
+		orphaned := startup && instance == instanceID && !isRunning(executionID)
+		if !stale && !orphaned {
+			continue
+		}
+
+		if err := removeNetwork(name); err != nil {
+			log.Printf("Reaper: %v", err)
+			continue
+		}
+		reapedNetworks.Add(1)
+		log.Printf("Reaper: removed network %s (instance %s)", name, instance)
+	}
+}
+
+// tempDirMaxAge is how old each kind of temp dir may get before it can no
+// longer belong to a live execution or session.
+var tempDirMaxAge = map[string]time.Duration{
+	"codeexec-*": maxRunTimeout,
+	"session-*":  maxSessionLifetime,
+}
+
+func reapTempDirs() {
+	for pattern, maxAge := range tempDirMaxAge {
+		reapTempDirsMatching(pattern, maxAge)
+	}
+}
+
+func reapTempDirsMatching(pattern string, maxAge time.Duration) {
+	dirs, err := filepath.Glob(filepath.Join(execRoot, pattern))
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-(maxAge + reapGrace))
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Reaper: failed to remove temp dir %s: %v", dir, err)
+			continue
+		}
+		reapedDirs.Add(1)
+		log.Printf("Reaper: removed temp dir %s", dir)
+	}
+}
@@ -0,0 +1,87 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrExecutionNotFound = errors.New("execution not found")
+	ErrNotOwner          = errors.New("execution belongs to another user")
+	errDuplicateID       = errors.New("execution ID already in use")
+)
+
+// execution tracks a running container so it can be cancelled.
+type execution struct {
+	id        string
+	owner     string
+	container string
+	cancel    context.CancelFunc
+
+	mu        sync.Mutex
+	cancelled bool
+}
+
+func (e *execution) isCancelled() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cancelled
+}
+
+var (
+	runningMu sync.Mutex
+	running   = map[string]*execution{}
+)
+
+func register(id, owner string, cancel context.CancelFunc) (*execution, error) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	if _, exists := running[id]; exists {
+		return nil, errDuplicateID
+	}
+	exe := &execution{
+		id:        id,
+		owner:     owner,
+		container: "codeexec-" + id,
+		cancel:    cancel,
+	}
+	running[id] = exe
+	return exe, nil
+}
+
+func unregister(id string) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	delete(running, id)
+}
+
+func isRunning(id string) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	_, ok := running[id]
+	return ok
+}
+
+// Cancel kills the container of a running execution. Only the owner of the
+// execution may cancel it unless admin is set.
+func Cancel(id, requester string, admin bool) error {
+	runningMu.Lock()
+	exe, ok := running[id]
+	runningMu.Unlock()
+	if !ok {
+		return ErrExecutionNotFound
+	}
+	if !admin && exe.owner != requester {
+		return ErrNotOwner
+	}
+
+	exe.mu.Lock()
+	exe.cancelled = true
+	exe.mu.Unlock()
+
+	killContainer(exe.container)
+	exe.cancel()
+	return nil
+}
@@ -0,0 +1,275 @@
+package sandbox
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	replIdleTimeout = 15 * time.Minute
+	replMaxLifetime = 2 * time.Hour
+	// replMaxResponse bounds one response line from a driver
+	replMaxResponse = 4 << 20
+	replDriverFile  = ".codeexec-repl"
+	// replRedirect runs before a REPL tool's command. It moves the protocol
+	// to fds 3 (replies) and 4 (requests) and sends the driver's own stdout
+	// and stderr to a file, which drivers read back as a snippet's output.
+	replRedirect = "exec 3>&1 4<&0 </dev/null >>/tmp/.codeexec-repl-output 2>&1; "
+)
+
+var (
+	ErrNoRepl       = errors.New("no REPL configured for this language")
+	ErrReplNotFound = errors.New("REPL not found")
+	ErrNotReplOwner = errors.New("REPL belongs to another user")
+)
+
+// Drivers translate between the JSON-lines protocol and a language's
+// interpreter. A language's Repl tool names one in Driver; it is written to
+// /app/.codeexec-repl before the tool's command starts. Drivers speak the
+// protocol on fds 3 and 4 only; see replRedirect.
+//
+//go:embed repl/python.py repl/node.js repl/yaegi.go
+var replDriverFiles embed.FS
+
+var replDrivers = map[string]string{
+	"python": "repl/python.py",
+	"node":   "repl/node.js",
+	"yaegi":  "repl/yaegi.go",
+}
+
+// ReplResult is the outcome of one snippet. Result is the printed value of
+// a trailing expression, Error the exception or compile error, if any.
+// CPUTimeMs is what the REPL's container used since the previous snippet.
+type ReplResult struct {
+	Status    string  `json:"status"`
+	Output    string  `json:"output"`
+	Result    *string `json:"result,omitempty"`
+	Error     *string `json:"error,omitempty"`
+	CPUTimeMs int64   `json:"cpuTimeMs"`
+}
+
+// Repl is an interpreter kept alive in a session between snippets, so that
+// variables and imports persist. Reset replaces the container, losing all
+// state; an idle or expired session closes the REPL for good.
+type Repl struct {
+	ID       string
+	Owner    string
+	Language string
+
+	mu      sync.Mutex
+	lang    Language
+	session *Session
+	reader  *bufio.Reader
+}
+
+var (
+	replsMu sync.Mutex
+	repls   = map[string]*Repl{}
+)
+
+// StartRepl starts a REPL in language for owner. REPLs count towards the
+// per-user session cap.
+func StartRepl(owner, language string) (*Repl, error) {
+	lang, ok := lookupLanguage(language)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+	if lang.Repl == nil {
+		return nil, ErrNoRepl
+	}
+
+	r := &Repl{ID: uuid.New().String(), Owner: owner, Language: lang.Name, lang: lang}
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+	replsMu.Lock()
+	repls[r.ID] = r
+	replsMu.Unlock()
+	return r, nil
+}
+
+// CheckReplLanguage returns the error StartRepl would give for language if
+// it has no REPL, without starting one.
+func CheckReplLanguage(language string) error {
+	lang, ok := lookupLanguage(language)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+	if lang.Repl == nil {
+		return ErrNoRepl
+	}
+	return nil
+}
+
+// FindRepl returns the REPL id. Only its owner or an admin may use it.
+func FindRepl(id, requester string, admin bool) (*Repl, error) {
+	replsMu.Lock()
+	r, ok := repls[id]
+	replsMu.Unlock()
+	if !ok {
+		return nil, ErrReplNotFound
+	}
+	if r.Owner != requester && !admin {
+		return nil, ErrNotReplOwner
+	}
+	return r, nil
+}
+
+// start launches a fresh session; the caller holds r.mu or owns r.
+func (r *Repl) start() error {
+	path, ok := replDrivers[r.lang.Repl.Driver]
+	if !ok {
+		return fmt.Errorf("unknown REPL driver %q", r.lang.Repl.Driver)
+	}
+	driver, err := replDriverFiles.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	s, err := startSession(r.Owner, sessionSpec{
+		kind:        "repl",
+		image:       r.lang.Repl.Image,
+		runtime:     r.lang.runtime(),
+		command:     replRedirect + r.lang.Repl.Command,
+		files:       map[string]string{replDriverFile: string(driver)},
+		idleTimeout: replIdleTimeout,
+		maxLifetime: replMaxLifetime,
+		metered:     true,
+	})
+	if err != nil {
+		return err
+	}
+	r.session = s
+	r.reader = bufio.NewReaderSize(s.Stdout(), replMaxResponse)
+
+	go func() {
+		<-s.Done()
+		r.mu.Lock()
+		ended := r.session == s
+		r.mu.Unlock()
+		if ended {
+			replsMu.Lock()
+			delete(repls, r.ID)
+			replsMu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// Eval runs one snippet. A snippet that runs past the execution timeout
+// resets the REPL and reports StatusTimeout; an unreadable reply resets it
+// too. The CPU time of a session closed that way is charged through
+// ChargeSessionCPU.
+func (r *Repl) Eval(code string) (*ReplResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.session
+	if s == nil {
+		return nil, ErrReplNotFound
+	}
+	select {
+	case <-s.Done():
+		return nil, ErrReplNotFound
+	default:
+	}
+	s.Touch()
+
+	req, _ := json.Marshal(map[string]string{"code": code})
+	if _, err := s.Stdin().Write(append(req, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to send snippet: %w", err)
+	}
+
+	type reply struct {
+		line []byte
+		err  error
+	}
+	// The goroutine outlives a timeout, after which restart replaces
+	// r.reader, so it reads from the reader of this session
+	reader := r.reader
+	replies := make(chan reply, 1)
+	go func() {
+		line, err := reader.ReadSlice('\n')
+		replies <- reply{append([]byte(nil), line...), err}
+	}()
+
+	timer := time.NewTimer(executionTimeout)
+	defer timer.Stop()
+	select {
+	case rep := <-replies:
+		s.Touch()
+		if rep.err != nil {
+			s.Close()
+			return nil, fmt.Errorf("REPL ended unexpectedly: %w", rep.err)
+		}
+		var res ReplResult
+		if err := json.Unmarshal(rep.line, &res); err != nil {
+			// Later replies can no longer be matched to their snippets
+			log.Printf("Invalid response from REPL %s: %v", r.ID, err)
+			if err := r.restart(); err != nil {
+				return nil, err
+			}
+			msg := "the REPL sent an invalid response and was reset"
+			return &ReplResult{Status: StatusError, Error: &msg}, nil
+		}
+		res.Status = StatusSuccess
+		res.CPUTimeMs = s.UnchargedCPU().Milliseconds()
+		if res.Error != nil {
+			res.Status = StatusError
+		}
+		return &res, nil
+	case <-timer.C:
+		// The interpreter is stuck; only a fresh container recovers it
+		if err := r.restart(); err != nil {
+			return nil, err
+		}
+		msg := "snippet timed out; the REPL was reset"
+		return &ReplResult{Status: StatusTimeout, Error: &msg}, nil
+	}
+}
+
+// Reset replaces the interpreter with a fresh one, discarding all state.
+func (r *Repl) Reset() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.restart()
+}
+
+// restart closes the current session and starts a new one; the caller holds
+// r.mu. The old session is swapped out first so that closing it does not
+// remove the REPL.
+func (r *Repl) restart() error {
+	old := r.session
+	r.session = nil
+	if old != nil {
+		old.Close()
+	}
+	if err := r.start(); err != nil {
+		replsMu.Lock()
+		delete(repls, r.ID)
+		replsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close stops the REPL.
+func (r *Repl) Close() {
+	r.mu.Lock()
+	s := r.session
+	r.mu.Unlock()
+	if s != nil {
+		s.Close()
+	}
+	replsMu.Lock()
+	delete(repls, r.ID)
+	replsMu.Unlock()
+}
@@ -0,0 +1,63 @@
+// REPL driver: reads {"code": ...} lines on fd 4 and answers each with one
+// {"output", "result", "error"} line on fd 3. Stdout and stderr go to a file
+// that is read back after each snippet, so nothing the snippet or its child
+// processes print can corrupt a reply. State lives in a single vm context, so
+// top-level declarations persist between snippets.
+const fs = require("fs");
+const readline = require("readline");
+const util = require("util");
+const vm = require("vm");
+
+const MAX_OUTPUT = 1 << 20;
+
+const context = vm.createContext({
+  console,
+  require,
+  Buffer,
+  setTimeout, clearTimeout, setInterval, clearInterval, setImmediate, clearImmediate,
+  queueMicrotask, URL, URLSearchParams, TextEncoder, TextDecoder,
+});
+
+// Writes to a file are synchronous, so everything printed so far is in it
+function readOutput() {
+  const fd = fs.openSync("/proc/self/fd/1", "r");
+  try {
+    const buf = Buffer.alloc(MAX_OUTPUT);
+    return buf.toString("utf8", 0, fs.readSync(fd, buf, 0, MAX_OUTPUT, 0));
+  } finally {
+    fs.closeSync(fd);
+  }
+}
+
+async function run(code) {
+  fs.ftruncateSync(1, 0);
+  let result = null;
+  let error = null;
+  try {
+    let value = vm.runInContext(code, context, { filename: "<cell>" });
+    if (value && typeof value.then === "function") {
+      value = await value;
+    }
+    if (value !== undefined) {
+      context._ = value;
+      result = util.inspect(value);
+    }
+  } catch (err) {
+    error = err && err.stack ? err.stack : String(err);
+  }
+  return { output: readOutput(), result, error };
+}
+
+// Snippets run one at a time, in order
+let queue = Promise.resolve();
+readline.createInterface({ input: fs.createReadStream(null, { fd: 4 }) }).on("line", (line) => {
+  let code;
+  try {
+    code = JSON.parse(line).code;
+  } catch {
+    return;
+  }
+  queue = queue.then(async () => {
+    fs.writeSync(3, JSON.stringify(await run(code)) + "\n");
+  });
+});
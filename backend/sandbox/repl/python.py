@@ -0,0 +1,63 @@
+# REPL driver: reads {"code": ...} lines on fd 4 and answers each with one
+# {"output", "result", "error"} line on fd 3. Stdout and stderr go to a file
+# that is read back after each snippet, so nothing the snippet or its child
+# processes print can corrupt a reply. State lives in a single namespace.
+import ast
+import json
+import os
+import sys
+import traceback
+
+MAX_OUTPUT = 1 << 20
+
+namespace = {"__name__": "__main__"}
+for fd in (3, 4):
+    os.set_inheritable(fd, False)
+protocol_in = os.fdopen(4, "r")
+protocol_out = os.fdopen(3, "w")
+
+
+def read_output():
+    sys.stdout.flush()
+    sys.stderr.flush()
+    with open("/proc/self/fd/1", "rb") as f:
+        return f.read(MAX_OUTPUT).decode("utf-8", "replace")
+
+
+def run(code):
+    result = error = None
+    os.ftruncate(1, 0)
+    try:
+        tree = ast.parse(code, "<cell>", "exec")
+        # Like the interactive interpreter, show the value of a final
+        # expression
+        last = None
+        if tree.body and isinstance(tree.body[-1], ast.Expr):
+            last = ast.Expression(tree.body.pop().value)
+        exec(compile(tree, "<cell>", "exec"), namespace)
+        if last is not None:
+            value = eval(compile(last, "<cell>", "eval"), namespace)
+            if value is not None:
+                namespace["_"] = value
+                result = repr(value)
+    except SystemExit:
+        pass
+    except SyntaxError as e:
+        error = "".join(traceback.format_exception_only(type(e), e))
+    except BaseException:
+        # Drop the driver's own frame from the traceback
+        etype, value, tb = sys.exc_info()
+        error = "".join(traceback.format_exception(etype, value, tb.tb_next))
+    # Undo any reassignment by the snippet
+    sys.stdin, sys.stdout, sys.stderr = sys.__stdin__, sys.__stdout__, sys.__stderr__
+    return read_output(), result, error
+
+
+for line in protocol_in:
+    try:
+        code = json.loads(line)["code"]
+    except (ValueError, KeyError, TypeError):
+        continue
+    output, result, error = run(code)
+    protocol_out.write(json.dumps({"output": output, "result": result, "error": error}) + "\n")
+    protocol_out.flush()
@@ -0,0 +1,89 @@
+//go:build ignore
+
+// REPL driver: reads {"code": ...} lines on fd 4 and answers each with one
+// {"output", "result", "error"} line on fd 3. Stdout and stderr go to a file
+// that is read back after each snippet, so nothing the snippet or its child
+// processes print can corrupt a reply. State lives in a single yaegi
+// interpreter. The ignore tag keeps it out of the backend build; the image
+// compiles it by file name.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"syscall"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+const maxOutput = 1 << 20
+
+type response struct {
+	Output string  `json:"output"`
+	Result *string `json:"result"`
+	Error  *string `json:"error"`
+}
+
+func main() {
+	i := interp.New(interp.Options{Stdin: bytes.NewReader(nil), Stdout: os.Stdout, Stderr: os.Stderr})
+	if err := i.Use(stdlib.Symbols); err != nil {
+		panic(err)
+	}
+
+	syscall.CloseOnExec(3)
+	syscall.CloseOnExec(4)
+	enc := json.NewEncoder(os.NewFile(3, "protocol-out"))
+	scanner := bufio.NewScanner(os.NewFile(4, "protocol-in"))
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var req struct {
+			Code string `json:"code"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &req) != nil {
+			continue
+		}
+		os.Stdout.Truncate(0)
+		resp := eval(i, req.Code)
+		resp.Output = readOutput()
+		enc.Encode(resp)
+	}
+}
+
+// readOutput returns what the snippet printed, up to maxOutput bytes.
+func readOutput() string {
+	f, err := os.Open("/proc/self/fd/1")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	out, _ := io.ReadAll(io.LimitReader(f, maxOutput))
+	return string(out)
+}
+
+func eval(i *interp.Interpreter, code string) (resp response) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg := fmt.Sprint("panic: ", r)
+			resp.Error = &msg
+		}
+	}()
+	v, err := i.Eval(code)
+	if err != nil {
+		msg := err.Error()
+		resp.Error = &msg
+		return resp
+	}
+	// Declarations and imports evaluate to functions or empty interface
+	// pointers, which are not worth showing
+	if v.IsValid() && v.Kind() != reflect.Func && !(v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Interface) {
+		result := fmt.Sprintf("%#v", v.Interface())
+		resp.Result = &result
+	}
+	return resp
+}
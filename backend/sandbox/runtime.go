@@ -0,0 +1,87 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	runtimeMu      sync.RWMutex
+	defaultRuntime string // Reported for containers without an explicit runtime
+)
+
+// runtime is the OCI runtime for the language's containers: its own Runtime,
+// else SANDBOX_RUNTIME, else "" for the daemon's default.
+func (l *Language) runtime() string {
+	if l.Runtime != "" {
+		return l.Runtime
+	}
+	return os.Getenv("SANDBOX_RUNTIME")
+}
+
+// runtimeName is the runtime reported in results for the language's runs.
+func runtimeName(lang Language) string {
+	if r := lang.runtime(); r != "" {
+		return r
+	}
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	return defaultRuntime
+}
+
+// VerifyRuntimes checks that every OCI runtime named by SANDBOX_RUNTIME or a
+// language entry is registered with the Docker daemon, so that untrusted
+// code never silently falls back to a weaker runtime. It also records the
+// daemon's default runtime for results.
+func VerifyRuntimes() error {
+	needed := map[string][]string{}
+	if r := os.Getenv("SANDBOX_RUNTIME"); r != "" {
+		needed[r] = append(needed[r], "SANDBOX_RUNTIME")
+	}
+	for _, lang := range allLanguages() {
+		if lang.Runtime != "" {
+			needed[lang.Runtime] = append(needed[lang.Runtime], lang.Name)
+		}
+	}
+
+	var info struct {
+		DefaultRuntime string
+		Runtimes       map[string]json.RawMessage
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "docker", "info", "--format", "{{json .}}").Output()
+	if err == nil {
+		err = json.Unmarshal(output, &info)
+	}
+	if err != nil {
+		if len(needed) == 0 {
+			// Nothing to enforce; runs will report no runtime
+			return nil
+		}
+		return fmt.Errorf("failed to query docker runtimes: %w", err)
+	}
+
+	runtimeMu.Lock()
+	defaultRuntime = info.DefaultRuntime
+	runtimeMu.Unlock()
+
+	var missing []string
+	for name, users := range needed {
+		if _, ok := info.Runtimes[name]; !ok {
+			missing = append(missing, fmt.Sprintf("%s (used by %s)", name, strings.Join(users, ", ")))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("OCI runtimes not registered with the docker daemon: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
@@ -1,113 +1,541 @@
 package sandbox
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+// Execution statuses reported back to clients.
+const (
+	StatusSuccess   = "SUCCESS"
+	StatusError     = "ERROR"
+	StatusTimeout   = "TIMEOUT"
+	StatusCancelled = "CANCELLED"
+)
+
+// Execution modes.
+const (
+	ModeRun       = "run"
+	ModeTest      = "test"
+	ModeBenchmark = "benchmark"
+	ModeProfile   = "profile"
+)
+
+// Limits applied to every sandbox container.
+const (
+	executionTimeout = 20 * time.Second
+	testTimeout      = 60 * time.Second
+	// maxRunTimeout is the longest any single container run may take; temp
+	// dirs older than that are stale.
+	maxRunTimeout = 2 * time.Minute
+	memoryLimitKB = 1 << 20 // 1g
+	cpuLimit      = 2.0
+	// pidsLimit stops fork bombs while leaving room for the threads of JVM
+	// and .NET compilers.
+	pidsLimit = 512
+)
+
+// Request describes a single code execution.
+type Request struct {
+	ID       string // Generated when empty
+	Owner    string // User email, or "ip:<addr>" for anonymous clients
+	Language string
+	Code     string
+	Input    string
+	Mode     string            // ModeRun (default) or ModeTest
+	Files    map[string]string // Extra files next to the main source file
+	Data     map[string]string // Uploaded data files, also in the working directory
+	Coverage bool              // Collect line coverage in test mode
+	Runs     int               // Timed runs in benchmark mode
+	Warmup   int               // Untimed runs before them, at least one
+	Baseline string            // Code benchmarked for comparison
+	Args     []string          // Program arguments, run and benchmark modes only
+	Env      map[string]string // Program environment, never seen by the compiler
+	Network  bool              // Reach allowlisted hosts through the egress proxy
+	// Output receives the program's output as it is produced, in every mode
+	// but benchmark. Its write errors are ignored.
+	Output io.Writer `json:"-"`
+}
+
+// Result is the outcome of an execution as returned to API clients.
+type Result struct {
+	ID        string    `json:"executionId"`
+	Status    string    `json:"status"`
+	Output    string    `json:"output"`
+	Error     string    `json:"error,omitempty"`
+	ExitCode  int       `json:"exitCode"`
+	StartedAt time.Time `json:"startedAt"`
+	Usage     *Usage    `json:"usage,omitempty"`
+	// Diagnostics holds compile and syntax errors parsed from failed runs.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// Tests holds per-test results in test mode.
+	Tests *TestReport `json:"tests,omitempty"`
+	// Coverage holds line coverage of test runs that asked for it.
+	Coverage *CoverageReport `json:"coverage,omitempty"`
+	// Benchmark and Comparison hold the timings of benchmark runs.
+	Benchmark  *BenchmarkStats `json:"benchmark,omitempty"`
+	Comparison *Comparison     `json:"comparison,omitempty"`
+	// Profile holds the top functions of profiled runs.
+	Profile *Profile `json:"profile,omitempty"`
+	// Runtime is the OCI runtime the code ran under, or "wasm".
+	Runtime string `json:"runtime,omitempty"`
+	// Egress logs the requests of runs with network access.
+	Egress *EgressReport `json:"egress,omitempty"`
+}
+
+var (
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	ErrNoTestRunner        = errors.New("no test runner configured for this language")
+	errCancelled           = errors.New("execution cancelled")
 )
 
 func ExecuteCode(language, code, input string) (string, error) {
-	// 1) Pick image & args for STDIN mode
-
-	switch language {
-	case "python":
-		return executeWithVolume(language, code, input)
-	case "go":
-		return executeWithVolume(language, code, input)
-	case "cpp":
-		return executeWithVolume(language, code, input)
-	case "java":
-		return executeWithVolume(language, code, input)
-	case "javascript":
-		return executeWithVolume(language, code, input)
+	res := Execute(Request{Language: language, Code: code, Input: input})
+	if res.Error != "" {
+		return res.Output, errors.New(res.Error)
+	}
+	return res.Output, nil
+}
+
+// Execute runs the request in a fresh container. While it runs, the execution
+// can be stopped through Cancel using the request ID.
+func Execute(req Request) *Result {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	res := &Result{ID: req.ID}
+
+	lang, ok := lookupLanguage(req.Language)
+	if !ok {
+		res.Status = StatusError
+		res.Error = fmt.Sprintf("unsupported language: %s", req.Language)
+		return res
+	}
+	if reason := unavailableReason(lang.Name); reason != "" {
+		res.Status = StatusError
+		res.Error = fmt.Sprintf("language %s is currently unavailable: %s", lang.Name, reason)
+		return res
+	}
+
+	if err := validateFiles(req.Files, lang.Filename); err != nil {
+		res.Status = StatusError
+		res.Error = err.Error()
+		return res
+	}
+	if err := validateDataFiles(req.Data, req.Files, lang.Filename); err != nil {
+		res.Status = StatusError
+		res.Error = err.Error()
+		return res
+	}
+	if len(req.Input) > MaxInputSize {
+		res.Status = StatusError
+		res.Error = fmt.Sprintf("input exceeds %d bytes", MaxInputSize)
+		return res
+	}
+	if err := ValidateArgsEnv(req.Args, req.Env); err != nil {
+		res.Status = StatusError
+		res.Error = err.Error()
+		return res
+	}
+	if (len(req.Args) > 0 || len(req.Env) > 0) && req.Mode != "" && req.Mode != ModeRun && req.Mode != ModeBenchmark {
+		res.Status = StatusError
+		res.Error = fmt.Sprintf("%s: only run and benchmark modes take args and env", ErrInvalidArgs)
+		return res
+	}
+	files := map[string]string{lang.Filename: req.Code}
+	for name, content := range req.Files {
+		files[name] = content
+	}
+	for name, content := range req.Data {
+		files[name] = content
+	}
+
+	spec := containerSpec{
+		image:   lang.Image,
+		runtime: lang.runtime(),
+		files:   files,
+		command: lang.command(req.Args, req.Env),
+		input:   req.Input,
+	}
+	if req.Output != nil {
+		spec.stream = &streamWriter{w: req.Output}
+	}
+	if req.Network {
+		if !EgressEnabled() {
+			res.Status = StatusError
+			res.Error = ErrEgressDisabled.Error()
+			return res
+		}
+		network, err := openEgressNetwork(req.ID)
+		if err != nil {
+			log.Printf("Execution %s: %v", req.ID, err)
+			res.Status = StatusError
+			res.Error = "failed to set up network access"
+			return res
+		}
+		session := egressProxy.Open(req.ID)
+		defer func() {
+			res.Egress = egressProxy.Close(session)
+			if err := removeNetwork(network); err != nil {
+				log.Printf("Execution %s: %v", req.ID, err)
+			}
+		}()
+		spec.network = network
+		spec.env = proxyEnv(session)
+	}
+
+	timeout := executionTimeout
+	switch req.Mode {
+	case "", ModeRun:
+	case ModeTest:
+		if lang.TestRunner == nil {
+			res.Status = StatusError
+			res.Error = ErrNoTestRunner.Error()
+			return res
+		}
+		spec.image = lang.TestRunner.Image
+		spec.command = lang.TestRunner.Command
+		spec.collect = []string{lang.TestRunner.Report}
+		timeout = testTimeout
+		if req.Coverage {
+			if lang.Coverage == nil {
+				res.Status = StatusError
+				res.Error = ErrNoCoverage.Error()
+				return res
+			}
+			spec.image = lang.Coverage.Image
+			spec.command = lang.Coverage.Command
+			spec.collect = append(spec.collect, lang.Coverage.Report)
+		}
+	case ModeBenchmark:
+		spec.stream = nil
+		res = benchmark(req, lang, spec)
+		return res
+	case ModeProfile:
+		if lang.Profiler == nil {
+			res.Status = StatusError
+			res.Error = ErrNoProfiler.Error()
+			return res
+		}
+		spec.image = lang.Profiler.Image
+		spec.command = lang.Profiler.Command
+		spec.collect = []string{lang.Profiler.Report, lang.Profiler.artifact()}
+		timeout = profileTimeout
 	default:
-		return "", fmt.Errorf("unsupported language: %s", language)
+		res.Status = StatusError
+		res.Error = fmt.Sprintf("unsupported mode: %s", req.Mode)
+		return res
+	}
+
+	res.StartedAt = time.Now()
+	var out *containerResult
+	var err error
+	if (req.Mode == "" || req.Mode == ModeRun) && !req.Network && wasmBackend(lang) {
+		res.Runtime = BackendWasm
+		out, err = runWasmOnce(req.ID, req.Owner, timeout, lang.Wasm, files, req)
+	} else {
+		res.Runtime = runtimeName(lang)
+		out, err = runOnce(req.ID, req.Owner, timeout, spec)
+	}
+	res.Output = out.output
+	res.Usage = out.usage
+	res.Status, res.ExitCode = statusOf(err)
+	if err != nil {
+		res.Error = err.Error()
+		if res.Status == StatusTimeout {
+			res.Error = "execution timed out"
+		}
+	}
+	if res.Status == StatusError && res.ExitCode != 0 {
+		res.Diagnostics = compileDiagnostics(lang, res.Output)
+	}
+	if req.Mode == ModeTest {
+		report, ok := out.files[lang.TestRunner.Report]
+		if parse, known := testReportParsers[lang.TestRunner.Parser]; ok && known {
+			tests, err := parse(report)
+			if err != nil {
+				log.Printf("Failed to parse %s test report of execution %s: %v", lang.Name, req.ID, err)
+			}
+			res.Tests = tests
+		}
+	}
+	if req.Mode == ModeTest && req.Coverage {
+		report, ok := out.files[lang.Coverage.Report]
+		if parse, known := coverageParsers[lang.Coverage.Parser]; ok && known {
+			coverage, err := parse(report)
+			if err != nil {
+				log.Printf("Failed to parse %s coverage report of execution %s: %v", lang.Name, req.ID, err)
+			}
+			if coverage != nil {
+				coverage.clamp(files)
+			}
+			res.Coverage = coverage
+		}
 	}
+	if req.Mode == ModeProfile {
+		res.Profile = collectProfile(lang, req.ID, out.files)
+	}
+	return res
 }
 
-func executeWithVolume(language, code, input string) (string, error) {
-	// 1) Get the host project path from environment variable
-	hostProjectPath := os.Getenv("HOST_PROJECT_PATH")
-	if hostProjectPath == "" {
-		return "", fmt.Errorf("HOST_PROJECT_PATH environment variable not set")
+// statusOf maps a container or WebAssembly run error to an execution status
+// and exit code.
+func statusOf(err error) (string, int) {
+	var exitErr interface{ ExitCode() int }
+	switch {
+	case err == nil:
+		return StatusSuccess, 0
+	case errors.Is(err, errCancelled):
+		return StatusCancelled, 0
+	case errors.Is(err, context.DeadlineExceeded):
+		return StatusTimeout, 0
+	case errors.As(err, &exitErr):
+		return StatusError, exitErr.ExitCode()
+	default:
+		return StatusError, 0
+	}
+}
+
+// containerSpec describes one sandboxed container run.
+type containerSpec struct {
+	image   string
+	runtime string            // OCI runtime, the daemon's default when empty
+	network string            // Docker network, none when empty
+	env     []string          // Container environment as NAME=value
+	files   map[string]string // Written into /app before the run
+	command string            // Shell command run inside /app
+	input   string
+	collect []string  // Files read back from /app after the run, if present
+	stream  io.Writer // Also receives the output as it is produced
+}
+
+// containerResult is what a container run produced. It is never nil, even
+// when the run fails, so partial output can still be shown.
+type containerResult struct {
+	output string
+	usage  *Usage
+	files  map[string][]byte
+}
+
+// runOnce registers a new execution and runs a single container for it,
+// bounded by timeout.
+func runOnce(id, owner string, timeout time.Duration, spec containerSpec) (*containerResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	exe, err := register(id, owner, cancel)
+	if err != nil {
+		return &containerResult{}, err
+	}
+	defer unregister(id)
+
+	deadline, _ := ctx.Deadline()
+	return executeWithVolume(ctx, exe, deadline, spec)
+}
+
+func executeWithVolume(ctx context.Context, exe *execution, deadline time.Time, spec containerSpec) (*containerResult, error) {
+	res := &containerResult{}
+
+	// 1) Make sure the temp dir can be mounted from the docker host
+	if os.Getenv("HOST_PROJECT_PATH") == "" {
+		return res, fmt.Errorf("HOST_PROJECT_PATH environment variable not set")
 	}
 
 	// 2) Make a temp dir inside the container's /code-exec mount
-	tmpDir, err := os.MkdirTemp("/code-exec", "codeexec-*")
+	tmpDir, err := os.MkdirTemp(execRoot, "codeexec-*")
 	if err != nil {
-		return "", fmt.Errorf("failed to make temp dir: %w", err)
+		return res, fmt.Errorf("failed to make temp dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
-	var filename string
-	var image string
-	var cmdArgs []string
-
-	switch language {
-	case "python":
-		filename = "main.py"
-		image = "python:3.10-alpine"
-		cmdArgs = []string{"python", filename}
-	case "go":
-		filename = "main.go"
-		image = "golang:1.20-alpine"
-		cmdArgs = []string{"go", "run", filename}
-	case "cpp":
-		filename = "main.cpp"
-		image = "cpp-compiler-alpine"
-		// Compile and then run
-		cmdArgs = []string{"sh", "-c", "g++ -o main main.cpp && ./main"}
-	case "java":
-		filename = "Main.java"
-		image = "openjdk:17-alpine"
-		cmdArgs = []string{"sh", "-c", "javac Main.java && java Main"}
-	case "javascript":
-		filename = "main.js"
-		image = "node:alpine"
-		cmdArgs = []string{"node", filename}
-	default:
-		return "", fmt.Errorf("unsupported language: %s", language)
+	if err := writeFiles(tmpDir, spec.files); err != nil {
+		return res, err
+	}
+
+	hostDir, err := hostPath(tmpDir)
+	if err != nil {
+		return res, err
+	}
+
+	fmt.Println("Container Temp Dir:", tmpDir)
+	fmt.Println("Absolute Host-relative Volume Path:", hostDir)
+
+	args := sandboxRunArgs(exe.container, exe.id, deadline, hostDir, spec.runtime, spec.network)
+	for _, env := range spec.env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, spec.image)
+	args = append(args, timedCommand(spec.command)...)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdin = strings.NewReader(spec.input)
+	var output bytes.Buffer
+	var w io.Writer = &output
+	if spec.stream != nil {
+		w = io.MultiWriter(&output, spec.stream)
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	start := time.Now()
+	meter := meterCPU(exe.container, start)
+	err = cmd.Run()
+	end := time.Now()
+	res.output = output.String()
+	res.usage = &Usage{
+		WallTimeMs: end.Sub(start).Milliseconds(),
+		CPUTimeMs:  meter.finish(end, cpuLimit).Milliseconds(),
+	}
+	res.usage.PeakMemoryKB, _ = readPeakMemory(tmpDir)
+	res.files = collectFiles(tmpDir, spec.collect)
+
+	if exe.isCancelled() {
+		return res, errCancelled
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		// Killing the docker CLI does not stop the container itself
+		killContainer(exe.container)
+		return res, fmt.Errorf("execution timed out: %w", ctx.Err())
+	}
+	if err != nil {
+		return res, fmt.Errorf("execution error: %w", err)
 	}
+	return res, nil
+}
+
+// streamWriter forwards output to a client until the first failed write,
+// so that a client going away never disturbs the run.
+type streamWriter struct {
+	w      io.Writer
+	failed bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if !s.failed {
+		if _, err := s.w.Write(p); err != nil {
+			s.failed = true
+		}
+	}
+	return len(p), nil
+}
 
-	codePath := filepath.Join(tmpDir, filename)
-	if err := os.WriteFile(codePath, []byte(code), 0o644); err != nil {
-		return "", fmt.Errorf("failed to write code file: %w", err)
+// writeFiles writes files (keyed by relative path) into dir.
+func writeFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("failed to write code file: %w", err)
+		}
 	}
+	return nil
+}
 
-	hostDir := filepath.Join(hostProjectPath, "code-exec", filepath.Base(tmpDir))
+// hostPath translates a directory under execRoot into the path the docker
+// daemon sees, since the backend itself runs inside a container.
+func hostPath(dir string) (string, error) {
+	hostProjectPath := os.Getenv("HOST_PROJECT_PATH")
+	if hostProjectPath == "" {
+		return "", fmt.Errorf("HOST_PROJECT_PATH environment variable not set")
+	}
+	hostDir := filepath.Join(hostProjectPath, "code-exec", filepath.Base(dir))
 	hostDir = strings.Replace(hostDir, `\`, `/`, -1)
 	if len(hostDir) > 1 && hostDir[1] == ':' {
 		hostDir = "/c" + hostDir[2:]
 	}
+	return hostDir, nil
+}
 
-	fmt.Println("Container Temp Dir:", tmpDir)
-	fmt.Println("Absolute Host-relative Volume Path:", hostDir)
-
+// sandboxRunArgs returns the docker run flags shared by every sandbox
+// container: no network unless one is given, resource limits, reaper labels,
+// the OCI runtime if one is set and hostDir mounted as the working
+// directory. The image and command go after them.
+func sandboxRunArgs(name, executionID string, deadline time.Time, hostDir, runtime, network string) []string {
 	args := []string{
 		"run", "--rm", "-i", // Add -i flag here
+		"--name", name,
+	}
+	if runtime != "" {
+		args = append(args, "--runtime", runtime)
+	}
+	args = append(args, containerLabels(executionID, deadline)...)
+	args = append(args,
 		"-v", fmt.Sprintf("%s:/app", hostDir),
 		"--workdir", "/app",
-		"--network=none",
-		"--memory", "1g",
-		"--cpus", "2.0",
-		image,
+		"--network", cmp.Or(network, "none"),
+		"--memory", fmt.Sprintf("%dk", memoryLimitKB),
+		"--cpus", strconv.FormatFloat(cpuLimit, 'f', -1, 64),
+		"--pids-limit", strconv.Itoa(pidsLimit),
+	)
+	return args
+}
+
+// maxCollectedFile bounds what the server reads back from a sandbox, since
+// the program can write reports and profiles of any size.
+const maxCollectedFile = 64 << 20 // 64 MiB
+
+// collectFiles reads the named files from dir, skipping missing ones, ones
+// larger than maxCollectedFile and anything that resolves outside dir.
+func collectFiles(dir string, names []string) map[string][]byte {
+	files := map[string][]byte{}
+	for _, name := range names {
+		if data, err := readSandboxFile(dir, name, maxCollectedFile); err == nil {
+			files[name] = data
+		}
 	}
-	args = append(args, cmdArgs...)
+	return files
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	cmd.Stdin = strings.NewReader(input)
-	output, err := cmd.CombinedOutput()
-	if ctx.Err() == context.DeadlineExceeded {
-		return string(output), fmt.Errorf("execution timed out")
+// readSandboxFile reads a regular file of at most limit bytes from dir, which
+// the program controlled. Symlinks are only followed while they stay inside
+// dir, including in intermediate directories, and special files such as
+// FIFOs are refused rather than opened.
+func readSandboxFile(dir, name string, limit int64) ([]byte, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	info, err := root.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
 	}
+	f, err := root.OpenFile(name, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
 	if err != nil {
-		return string(output), fmt.Errorf("execution error: %w", err)
+		return nil, err
+	}
+	defer f.Close()
+	if info, err = f.Stat(); err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > limit {
+		return nil, fmt.Errorf("%s is not a regular file of at most %d bytes", name, limit)
 	}
-	return string(output), nil
+	return io.ReadAll(io.LimitReader(f, limit))
+}
+
+// killContainer force-stops a sandbox container by name. Errors are ignored
+// because the container may already have exited and been removed.
+func killContainer(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exec.CommandContext(ctx, "docker", "kill", name).Run()
 }
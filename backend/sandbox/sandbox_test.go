@@ -0,0 +1,56 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestCollectFiles(t *testing.T) {
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "report.xml"), []byte("<ok/>"), 0o644)
+	os.Mkdir(filepath.Join(dir, "out"), 0o755)
+	os.WriteFile(filepath.Join(dir, "out", "cover.out"), []byte("mode: set"), 0o644)
+	os.Symlink(outside, filepath.Join(dir, "escape"))
+	os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "secret"))
+	os.Symlink("out", filepath.Join(dir, "inside"))
+	os.Symlink("/", filepath.Join(dir, "root"))
+	syscall.Mkfifo(filepath.Join(dir, "fifo"), 0o644)
+	big, _ := os.Create(filepath.Join(dir, "big"))
+	big.Truncate(maxCollectedFile + 1)
+	big.Close()
+
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{name: "file", file: "report.xml", want: "<ok/>"},
+		{name: "file in a directory", file: "out/cover.out", want: "mode: set"},
+		{name: "symlinked directory inside", file: "inside/cover.out", want: "mode: set"},
+		{name: "missing", file: "missing.xml"},
+		{name: "dot dot", file: "../secret"},
+		{name: "absolute path", file: filepath.Join(outside, "secret")},
+		{name: "symlink out", file: "secret"},
+		{name: "symlinked directory out", file: "escape/secret"},
+		{name: "symlink to the root", file: "root/etc/passwd"},
+		{name: "fifo", file: "fifo"},
+		{name: "too large", file: "big"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := map[string][]byte{}
+			if tt.want != "" {
+				want[tt.file] = []byte(tt.want)
+			}
+			if got := collectFiles(dir, []string{tt.file}); !reflect.DeepEqual(got, want) {
+				t.Errorf("collectFiles(%s) = %q, want %q", tt.file, got, want)
+			}
+		})
+	}
+}
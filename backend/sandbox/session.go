@@ -0,0 +1,311 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxSessionLifetime bounds every interactive session; the reaper uses it to
+// recognise stale session directories.
+const maxSessionLifetime = 2 * time.Hour
+
+// ChargeSessionCPU, when set, is called as a metered session closes with the
+// CPU time its container used since it was last charged, so that work left
+// running after the owner's last request still counts.
+var ChargeSessionCPU func(owner string, cpu time.Duration)
+
+var (
+	ErrSessionLimit    = errors.New("too many open sessions")
+	ErrSessionNotFound = errors.New("session not found")
+	ErrNotSessionOwner = errors.New("session belongs to another user")
+)
+
+// sessionsPerUser is how many sessions of one kind a user may hold open.
+var sessionsPerUser = func() int {
+	if n, err := strconv.Atoi(os.Getenv("SANDBOX_MAX_SESSIONS")); err == nil && n > 0 {
+		return n
+	}
+	return 3
+}()
+
+// sessionSpec describes a long-lived sandbox container.
+type sessionSpec struct {
+	kind        string // Groups sessions for the per-user cap, e.g. "lsp"
+	image       string
+	runtime     string // OCI runtime, the daemon's default when empty
+	command     string
+	files       map[string]string
+	idleTimeout time.Duration
+	maxLifetime time.Duration
+	metered     bool // Container CPU counts towards the owner's daily quota
+}
+
+// Session is a long-lived sandbox container driven through its stdin and
+// stdout. It has the same isolation as a single execution and only sees its
+// own directory. Sessions close themselves when idle or too old.
+type Session struct {
+	ID    string
+	Owner string
+	Kind  string
+
+	container string
+	dir       string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    io.ReadCloser
+
+	mu         sync.Mutex
+	lastActive time.Time
+
+	// Container CPU time as last measured and how much of it was charged;
+	// final once the container is gone
+	metered    bool
+	cpuMu      sync.Mutex
+	cpuUsed    time.Duration
+	cpuCharged time.Duration
+	cpuFinal   bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = map[string]*Session{}
+)
+
+// FindSession returns the open session id of the given kind. Only its owner
+// or an admin may use it.
+func FindSession(id, kind, requester string, admin bool) (*Session, error) {
+	sessionsMu.Lock()
+	s, ok := sessions[id]
+	sessionsMu.Unlock()
+	if !ok || s.Kind != kind {
+		return nil, ErrSessionNotFound
+	}
+	if s.Owner != requester && !admin {
+		return nil, ErrNotSessionOwner
+	}
+	return s, nil
+}
+
+// startSession starts the container for a new session owned by owner.
+func startSession(owner string, spec sessionSpec) (*Session, error) {
+	if spec.maxLifetime <= 0 || spec.maxLifetime > maxSessionLifetime {
+		spec.maxLifetime = maxSessionLifetime
+	}
+
+	s := &Session{
+		ID:         uuid.New().String(),
+		Owner:      owner,
+		Kind:       spec.kind,
+		lastActive: time.Now(),
+		metered:    spec.metered,
+		done:       make(chan struct{}),
+	}
+	s.container = "codesession-" + s.ID
+
+	sessionsMu.Lock()
+	count := 0
+	for _, other := range sessions {
+		if other.Owner == owner && other.Kind == spec.kind {
+			count++
+		}
+	}
+	if count >= sessionsPerUser {
+		sessionsMu.Unlock()
+		return nil, ErrSessionLimit
+	}
+	sessions[s.ID] = s
+	sessionsMu.Unlock()
+
+	if err := s.start(spec); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	go s.watch(spec.idleTimeout, time.Now().Add(spec.maxLifetime))
+	return s, nil
+}
+
+func (s *Session) start(spec sessionSpec) error {
+	dir, err := os.MkdirTemp(execRoot, "session-*")
+	if err != nil {
+		return fmt.Errorf("failed to make session dir: %w", err)
+	}
+	s.dir = dir
+	if err := writeFiles(dir, spec.files); err != nil {
+		return err
+	}
+	hostDir, err := hostPath(dir)
+	if err != nil {
+		return err
+	}
+
+	args := sandboxRunArgs(s.container, s.ID, time.Now().Add(spec.maxLifetime), hostDir, spec.runtime, "")
+	// Tools keep caches in $HOME; point it at the container's own scratch space
+	args = append(args, "-e", "HOME=/tmp", spec.image, "sh", "-c", spec.command)
+
+	s.cmd = exec.Command("docker", args...)
+	if s.stdin, err = s.cmd.StdinPipe(); err != nil {
+		return err
+	}
+	if s.stdout, err = s.cmd.StdoutPipe(); err != nil {
+		return err
+	}
+	s.cmd.Stderr = io.Discard
+	if err := s.cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start session container: %w", err)
+	}
+
+	go func() {
+		s.cmd.Wait()
+		s.Close()
+	}()
+	return nil
+}
+
+// watch closes the session once it has been idle for idleTimeout or has
+// reached its deadline.
+func (s *Session) watch(idleTimeout time.Duration, deadline time.Time) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			if s.metered {
+				s.measureCPU()
+			}
+			s.mu.Lock()
+			idle := now.Sub(s.lastActive)
+			s.mu.Unlock()
+			if (idleTimeout > 0 && idle > idleTimeout) || now.After(deadline) {
+				log.Printf("Closing %s session %s (idle %s)", s.Kind, s.ID, idle.Round(time.Second))
+				s.Close()
+				return
+			}
+		}
+	}
+}
+
+// Stdin returns the session process's standard input.
+func (s *Session) Stdin() io.Writer { return s.stdin }
+
+// Stdout returns the session process's standard output.
+func (s *Session) Stdout() io.Reader { return s.stdout }
+
+// Attachment is an extra process inside a session's container.
+type Attachment struct {
+	cmd    *exec.Cmd
+	Stdin  io.WriteCloser
+	Stdout io.ReadCloser
+}
+
+// Attach runs command inside the session's container, for example to open
+// another connection to a server listening on the container's loopback.
+func (s *Session) Attach(command string) (*Attachment, error) {
+	a := &Attachment{cmd: exec.Command("docker", "exec", "-i", s.container, "sh", "-c", command)}
+	var err error
+	if a.Stdin, err = a.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if a.Stdout, err = a.cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	a.cmd.Stderr = io.Discard
+	if err := a.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to attach to session: %w", err)
+	}
+	return a, nil
+}
+
+// Close ends the attached process.
+func (a *Attachment) Close() {
+	a.Stdin.Close()
+	if a.cmd.Process != nil {
+		a.cmd.Process.Kill()
+	}
+	a.cmd.Wait()
+}
+
+// Touch records activity, postponing the idle shutdown.
+func (s *Session) Touch() {
+	s.mu.Lock()
+	s.lastActive = time.Now()
+	s.mu.Unlock()
+}
+
+// Done is closed once the session has ended.
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+// measureCPU updates the container's CPU time. The figure is cumulative, so
+// a failed measurement is made up by the next one.
+func (s *Session) measureCPU() {
+	s.cpuMu.Lock()
+	defer s.cpuMu.Unlock()
+	if s.cpuFinal {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if cpu, err := containerCPU(ctx, s.container); err == nil && cpu > s.cpuUsed {
+		s.cpuUsed = cpu
+	}
+}
+
+// UnchargedCPU returns the CPU time the session's container used since the
+// previous call, including work left running between requests. It is zero
+// for sessions that are not metered.
+func (s *Session) UnchargedCPU() time.Duration {
+	if !s.metered {
+		return 0
+	}
+	s.measureCPU()
+	s.cpuMu.Lock()
+	defer s.cpuMu.Unlock()
+	cpu := s.cpuUsed - s.cpuCharged
+	s.cpuCharged = s.cpuUsed
+	return cpu
+}
+
+// Close stops the container and removes the session's files. It is safe to
+// call more than once.
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if s.stdin != nil {
+			s.stdin.Close()
+		}
+		if s.cmd != nil && s.cmd.Process != nil {
+			if s.metered {
+				s.measureCPU()
+			}
+			s.cpuMu.Lock()
+			s.cpuFinal = true
+			s.cpuMu.Unlock()
+			killContainer(s.container)
+			if cpu := s.UnchargedCPU(); cpu > 0 && ChargeSessionCPU != nil {
+				ChargeSessionCPU(s.Owner, cpu)
+			}
+		}
+		if s.dir != "" {
+			os.RemoveAll(s.dir)
+		}
+
+		sessionsMu.Lock()
+		delete(sessions, s.ID)
+		sessionsMu.Unlock()
+	})
+}
@@ -0,0 +1,203 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Test case statuses.
+const (
+	TestPassed  = "passed"
+	TestFailed  = "failed"
+	TestSkipped = "skipped"
+)
+
+// TestCase is the outcome of a single test.
+type TestCase struct {
+	Name       string  `json:"name"`
+	Suite      string  `json:"suite,omitempty"` // Package, class or file the test belongs to
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+	Message    string  `json:"message,omitempty"` // Failure message and output
+}
+
+// TestReport summarises a test run.
+type TestReport struct {
+	Passed  int        `json:"passed"`
+	Failed  int        `json:"failed"`
+	Skipped int        `json:"skipped"`
+	Tests   []TestCase `json:"tests"`
+}
+
+func (r *TestReport) add(tc TestCase) {
+	switch tc.Status {
+	case TestPassed:
+		r.Passed++
+	case TestSkipped:
+		r.Skipped++
+	default:
+		tc.Status = TestFailed
+		r.Failed++
+	}
+	r.Tests = append(r.Tests, tc)
+}
+
+// testReportParsers are referenced by name from the test runner's Parser.
+var testReportParsers = map[string]func([]byte) (*TestReport, error){
+	"go-test-json": parseGoTestJSON,
+	"junit":        parseJUnitXML,
+	"jest":         parseJestJSON,
+}
+
+// parseGoTestJSON reads `go test -json` events.
+func parseGoTestJSON(data []byte) (*TestReport, error) {
+	type key struct{ pkg, test string }
+	output := map[key]*strings.Builder{}
+	report := &TestReport{Tests: []TestCase{}}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var ev struct {
+			Action  string
+			Package string
+			Test    string
+			Elapsed float64
+			Output  string
+		}
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil || ev.Test == "" {
+			continue
+		}
+		k := key{ev.Package, ev.Test}
+		switch ev.Action {
+		case "output":
+			if output[k] == nil {
+				output[k] = &strings.Builder{}
+			}
+			output[k].WriteString(ev.Output)
+		case "pass", "fail", "skip":
+			tc := TestCase{Name: ev.Test, Suite: ev.Package, DurationMs: ev.Elapsed * 1000}
+			switch ev.Action {
+			case "pass":
+				tc.Status = TestPassed
+			case "skip":
+				tc.Status = TestSkipped
+			default:
+				tc.Status = TestFailed
+				if out := output[k]; out != nil {
+					tc.Message = strings.TrimSpace(out.String())
+				}
+			}
+			report.add(tc)
+		}
+	}
+	return report, scanner.Err()
+}
+
+// parseJUnitXML reads the JUnit XML format written by pytest, the JUnit
+// console launcher and GoogleTest. Test cases are collected wherever they
+// appear, so both <testsuites> and bare <testsuite> roots work.
+func parseJUnitXML(data []byte) (*TestReport, error) {
+	type message struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+	type testcase struct {
+		Name      string    `xml:"name,attr"`
+		Classname string    `xml:"classname,attr"`
+		Time      string    `xml:"time,attr"`
+		Result    string    `xml:"result,attr"` // GoogleTest: "skipped" for disabled tests
+		Failures  []message `xml:"failure"`
+		Errors    []message `xml:"error"`
+		Skipped   *message  `xml:"skipped"`
+	}
+
+	report := &TestReport{Tests: []TestCase{}}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "testcase" {
+			continue
+		}
+		var t testcase
+		if err := dec.DecodeElement(&t, &start); err != nil {
+			return report, err
+		}
+
+		seconds, _ := strconv.ParseFloat(t.Time, 64)
+		tc := TestCase{Name: t.Name, Suite: t.Classname, DurationMs: seconds * 1000, Status: TestPassed}
+		problems := append(t.Failures, t.Errors...)
+		switch {
+		case len(problems) > 0:
+			tc.Status = TestFailed
+			var msgs []string
+			for _, p := range problems {
+				msgs = append(msgs, strings.TrimSpace(strings.TrimSpace(p.Message)+"\n"+strings.TrimSpace(p.Text)))
+			}
+			tc.Message = strings.Join(msgs, "\n")
+		case t.Skipped != nil || t.Result == "skipped":
+			tc.Status = TestSkipped
+			if t.Skipped != nil {
+				tc.Message = t.Skipped.Message
+			}
+		}
+		report.add(tc)
+	}
+}
+
+// parseJestJSON reads the report written by `jest --json`.
+func parseJestJSON(data []byte) (*TestReport, error) {
+	var results struct {
+		TestResults []struct {
+			Name             string `json:"name"`
+			Message          string `json:"message"`
+			AssertionResults []struct {
+				FullName        string   `json:"fullName"`
+				Status          string   `json:"status"`
+				Duration        *float64 `json:"duration"`
+				FailureMessages []string `json:"failureMessages"`
+			} `json:"assertionResults"`
+		} `json:"testResults"`
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	report := &TestReport{Tests: []TestCase{}}
+	for _, file := range results.TestResults {
+		suite := cleanPath(file.Name)
+		if len(file.AssertionResults) == 0 && file.Message != "" {
+			// The file failed to load, e.g. because of a syntax error
+			report.add(TestCase{Name: suite, Suite: suite, Status: TestFailed, Message: file.Message})
+			continue
+		}
+		for _, a := range file.AssertionResults {
+			tc := TestCase{Name: a.FullName, Suite: suite, Message: strings.Join(a.FailureMessages, "\n")}
+			if a.Duration != nil {
+				tc.DurationMs = *a.Duration
+			}
+			switch a.Status {
+			case "passed":
+				tc.Status = TestPassed
+			case "pending", "skipped", "todo", "disabled":
+				tc.Status = TestSkipped
+			default:
+				tc.Status = TestFailed
+			}
+			report.add(tc)
+		}
+	}
+	return report, nil
+}
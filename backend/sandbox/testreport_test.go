@@ -0,0 +1,125 @@
+package sandbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTestReports(t *testing.T) {
+	tests := []struct {
+		name    string
+		parser  string
+		data    string
+		want    *TestReport
+		wantErr bool
+	}{
+		{
+			name:   "go test events",
+			parser: "go-test-json",
+			data: `{"Action":"run","Package":"main","Test":"TestAdd"}
+{"Action":"output","Package":"main","Test":"TestAdd","Output":"--- PASS: TestAdd\n"}
+{"Action":"pass","Package":"main","Test":"TestAdd","Elapsed":0.01}
+{"Action":"output","Package":"main","Test":"TestSub","Output":"    main_test.go:9: got 1, want 2\n"}
+{"Action":"fail","Package":"main","Test":"TestSub","Elapsed":0.002}
+{"Action":"skip","Package":"main","Test":"TestSlow","Elapsed":0}
+{"Action":"fail","Package":"main","Elapsed":0.3}
+`,
+			want: &TestReport{Passed: 1, Failed: 1, Skipped: 1, Tests: []TestCase{
+				{Name: "TestAdd", Suite: "main", Status: TestPassed, DurationMs: 10},
+				{Name: "TestSub", Suite: "main", Status: TestFailed, DurationMs: 2, Message: "main_test.go:9: got 1, want 2"},
+				{Name: "TestSlow", Suite: "main", Status: TestSkipped},
+			}},
+		},
+		{
+			name:   "go test output that is not JSON is skipped",
+			parser: "go-test-json",
+			data:   "# main [main.test]\n./main_test.go:3:1: syntax error\n{\"Action\":\"pass\",\"Package\":\"main\",\"Test\":\"TestA\"}\n{broken\n",
+			want: &TestReport{Passed: 1, Tests: []TestCase{
+				{Name: "TestA", Suite: "main", Status: TestPassed},
+			}},
+		},
+		{
+			name:   "go test with no events",
+			parser: "go-test-json",
+			data:   "",
+			want:   &TestReport{Tests: []TestCase{}},
+		},
+		{
+			name:   "junit from pytest",
+			parser: "junit",
+			data: `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" tests="4">
+<testcase classname="test_main" name="test_add" time="0.001"/>
+<testcase classname="test_main" name="test_sub" time="0.002"><failure message="assert 1 == 2">def test_sub():
+&gt;   assert 1 == 2</failure></testcase>
+<testcase classname="test_main" name="test_div" time="0"><error message="ZeroDivisionError"/></testcase>
+<testcase classname="test_main" name="test_skip" time="0"><skipped message="not ready"/></testcase>
+</testsuite></testsuites>`,
+			want: &TestReport{Passed: 1, Failed: 2, Skipped: 1, Tests: []TestCase{
+				{Name: "test_add", Suite: "test_main", Status: TestPassed, DurationMs: 1},
+				{Name: "test_sub", Suite: "test_main", Status: TestFailed, DurationMs: 2, Message: "assert 1 == 2\ndef test_sub():\n>   assert 1 == 2"},
+				{Name: "test_div", Suite: "test_main", Status: TestFailed, Message: "ZeroDivisionError"},
+				{Name: "test_skip", Suite: "test_main", Status: TestSkipped, Message: "not ready"},
+			}},
+		},
+		{
+			name:   "junit from GoogleTest with a bare testsuite root",
+			parser: "junit",
+			data:   `<testsuite name="Math"><testcase name="Adds" classname="Math" time="0.5" result="completed"/><testcase name="DISABLED_Old" classname="Math" time="0" result="skipped"/></testsuite>`,
+			want: &TestReport{Passed: 1, Skipped: 1, Tests: []TestCase{
+				{Name: "Adds", Suite: "Math", Status: TestPassed, DurationMs: 500},
+				{Name: "DISABLED_Old", Suite: "Math", Status: TestSkipped},
+			}},
+		},
+		{
+			name:    "truncated junit keeps the cases read so far",
+			parser:  "junit",
+			data:    `<testsuite><testcase name="a" classname="s" time="x"/><testcase name="b"`,
+			want:    &TestReport{Passed: 1, Tests: []TestCase{{Name: "a", Suite: "s", Status: TestPassed}}},
+			wantErr: true,
+		},
+		{
+			name:   "jest",
+			parser: "jest",
+			data: `{"testResults":[
+{"name":"/app/main.test.js","assertionResults":[
+ {"fullName":"adds","status":"passed","duration":3,"failureMessages":[]},
+ {"fullName":"subtracts","status":"failed","duration":null,"failureMessages":["expected 2","received 1"]},
+ {"fullName":"later","status":"todo","failureMessages":[]}
+]},
+{"name":"/app/broken.test.js","message":"SyntaxError: Unexpected token","assertionResults":[]}
+]}`,
+			want: &TestReport{Passed: 1, Failed: 2, Skipped: 1, Tests: []TestCase{
+				{Name: "adds", Suite: "main.test.js", Status: TestPassed, DurationMs: 3},
+				{Name: "subtracts", Suite: "main.test.js", Status: TestFailed, Message: "expected 2\nreceived 1"},
+				{Name: "later", Suite: "main.test.js", Status: TestSkipped},
+				{Name: "broken.test.js", Suite: "broken.test.js", Status: TestFailed, Message: "SyntaxError: Unexpected token"},
+			}},
+		},
+		{
+			name:   "jest with an unknown status counts as failed",
+			parser: "jest",
+			data:   `{"testResults":[{"name":"t.js","assertionResults":[{"fullName":"x","status":"exploded"}]}]}`,
+			want: &TestReport{Failed: 1, Tests: []TestCase{
+				{Name: "x", Suite: "t.js", Status: TestFailed},
+			}},
+		},
+		{
+			name:    "jest report that is not JSON",
+			parser:  "jest",
+			data:    "FAIL main.test.js",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testReportParsers[tt.parser]([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s parser error = %v, wantErr %v", tt.parser, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s parser =\n%+v\nwant\n%+v", tt.parser, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usageFile is written by `time` inside the working directory.
+const usageFile = ".codeexec-usage"
+
+// A running container's CPU time is read every cpuSampleInterval, starting
+// more often so that short runs get sampled too. CPU time after the last
+// sample, or of a run never sampled, is charged as if every core was busy.
+const (
+	firstCPUSample    = 25 * time.Millisecond
+	cpuSampleInterval = 200 * time.Millisecond
+)
+
+// Usage is the resource consumption of an execution. CPU time is measured
+// from outside the container through the Docker API; peak memory comes from
+// a `time` utility inside it (busybox and GNU time both work) and is only
+// known when the image ships one.
+type Usage struct {
+	WallTimeMs   int64 `json:"wallTimeMs" bson:"wallTimeMs"`
+	CPUTimeMs    int64 `json:"cpuTimeMs" bson:"cpuTimeMs"` // User plus system time
+	PeakMemoryKB int64 `json:"peakMemoryKb" bson:"peakMemoryKb"`
+}
+
+// timedCommand wraps a shell command so that its resource usage is written
+// to usageFile. The command is passed as $0 to avoid another level of quoting.
+func timedCommand(command string) []string {
+	script := `if [ -x /usr/bin/time ]; then exec /usr/bin/time -o ` + usageFile + ` -f "%e %U %S %M" sh -c "$0"; else exec sh -c "$0"; fi`
+	return []string{"sh", "-c", script, command}
+}
+
+// readPeakMemory parses the peak memory from the usage file left in dir. The
+// file is user-writable, so the value is clamped to the container limit and
+// must never be used for anything charged.
+func readPeakMemory(dir string) (int64, error) {
+	data, err := readSandboxFile(dir, usageFile, 4096)
+	if err != nil {
+		return 0, err
+	}
+
+	// time may prefix the numbers with a "Command exited" line
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) != 4 {
+		return 0, fmt.Errorf("unexpected usage format")
+	}
+	kb, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil || kb < 0 {
+		return 0, fmt.Errorf("unexpected usage value %q", fields[3])
+	}
+	return min(int64(kb), memoryLimitKB), nil
+}
+
+// dockerAPI talks to the Docker daemon's socket directly, for what the CLI
+// does not show, such as a container's cumulative CPU time.
+var dockerAPI = &http.Client{
+	Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", dockerSocket())
+		},
+	},
+	Timeout: 5 * time.Second,
+}
+
+func dockerSocket() string {
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		return strings.TrimPrefix(host, "unix://")
+	}
+	return "/var/run/docker.sock"
+}
+
+// containerCPU reads a running container's cumulative CPU time from its
+// cgroup through the Docker API. The program cannot influence it.
+func containerCPU(ctx context.Context, container string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/"+url.PathEscape(container)+"/stats?stream=false&one-shot=true", nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := dockerAPI.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read stats of %s: %w", container, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to read stats of %s: %s", container, resp.Status)
+	}
+	var stats struct {
+		Read     time.Time `json:"read"`
+		CPUStats struct {
+			CPUUsage struct {
+				TotalUsage uint64 `json:"total_usage"`
+			} `json:"cpu_usage"`
+		} `json:"cpu_stats"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return 0, fmt.Errorf("failed to decode stats of %s: %w", container, err)
+	}
+	// Stopped containers report zeroed stats
+	if stats.Read.IsZero() {
+		return 0, fmt.Errorf("container %s is not running", container)
+	}
+	return time.Duration(stats.CPUStats.CPUUsage.TotalUsage), nil
+}
+
+// cpuMeter samples a container's CPU time while it runs, since its cgroup
+// and with it the final figure are gone once it exits.
+type cpuMeter struct {
+	mu        sync.Mutex
+	cpu       time.Duration // At the last sample
+	sampledAt time.Time
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// meterCPU starts sampling the named container, which need not exist yet.
+func meterCPU(container string, start time.Time) *cpuMeter {
+	m := &cpuMeter{sampledAt: start, stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(m.done)
+		interval := firstCPUSample
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-time.After(interval):
+			}
+			interval = min(2*interval, cpuSampleInterval)
+			ctx, cancel := context.WithTimeout(context.Background(), cpuSampleInterval)
+			cpu, err := containerCPU(ctx, container)
+			cancel()
+			if err == nil {
+				m.mu.Lock()
+				m.cpu, m.sampledAt = cpu, time.Now()
+				m.mu.Unlock()
+			}
+		}
+	}()
+	return m
+}
+
+// finish stops sampling and returns the CPU time of a run that ended at end.
+func (m *cpuMeter) finish(end time.Time, cpus float64) time.Duration {
+	close(m.stop)
+	<-m.done
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return chargedCPU(m.cpu, m.sampledAt, end, cpus)
+}
+
+// chargedCPU is the CPU time sampled at sampledAt plus the most the
+// container could have used from then until end.
+func chargedCPU(sampled time.Duration, sampledAt, end time.Time, cpus float64) time.Duration {
+	return sampled + time.Duration(float64(max(end.Sub(sampledAt), 0))*cpus)
+}
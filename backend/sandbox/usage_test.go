@@ -0,0 +1,109 @@
+package sandbox
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadPeakMemory(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		outside bool // Written outside the dir and symlinked in
+		want    int64
+		wantErr bool
+	}{
+		{name: "gnu time", data: "0.52 0.40 0.05 20480\n", want: 20480},
+		{name: "after a note", data: "Command exited with non-zero status 1\n0.10 0.08 0.01 1000\n", want: 1000},
+		{name: "clamped to the container limit", data: "0 0 0 99999999999\n", want: memoryLimitKB},
+		{name: "negative", data: "0 0 0 -5\n", wantErr: true},
+		{name: "garbled", data: "0 0 0\n", wantErr: true},
+		{name: "missing", wantErr: true},
+		{name: "symlink out of the dir", data: "0 0 0 1000\n", outside: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			switch {
+			case tt.outside:
+				file := filepath.Join(t.TempDir(), "usage")
+				os.WriteFile(file, []byte(tt.data), 0o644)
+				os.Symlink(file, filepath.Join(dir, usageFile))
+			case tt.data != "":
+				os.WriteFile(filepath.Join(dir, usageFile), []byte(tt.data), 0o644)
+			}
+			got, err := readPeakMemory(dir)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("readPeakMemory() = %d, %v; want %d, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChargedCPU(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name      string
+		sampled   time.Duration
+		sampledAt time.Time
+		end       time.Time
+		want      time.Duration
+	}{
+		{"never sampled", 0, start, start.Add(time.Second), 2 * time.Second},
+		{"sampled at the end", 300 * time.Millisecond, start.Add(time.Second), start.Add(time.Second), 300 * time.Millisecond},
+		{"tail after the last sample", 300 * time.Millisecond, start.Add(time.Second), start.Add(1100 * time.Millisecond), 500 * time.Millisecond},
+		{"sample after the end", 300 * time.Millisecond, start.Add(time.Second), start, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chargedCPU(tt.sampled, tt.sampledAt, tt.end, 2); got != tt.want {
+				t.Errorf("chargedCPU() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerCPU(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/running/stats":
+			if r.URL.Query().Get("stream") != "false" {
+				t.Errorf("stats requested as a stream")
+			}
+			w.Write([]byte(`{"read":"2026-01-02T03:04:05Z","cpu_stats":{"cpu_usage":{"total_usage":1500000000}}}`))
+		case "/containers/stopped/stats":
+			w.Write([]byte(`{"read":"0001-01-01T00:00:00Z","cpu_stats":{"cpu_usage":{"total_usage":0}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})}
+	go server.Serve(ln)
+	defer server.Close()
+	t.Setenv("DOCKER_HOST", "unix://"+socket)
+
+	tests := []struct {
+		container string
+		want      time.Duration
+		wantErr   bool
+	}{
+		{"running", 1500 * time.Millisecond, false},
+		{"stopped", 0, true},
+		{"missing", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := containerCPU(context.Background(), tt.container)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("containerCPU(%s) = %v, %v; want %v, error %v", tt.container, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+const (
+	BackendDocker = "docker"
+	BackendWasm   = "wasm"
+
+	// wasmBinary is what a WasmRuntime's Compile command must produce.
+	wasmBinary = "main.wasm"
+	// wasmMaxOutput bounds the output kept in memory for one execution.
+	wasmMaxOutput = 1 << 20
+	wasmPageKB    = 64
+	// defaultWasmMemoryMB is the memory budget for concurrent guests when
+	// SANDBOX_WASM_MEMORY_MB is unset.
+	defaultWasmMemoryMB = 4096
+)
+
+// WasmRuntime runs a language in the embedded WebAssembly runtime instead
+// of a container. The guest sees the execution's files at / and nothing of
+// the host except Mounts; it has no network. Guests are limited in memory
+// and time only: wazero has no fuel or instruction metering, so a guest
+// that spins keeps a CPU core busy until the execution timeout.
+type WasmRuntime struct {
+	// Module is a WASI build of the interpreter, resolved against the
+	// backend's working directory. Compiled languages leave it empty.
+	Module string `json:"module,omitempty"`
+	// Compile builds main.wasm from the sources. It runs as a shell command
+	// in a bubblewrap jail (see jailCommand), in /work with the execution's
+	// files and an empty environment, so it must not execute user code.
+	Compile string            `json:"compile,omitempty"`
+	Args    []string          `json:"args"` // Guest argv, starting with the program name
+	Env     map[string]string `json:"env,omitempty"`
+	// Mounts maps guest paths to read-only host directories, such as an
+	// interpreter's standard library.
+	Mounts map[string]string `json:"mounts,omitempty"`
+}
+
+// wasmBackend reports whether lang runs in the WebAssembly runtime: only
+// when SANDBOX_BACKEND selects it and the language has a Wasm entry.
+func wasmBackend(lang Language) bool {
+	return lang.Wasm != nil && os.Getenv("SANDBOX_BACKEND") == BackendWasm
+}
+
+var (
+	wasmCacheOnce sync.Once
+	wasmCache     wazero.CompilationCache
+
+	// wasmSlots holds one slot per guest that fits in the memory budget.
+	// Guests run inside the backend process, so unlike containers nothing
+	// else stops them from exhausting its memory together.
+	wasmSlotsOnce sync.Once
+	wasmSlots     chan struct{}
+)
+
+// wasmMemoryBudgetMB is SANDBOX_WASM_MEMORY_MB, the memory all guests may
+// use together.
+func wasmMemoryBudgetMB() (int, error) {
+	v := os.Getenv("SANDBOX_WASM_MEMORY_MB")
+	if v == "" {
+		return defaultWasmMemoryMB, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < memoryLimitKB/1024 {
+		return 0, fmt.Errorf("invalid SANDBOX_WASM_MEMORY_MB %q, need at least %d", v, memoryLimitKB/1024)
+	}
+	return n, nil
+}
+
+func guestSlots() chan struct{} {
+	wasmSlotsOnce.Do(func() {
+		budgetMB, err := wasmMemoryBudgetMB()
+		if err != nil {
+			budgetMB = defaultWasmMemoryMB
+		}
+		wasmSlots = make(chan struct{}, budgetMB/(memoryLimitKB/1024))
+	})
+	return wasmSlots
+}
+
+// StartWasm checks the WebAssembly runtime's settings and, if any language
+// compiles to Wasm, that the compile jail works. It does nothing unless
+// SANDBOX_BACKEND is wasm.
+func StartWasm() error {
+	if os.Getenv("SANDBOX_BACKEND") != BackendWasm {
+		return nil
+	}
+	budgetMB, err := wasmMemoryBudgetMB()
+	if err != nil {
+		return err
+	}
+	for _, lang := range allLanguages() {
+		if lang.Wasm != nil && lang.Wasm.Compile != "" {
+			if err := checkJail(); err != nil {
+				return fmt.Errorf("%s compiles to Wasm but the compile jail does not work: %w", lang.Name, err)
+			}
+			break
+		}
+	}
+	log.Printf("WebAssembly runtime: %d concurrent guests in %d MB", cap(guestSlots()), budgetMB)
+	return nil
+}
+
+// checkJail runs an empty command in the compile jail, which fails where
+// bwrap is missing or user namespaces are not allowed.
+func checkJail() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	dir, err := os.MkdirTemp(execRoot, "codeexec-wasm-*")
+	if err != nil {
+		return fmt.Errorf("failed to make temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if output, err := jailCommand(ctx, dir, "true").CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// jailCommand runs command with sh in a bubblewrap jail: in new namespaces
+// without network, with the system and /opt read-only, dir writable at
+// /work, a fresh /tmp, and SANDBOX_WASM_BUILD_CACHE (if set) as a
+// copy-on-write /cache whose changes are discarded. The jail dies with the
+// backend, and the context kills it.
+func jailCommand(ctx context.Context, dir, command string) *exec.Cmd {
+	args := []string{
+		"--unshare-all", "--die-with-parent", "--new-session", "--clearenv",
+		"--ro-bind", "/usr", "/usr",
+		"--ro-bind-try", "/bin", "/bin",
+		"--ro-bind-try", "/sbin", "/sbin",
+		"--ro-bind-try", "/lib", "/lib",
+		"--ro-bind-try", "/lib64", "/lib64",
+		"--ro-bind-try", "/opt", "/opt",
+		"--ro-bind-try", "/etc/alternatives", "/etc/alternatives",
+		"--ro-bind-try", "/etc/ld.so.cache", "/etc/ld.so.cache",
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--tmpfs", "/tmp",
+		"--bind", dir, "/work",
+		"--chdir", "/work",
+		"--setenv", "PATH", os.Getenv("PATH"),
+		"--setenv", "HOME", "/work",
+	}
+	if cache := os.Getenv("SANDBOX_WASM_BUILD_CACHE"); cache != "" {
+		args = append(args, "--overlay-src", cache, "--tmp-overlay", "/cache")
+	} else {
+		args = append(args, "--tmpfs", "/cache")
+	}
+	args = append(args, "sh", "-c", command)
+	cmd := exec.CommandContext(ctx, "bwrap", args...)
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	return cmd
+}
+
+// compilationCache keeps machine code for modules across executions, in
+// SANDBOX_WASM_CACHE if set so that it also survives restarts.
+func compilationCache() wazero.CompilationCache {
+	wasmCacheOnce.Do(func() {
+		if dir := os.Getenv("SANDBOX_WASM_CACHE"); dir != "" {
+			cache, err := wazero.NewCompilationCacheWithDir(dir)
+			if err == nil {
+				wasmCache = cache
+				return
+			}
+		}
+		wasmCache = wazero.NewCompilationCache()
+	})
+	return wasmCache
+}
+
+// runWasmOnce registers a new execution and runs it in the WebAssembly
+// runtime, bounded by timeout. Compilation counts towards the timeout, as it
+// does in containers.
+func runWasmOnce(id, owner string, timeout time.Duration, rt *WasmRuntime, files map[string]string, req Request) (*containerResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	exe, err := register(id, owner, cancel)
+	if err != nil {
+		return &containerResult{}, err
+	}
+	defer unregister(id)
+
+	// Waiting for memory counts towards the timeout, so a busy backend
+	// answers with timeouts rather than an unbounded queue
+	res := &containerResult{}
+	select {
+	case guestSlots() <- struct{}{}:
+		defer func() { <-guestSlots() }()
+	case <-ctx.Done():
+		if exe.isCancelled() {
+			return res, errCancelled
+		}
+		return res, fmt.Errorf("execution timed out waiting for memory: %w", ctx.Err())
+	}
+	out, err := executeWasm(ctx, rt, files, req, res)
+	res.output = out.String()
+
+	if exe.isCancelled() {
+		return res, errCancelled
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return res, fmt.Errorf("execution timed out: %w", ctx.Err())
+	}
+	return res, err
+}
+
+// executeWasm runs the request's program. Its args follow the runtime's own
+// and its env is set first, so the runtime's variables win.
+func executeWasm(ctx context.Context, rt *WasmRuntime, files map[string]string, req Request, res *containerResult) (*limitedBuffer, error) {
+	out := &limitedBuffer{limit: wasmMaxOutput}
+	if req.Output != nil {
+		out.stream = &streamWriter{w: req.Output}
+	}
+
+	dir, err := os.MkdirTemp(execRoot, "codeexec-wasm-*")
+	if err != nil {
+		return out, fmt.Errorf("failed to make temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writeFiles(dir, files); err != nil {
+		return out, err
+	}
+
+	start := time.Now()
+	var compileCPU time.Duration
+	defer func() {
+		if res.usage == nil {
+			res.usage = &Usage{CPUTimeMs: compileCPU.Milliseconds()}
+		}
+		res.usage.WallTimeMs = time.Since(start).Milliseconds()
+	}()
+
+	module := rt.Module
+	if rt.Compile != "" {
+		cmd := jailCommand(ctx, dir, rt.Compile)
+		cmd.Stdout, cmd.Stderr = out, out
+		err := cmd.Run()
+		if cmd.ProcessState != nil {
+			compileCPU = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+		}
+		if err != nil {
+			return out, fmt.Errorf("execution error: %w", err)
+		}
+		module = filepath.Join(dir, wasmBinary)
+	}
+	binary, err := os.ReadFile(module)
+	if err != nil {
+		return out, fmt.Errorf("failed to read module: %w", err)
+	}
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCompilationCache(compilationCache()).
+		WithMemoryLimitPages(memoryLimitKB/wasmPageKB).
+		WithCloseOnContextDone(true))
+	defer r.Close(context.Background())
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+
+	compiled, err := r.CompileModule(ctx, binary)
+	if err != nil {
+		return out, fmt.Errorf("failed to compile module: %w", err)
+	}
+
+	fsConfig := wazero.NewFSConfig().WithDirMount(dir, "/")
+	for guest, host := range rt.Mounts {
+		fsConfig = fsConfig.WithReadOnlyDirMount(host, guest)
+	}
+	config := wazero.NewModuleConfig().
+		WithArgs(append(slices.Clone(rt.Args), req.Args...)...).
+		WithStdin(strings.NewReader(req.Input)).
+		WithStdout(out).
+		WithStderr(out).
+		WithFSConfig(fsConfig).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+	for k, v := range req.Env {
+		config = config.WithEnv(k, v)
+	}
+	for k, v := range rt.Env {
+		config = config.WithEnv(k, v)
+	}
+
+	// Linear memory only grows, so its final size is the peak
+	memory := &peakMemory{}
+	run := time.Now()
+	_, err = r.InstantiateModule(experimental.WithMemoryAllocator(ctx, memory), compiled, config)
+	// Guest code runs on this goroutine only, so its CPU time is the time
+	// it ran for
+	res.usage = &Usage{
+		CPUTimeMs:    (compileCPU + time.Since(run)).Milliseconds(),
+		PeakMemoryKB: int64(memory.peak / 1024),
+	}
+
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		if ctx.Err() != nil {
+			return out, ctx.Err()
+		}
+		return out, fmt.Errorf("execution error: %w", &wasmExitError{code: int(exitErr.ExitCode())})
+	}
+	if err != nil {
+		return out, fmt.Errorf("execution error: %w", err)
+	}
+	return out, nil
+}
+
+// wasmExitError is a guest's non-zero exit status.
+type wasmExitError struct {
+	code int
+}
+
+func (e *wasmExitError) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+func (e *wasmExitError) ExitCode() int { return e.code }
+
+// peakMemory backs guest linear memory and records its largest size.
+type peakMemory struct {
+	peak uint64
+}
+
+func (m *peakMemory) Allocate(capacity, maximum uint64) experimental.LinearMemory {
+	return &linearMemory{owner: m, buf: make([]byte, 0, capacity), max: maximum}
+}
+
+type linearMemory struct {
+	owner *peakMemory
+	buf   []byte
+	max   uint64
+}
+
+func (l *linearMemory) Reallocate(size uint64) []byte {
+	if size > l.max {
+		return nil
+	}
+	if size > uint64(cap(l.buf)) {
+		grown := make([]byte, size, min(max(size, 2*uint64(cap(l.buf))), l.max))
+		copy(grown, l.buf)
+		l.buf = grown
+	} else {
+		l.buf = l.buf[:size]
+	}
+	l.owner.peak = max(l.owner.peak, size)
+	return l.buf
+}
+
+func (l *linearMemory) Free() {
+	l.buf = nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the
+// rest, so a guest printing in a loop cannot exhaust the backend's memory.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+	stream    io.Writer // Also receives what is kept, if set
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kept := p
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		kept = p[:max(room, 0)]
+	}
+	b.buf.Write(kept)
+	if b.stream != nil && len(kept) > 0 {
+		b.stream.Write(kept)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]\n"
+	}
+	return b.buf.String()
+}
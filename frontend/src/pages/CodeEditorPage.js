@@ -124,6 +124,12 @@ function CodeEditorPage() {
                   <option value="cpp">C++</option>
                   <option value="python">Python</option>
                   <option value="java">Java</option>
+                  <option value="c">C</option>
+                  <option value="rust">Rust</option>
+                  <option value="typescript">TypeScript</option>
+                  <option value="kotlin">Kotlin</option>
+                  <option value="ruby">Ruby</option>
+                  <option value="csharp">C#</option>
                 </select>
               </div>
               <div className="w-full h-96 rounded-2xl bg-gradient-to-br from-accent-pink to-accent-purple p-1 animate-float">